@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+func Hello(ctx context.Context, request interface{}) (interface{}, error) {
+	return map[string]string{"hello": "world"}, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	// behind a load balancer forwarding UDP 443 to the HTTP/3 server, the
+	// advertised port is the public one
+	server.WithAltSvc(types.AltSvcOptions{
+		MaxAge:  24 * time.Hour,
+		Port:    443,
+		DNSName: "example.com",
+	})
+
+	server.GET("/hello").Serve(Hello)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
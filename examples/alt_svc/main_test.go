@@ -0,0 +1,98 @@
+package main_test
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestAltSvc(t *testing.T) {
+	ctx := context.Background()
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	release := make(chan struct{})
+	s.GET("/hello").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return map[string]string{"hello": "world"}, nil
+	})
+	s.GET("/slow").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		<-release
+		return map[string]string{"hello": "eventually"}, nil
+	})
+	s.GET("/panic").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		panic("boom")
+	})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// the HTTP/3 server listens on a random port, advertised once bound
+	resp, err := http.Get("http://localhost:4631/hello")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected response %v: %v", resp, err)
+	}
+	_ = resp.Body.Close()
+	match := regexp.MustCompile(`^h3=":(\d+)"; ma=86400; persist=1$`).FindStringSubmatch(resp.Header.Get("Alt-Svc"))
+	if match == nil || match[1] == "0" {
+		t.Fatalf("unexpected Alt-Svc header %q", resp.Header.Get("Alt-Svc"))
+	}
+
+	// the advertised port serves HTTP/3
+	tr := &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer tr.Close()
+	resp, err = (&http.Client{Transport: tr, Timeout: 2 * time.Second}).Get("https://localhost:" + match[1] + "/hello")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected HTTP/3 response %v: %v", resp, err)
+	}
+	_ = resp.Body.Close()
+	if resp.Header.Get("Alt-Svc") != "" || resp.Header.Get("X-Server") != "crazyhttp" {
+		t.Fatalf("unexpected HTTP/3 headers %v", resp.Header)
+	}
+
+	// panics are recovered by the root handler
+	resp, err = http.Get("http://localhost:4631/panic")
+	if err != nil || resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected the panic to be recovered, got %v: %v", resp, err)
+	}
+	_ = resp.Body.Close()
+
+	// responses sent while shutting down clear the advertisement
+	done := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get("http://localhost:4631/slow")
+		if err != nil {
+			t.Errorf("request failed: %v", err)
+		}
+		done <- resp
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		shutdown <- s.Shutdown(shutdownCtx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	resp = <-done
+	if resp == nil || resp.Header.Get("Alt-Svc") != "clear" {
+		t.Fatalf("expected the Alt-Svc header to be cleared, got %v", resp)
+	}
+	_ = resp.Body.Close()
+	if err := <-shutdown; err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+func Export(ctx context.Context, request interface{}) (response interface{}, err error) {
+	time.Sleep(time.Second)
+	return "export done", nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	// at most 4 exports at a time, 8 more wait up to 5 seconds, the rest get a
+	// 503 Service Unavailable with a Retry-After header
+	server.GET("/export").
+		WithConcurrencyLimit(types.ConcurrencyLimitOptions{
+			MaxInFlight:  4,
+			MaxQueue:     8,
+			QueueTimeout: 5 * time.Second,
+			RetryAfter:   10 * time.Second,
+		}).
+		Serve(Export)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+func TestUserRoute_ConcurrencyLimit(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4471"
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	started := make(chan struct{}, 1)
+	unblock := make(chan struct{})
+
+	server.GET("/export").
+		WithConcurrencyLimit(types.ConcurrencyLimitOptions{
+			MaxInFlight: 1,
+			RetryAfter:  5 * time.Second,
+		}).
+		Serve(func(ctx context.Context, req interface{}) (interface{}, error) {
+			started <- struct{}{}
+			<-unblock
+			return "done", nil
+		})
+
+	go func() {
+		_ = server.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	firstStatus := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://%s/export", addr))
+		if err != nil {
+			firstStatus <- 0
+			return
+		}
+		resp.Body.Close()
+		firstStatus <- resp.StatusCode
+	}()
+	<-started
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/export", addr))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 Service Unavailable, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "5" {
+		t.Errorf("Expected Retry-After 5, got %q", got)
+	}
+
+	close(unblock)
+	if status := <-firstStatus; status != http.StatusOK {
+		t.Errorf("Expected 200 OK for the admitted request, got %d", status)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/export", addr))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 OK once the slot is released, got %d", resp.StatusCode)
+	}
+}
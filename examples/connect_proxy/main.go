@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// Egress lets authenticated clients reach public web and DNS servers only.
+func Egress(ctx context.Context, r *http.Request, target types.ProxyTarget) error {
+	if r.Header.Get("Proxy-Authorization") != "Bearer egress-token" {
+		return errors.ProxyAuthenticationRequired.New("missing proxy credentials")
+	}
+
+	switch {
+	case target.Network == "tcp" && (target.Port == 80 || target.Port == 443):
+		return nil
+	case target.Network == "udp" && (target.Port == 53 || target.Port == 443):
+		return nil
+	default:
+		return errors.Forbidden.Newf("port %d is not allowed", target.Port)
+	}
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.Proxy().
+		WithPolicy(Egress).
+		Serve()
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
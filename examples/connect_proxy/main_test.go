@@ -0,0 +1,469 @@
+package main_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+func TestConnectProxy(t *testing.T) {
+	ctx := context.Background()
+
+	// targets echoing what they receive
+	tcpTarget, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer tcpTarget.Close()
+	go func() {
+		for {
+			conn, err := tcpTarget.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	udpTarget, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer udpTarget.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := udpTarget.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = udpTarget.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	tcpAddress := tcpTarget.Addr().String()
+	udpPort := udpTarget.LocalAddr().(*net.UDPAddr).Port
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	s.Proxy().
+		// the targets of the test run on the loopback interface
+		WithOptions(types.ProxyOption{DialTimeout: time.Second, AllowPrivateAddresses: true}).
+		WithPolicy(func(ctx context.Context, r *http.Request, target types.ProxyTarget) error {
+			if r.Header.Get("Proxy-Authorization") != "Bearer egress-token" {
+				return errors.ProxyAuthenticationRequired.New("missing proxy credentials")
+			}
+			if target.Host == "blocked.example.com" {
+				return errors.Forbidden.Newf("host %s is not allowed", target.Host)
+			}
+			return nil
+		}).
+		WithIPHandler(func(ctx context.Context, target types.ProxyTarget, tunnel types.IPTunnel) error {
+			requested, err := tunnel.ReceiveAddressRequest(ctx)
+			if err != nil {
+				return err
+			}
+			// IPv4 requests get an address of the documentation range
+			var assigned []types.IPAddress
+			for _, address := range requested {
+				prefix := netip.PrefixFrom(netip.IPv6Unspecified(), 128)
+				if address.Prefix.Addr().Is4() {
+					prefix = netip.MustParsePrefix("192.0.2.2/32")
+				}
+				assigned = append(assigned, types.IPAddress{RequestId: address.RequestId, Prefix: prefix})
+			}
+			if err := tunnel.AssignAddresses(assigned...); err != nil {
+				return err
+			}
+			err = tunnel.AdvertiseRoutes(types.IPRoute{
+				Start:    netip.MustParseAddr("198.51.100.0"),
+				End:      netip.MustParseAddr("198.51.100.255"),
+				Protocol: 17,
+			})
+			if err != nil {
+				return err
+			}
+
+			// packets are sent back with their addresses swapped
+			for {
+				packet, err := tunnel.ReadPacket(ctx)
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				reply := append([]byte(nil), packet...)
+				copy(reply[12:16], packet[16:20])
+				copy(reply[16:20], packet[12:16])
+				if err := tunnel.WritePacket(reply); err != nil {
+					return err
+				}
+			}
+		}).
+		Serve()
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// connect opens a classic CONNECT tunnel over HTTP/1.1
+	connect := func(t *testing.T, target string, authorization string) (net.Conn, *http.Response) {
+		conn, err := net.Dial("tcp", "localhost:4601")
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		_, _ = io.WriteString(conn, "CONNECT "+target+" HTTP/1.1\r\nHost: "+target+"\r\nProxy-Authorization: "+authorization+"\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		return conn, resp
+	}
+
+	t.Run("CONNECT over HTTP/1.1", func(t *testing.T) {
+		conn, resp := connect(t, tcpAddress, "Bearer egress-token")
+		defer conn.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %d", resp.StatusCode)
+		}
+
+		_, _ = io.WriteString(conn, "hello through the tunnel")
+		p := make([]byte, len("hello through the tunnel"))
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, err := io.ReadFull(conn, p); err != nil || string(p) != "hello through the tunnel" {
+			t.Fatalf("unexpected echo %q: %v", p, err)
+		}
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		conn, resp := connect(t, tcpAddress, "")
+		_ = conn.Close()
+		if resp.StatusCode != http.StatusProxyAuthRequired {
+			t.Fatalf("expected credentials to be required, got %d", resp.StatusCode)
+		}
+
+		conn, resp = connect(t, "blocked.example.com:443", "Bearer egress-token")
+		_ = conn.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected the host to be denied, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("multicast and broadcast targets", func(t *testing.T) {
+		for _, target := range []string{"224.0.0.1:443", "255.255.255.255:443", "[ff02::1]:443"} {
+			conn, resp := connect(t, target, "Bearer egress-token")
+			_ = conn.Close()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("expected %s to be denied, got %d", target, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("unreachable target", func(t *testing.T) {
+		closed, _ := net.Listen("tcp", "127.0.0.1:0")
+		address := closed.Addr().String()
+		_ = closed.Close()
+
+		conn, resp := connect(t, address, "Bearer egress-token")
+		_ = conn.Close()
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Fatalf("expected a bad gateway, got %d", resp.StatusCode)
+		}
+	})
+
+	quicConn, err := quic.DialAddr(ctx, "localhost:4601", &tls.Config{InsecureSkipVerify: true, NextProtos: []string{http3.NextProtoH3}}, &quic.Config{EnableDatagrams: true})
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer quicConn.CloseWithError(0, "")
+
+	cc := (&http3.Transport{EnableDatagrams: true}).NewClientConn(quicConn)
+	select {
+	case <-cc.ReceivedSettings():
+	case <-time.After(time.Second):
+		t.Fatalf("expected server settings")
+	}
+
+	t.Run("CONNECT over HTTP/3", func(t *testing.T) {
+		stream, err := cc.OpenRequestStream(ctx)
+		if err != nil {
+			t.Fatalf("failed to open stream: %v", err)
+		}
+		err = stream.SendRequestHeader(&http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Host: tcpAddress},
+			Host:   tcpAddress,
+			Header: http.Header{"Proxy-Authorization": {"Bearer egress-token"}},
+		})
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		resp, err := stream.ReadResponse()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected response %v: %v", resp, err)
+		}
+
+		_, _ = io.WriteString(stream, "hello over HTTP/3")
+		p := make([]byte, len("hello over HTTP/3"))
+		_ = stream.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, err := io.ReadFull(resp.Body, p); err != nil || string(p) != "hello over HTTP/3" {
+			t.Fatalf("unexpected echo %q: %v", p, err)
+		}
+		_ = stream.Close()
+	})
+
+	t.Run("CONNECT-UDP over HTTP/3", func(t *testing.T) {
+		stream, err := cc.OpenRequestStream(ctx)
+		if err != nil {
+			t.Fatalf("failed to open stream: %v", err)
+		}
+		u, _ := url.Parse("https://localhost:4601/.well-known/masque/udp/127.0.0.1/" + strconv.Itoa(udpPort) + "/")
+		err = stream.SendRequestHeader(&http.Request{
+			Method: http.MethodConnect,
+			Proto:  "connect-udp",
+			URL:    u,
+			Host:   u.Host,
+			Header: http.Header{"Proxy-Authorization": {"Bearer egress-token"}, "Capsule-Protocol": {"?1"}},
+		})
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		resp, err := stream.ReadResponse()
+		if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Capsule-Protocol") != "?1" {
+			t.Fatalf("unexpected response %v: %v", resp, err)
+		}
+
+		// UDP payloads are prefixed with context ID 0, and may be lost
+		var reply []byte
+		for i := 0; i < 10 && reply == nil; i++ {
+			if err := stream.SendDatagram(append(quicvarint.Append(nil, 0), "ping"...)); err != nil {
+				t.Fatalf("failed to send datagram: %v", err)
+			}
+			receiveCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+			reply, _ = stream.ReceiveDatagram(receiveCtx)
+			cancel()
+		}
+		contextID, n, err := quicvarint.Parse(reply)
+		if err != nil || contextID != 0 || string(reply[n:]) != "ping" {
+			t.Fatalf("unexpected datagram %q", reply)
+		}
+		_ = stream.Close()
+	})
+
+	t.Run("CONNECT-IP over HTTP/3", func(t *testing.T) {
+		stream, err := cc.OpenRequestStream(ctx)
+		if err != nil {
+			t.Fatalf("failed to open stream: %v", err)
+		}
+		u, _ := url.Parse("https://localhost:4601/.well-known/masque/ip/198.51.100.0%2F24/17/")
+		err = stream.SendRequestHeader(&http.Request{
+			Method: http.MethodConnect,
+			Proto:  "connect-ip",
+			URL:    u,
+			Host:   u.Host,
+			Header: http.Header{"Proxy-Authorization": {"Bearer egress-token"}, "Capsule-Protocol": {"?1"}},
+		})
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		resp, err := stream.ReadResponse()
+		if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Capsule-Protocol") != "?1" {
+			t.Fatalf("unexpected response %v: %v", resp, err)
+		}
+
+		// request any IPv4 address, with request id 1
+		request := append(quicvarint.Append(nil, 1), 4, 0, 0, 0, 0, 32)
+		_, _ = stream.Write(appendCapsule(nil, 0x02, request))
+
+		capsules := bufio.NewReader(resp.Body)
+		capsuleType, value := readCapsule(t, capsules)
+		if capsuleType != 0x01 || string(value) != string(append(quicvarint.Append(nil, 1), 4, 192, 0, 2, 2, 32)) {
+			t.Fatalf("unexpected ADDRESS_ASSIGN capsule %d %v", capsuleType, value)
+		}
+		capsuleType, value = readCapsule(t, capsules)
+		if capsuleType != 0x03 || string(value) != string([]byte{4, 198, 51, 100, 0, 198, 51, 100, 255, 17}) {
+			t.Fatalf("unexpected ROUTE_ADVERTISEMENT capsule %d %v", capsuleType, value)
+		}
+
+		// a packet out of the scope of the tunnel is dropped
+		outOfScope := ipv4Packet(netip.MustParseAddr("192.0.2.2"), netip.MustParseAddr("203.0.113.1"), 17)
+		if err := stream.SendDatagram(append(quicvarint.Append(nil, 0), outOfScope...)); err != nil {
+			t.Fatalf("failed to send datagram: %v", err)
+		}
+
+		packet := ipv4Packet(netip.MustParseAddr("192.0.2.2"), netip.MustParseAddr("198.51.100.1"), 17)
+		var reply []byte
+		for i := 0; i < 10 && reply == nil; i++ {
+			if err := stream.SendDatagram(append(quicvarint.Append(nil, 0), packet...)); err != nil {
+				t.Fatalf("failed to send datagram: %v", err)
+			}
+			receiveCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+			reply, _ = stream.ReceiveDatagram(receiveCtx)
+			cancel()
+		}
+		contextID, n, err := quicvarint.Parse(reply)
+		if err != nil || contextID != 0 || len(reply[n:]) != 20 {
+			t.Fatalf("unexpected datagram %v", reply)
+		}
+		source := netip.AddrFrom4([4]byte(reply[n+12 : n+16]))
+		destination := netip.AddrFrom4([4]byte(reply[n+16 : n+20]))
+		if source.String() != "198.51.100.1" || destination.String() != "192.0.2.2" {
+			t.Fatalf("unexpected reply from %s to %s", source, destination)
+		}
+		_ = stream.Close()
+	})
+
+	t.Run("CONNECT-IP with an invalid protocol", func(t *testing.T) {
+		stream, err := cc.OpenRequestStream(ctx)
+		if err != nil {
+			t.Fatalf("failed to open stream: %v", err)
+		}
+		u, _ := url.Parse("https://localhost:4601/.well-known/masque/ip/*/300/")
+		err = stream.SendRequestHeader(&http.Request{
+			Method: http.MethodConnect,
+			Proto:  "connect-ip",
+			URL:    u,
+			Host:   u.Host,
+			Header: http.Header{"Proxy-Authorization": {"Bearer egress-token"}, "Capsule-Protocol": {"?1"}},
+		})
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		resp, err := stream.ReadResponse()
+		if err != nil || resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected the protocol to be rejected, got %v: %v", resp, err)
+		}
+	})
+
+	t.Run("CONNECT-UDP with an invalid port", func(t *testing.T) {
+		stream, err := cc.OpenRequestStream(ctx)
+		if err != nil {
+			t.Fatalf("failed to open stream: %v", err)
+		}
+		u, _ := url.Parse("https://localhost:4601/.well-known/masque/udp/127.0.0.1/70000/")
+		err = stream.SendRequestHeader(&http.Request{
+			Method: http.MethodConnect,
+			Proto:  "connect-udp",
+			URL:    u,
+			Host:   u.Host,
+			Header: http.Header{"Proxy-Authorization": {"Bearer egress-token"}, "Capsule-Protocol": {"?1"}},
+		})
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		resp, err := stream.ReadResponse()
+		if err != nil || resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected the port to be rejected, got %v: %v", resp, err)
+		}
+	})
+}
+
+func appendCapsule(b []byte, capsuleType uint64, value []byte) []byte {
+	b = quicvarint.Append(b, capsuleType)
+	b = quicvarint.Append(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func readCapsule(t *testing.T, r *bufio.Reader) (uint64, []byte) {
+	t.Helper()
+	capsuleType, err := quicvarint.Read(r)
+	if err != nil {
+		t.Fatalf("failed to read capsule: %v", err)
+	}
+	length, err := quicvarint.Read(r)
+	if err != nil {
+		t.Fatalf("failed to read capsule: %v", err)
+	}
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		t.Fatalf("failed to read capsule: %v", err)
+	}
+	return capsuleType, value
+}
+
+// ipv4Packet returns an IPv4 header without options nor payload.
+func ipv4Packet(source, destination netip.Addr, protocol byte) []byte {
+	packet := make([]byte, 20)
+	packet[0] = 0x45
+	packet[3] = 20
+	packet[8] = 64
+	packet[9] = protocol
+	copy(packet[12:16], source.AsSlice())
+	copy(packet[16:20], destination.AsSlice())
+	return packet
+}
+
+func TestConnectProxy_PrivateAddresses(t *testing.T) {
+	ctx := context.Background()
+
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer target.Close()
+
+	// the configured ports are taken by TestConnectProxy
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	h1, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	h1TLS, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+	s.Proxy().Serve()
+	s.WithListeners(types.Listeners{H3: conn, H1: h1, H1TLS: h1TLS})
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	defer s.Shutdown(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	for _, address := range []string{target.Addr().String(), "localhost:" + strconv.Itoa(target.Addr().(*net.TCPAddr).Port)} {
+		client, err := net.Dial("tcp", h1.Addr().String())
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		_, _ = io.WriteString(client, "CONNECT "+address+" HTTP/1.1\r\nHost: "+address+"\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(client), &http.Request{Method: http.MethodConnect})
+		_ = client.Close()
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected private target %s to be denied by default, got %d", address, resp.StatusCode)
+		}
+	}
+}
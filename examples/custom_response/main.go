@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+type CreateUserResponse struct {
+	UserId string `json:"user_id"`
+}
+
+func CreateUser(ctx context.Context, request interface{}) (response interface{}, err error) {
+	return &types.Response{
+		StatusCode: http.StatusCreated,
+		Headers:    http.Header{"Location": {"/users/123"}},
+		Cookies:    []*http.Cookie{{Name: "session", Value: "abc", HttpOnly: true}},
+		Body:       CreateUserResponse{UserId: "123"},
+	}, nil
+}
+
+func Ping(ctx context.Context, request interface{}) (response interface{}, err error) {
+	crazyserver.ResponseHeaders(ctx).Set("Cache-Control", "max-age=60")
+	crazyserver.SetStatusCode(ctx, http.StatusAccepted)
+
+	return "pong", nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.POST("/users").Serve(CreateUser)
+	server.GET("/ping").Serve(Ping)
+	server.GET("/old-ping").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return crazyserver.MovedPermanently("/ping"), nil
+	})
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
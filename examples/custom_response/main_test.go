@@ -0,0 +1,109 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+func TestUserRoute_CustomResponse(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4441"
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	server.POST("/users").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return &types.Response{
+			StatusCode: http.StatusCreated,
+			Headers:    http.Header{"Location": {"/users/123"}},
+			Cookies:    []*http.Cookie{{Name: "session", Value: "abc"}},
+			Body:       map[string]string{"user_id": "123"},
+		}, nil
+	})
+	server.GET("/ping").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		crazyserver.ResponseHeaders(ctx).Set("Cache-Control", "max-age=60")
+		crazyserver.SetStatusCode(ctx, http.StatusAccepted)
+		return "pong", nil
+	})
+	server.DELETE("/users/123").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return types.Response{StatusCode: http.StatusNoContent}, nil
+	})
+	server.GET("/old-ping").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return crazyserver.MovedPermanently("/ping"), nil
+	})
+
+	go func() {
+		_ = server.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Post(fmt.Sprintf("http://%s/users", addr), "application/json", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Expected 201 Created, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Location"); got != "/users/123" {
+		t.Errorf("Expected Location /users/123, got %q", got)
+	}
+	if cookies := resp.Cookies(); len(cookies) != 1 || cookies[0].Value != "abc" {
+		t.Errorf("Expected session cookie, got %v", cookies)
+	}
+	if string(body) != `{"user_id":"123"}` {
+		t.Errorf("Expected JSON body, got %q", body)
+	}
+
+	resp, err = client.Get(fmt.Sprintf("http://%s/ping", addr))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Expected 202 Accepted, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Cache-Control"); got != "max-age=60" {
+		t.Errorf("Expected Cache-Control max-age=60, got %q", got)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%s/users/123", addr), nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent || len(body) != 0 {
+		t.Errorf("Expected empty 204 No Content, got %d %q", resp.StatusCode, body)
+	}
+
+	resp, err = client.Get(fmt.Sprintf("http://%s/old-ping", addr))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "/ping" {
+		t.Errorf("Expected 301 to /ping, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// Catalog is safe to serve in early data, which saves a round trip to
+// returning visitors.
+func Catalog(ctx context.Context, request interface{}) (interface{}, error) {
+	return map[string]interface{}{"items": []string{"tea", "coffee"}, "early": crazyserver.IsEarlyData(ctx)}, nil
+}
+
+// SetCart replaces the cart, so a replayed request has no further effect.
+func SetCart(ctx context.Context, request interface{}) (interface{}, error) {
+	return request, nil
+}
+
+// PlaceOrder must never run twice for the same request, so it is rejected in
+// early data and the client retries it once the handshake completed.
+func PlaceOrder(ctx context.Context, request interface{}) (interface{}, error) {
+	return map[string]string{"status": "placed"}, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.GET("/catalog").Serve(Catalog)
+	server.PUT("/cart").
+		WithOptions(types.MethodOptions{AllowEarlyData: true}).
+		Serve(SetCart)
+	server.POST("/orders").Serve(PlaceOrder)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package main_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestEarlyData(t *testing.T) {
+	ctx := context.Background()
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	s.GET("/catalog").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return map[string]bool{"early": crazyserver.IsEarlyData(ctx)}, nil
+	})
+	s.PUT("/cart").
+		WithOptions(types.MethodOptions{AllowEarlyData: true}).
+		Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+			return map[string]bool{"early": crazyserver.IsEarlyData(ctx)}, nil
+		})
+	s.POST("/orders").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return map[string]string{"status": "placed"}, nil
+	})
+	s.WebSocket("/live").ServeConn(func(ctx context.Context, conn types.WebsocketConn) error {
+		return nil
+	})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	early := func(t *testing.T, resp *http.Response) bool {
+		defer resp.Body.Close()
+		var body map[string]bool
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return body["early"]
+	}
+
+	t.Run("Early-Data header from a proxy", func(t *testing.T) {
+		do := func(method, path string) *http.Response {
+			req, _ := http.NewRequest(method, "http://localhost:4621"+path, strings.NewReader(`{}`))
+			req.Header.Set("Early-Data", "1")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			return resp
+		}
+
+		resp := do(http.MethodPost, "/orders")
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusTooEarly {
+			t.Fatalf("expected unsafe requests to be rejected, got %d", resp.StatusCode)
+		}
+
+		resp = do(http.MethodGet, "/catalog")
+		if resp.StatusCode != http.StatusOK || !early(t, resp) {
+			t.Fatalf("expected safe requests to be served as early data, got %d", resp.StatusCode)
+		}
+
+		resp = do(http.MethodPut, "/cart")
+		if resp.StatusCode != http.StatusOK || !early(t, resp) {
+			t.Fatalf("expected the route to allow early data, got %d", resp.StatusCode)
+		}
+
+		// upgrades are unsafe, even with a safe method
+		req, _ := http.NewRequest(http.MethodGet, "http://localhost:4621/live", nil)
+		req.Header.Set("Early-Data", "1")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusTooEarly {
+			t.Fatalf("expected the upgrade to be rejected, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("0-RTT over HTTP/3", func(t *testing.T) {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{http3.NextProtoH3},
+			ClientSessionCache: tls.NewLRUClientSessionCache(1),
+		}
+
+		// a first connection receives the session ticket
+		tr := &http3.Transport{TLSClientConfig: tlsConfig}
+		resp, err := (&http.Client{Transport: tr}).Get("https://localhost:4621/catalog")
+		if err != nil || resp.StatusCode != http.StatusOK || early(t, resp) {
+			t.Fatalf("unexpected response %v: %v", resp, err)
+		}
+		_ = tr.Close()
+
+		// requests are sent in 0-RTT data on resumption, without waiting for
+		// the handshake
+		send := func(method, path string) *http.Response {
+			conn, err := quic.DialAddrEarly(ctx, "localhost:4621", tlsConfig, &quic.Config{})
+			if err != nil {
+				t.Fatalf("dial failed: %v", err)
+			}
+			t.Cleanup(func() { _ = conn.CloseWithError(0, "") })
+
+			stream, err := (&http3.Transport{}).NewClientConn(conn).OpenRequestStream(ctx)
+			if err != nil {
+				t.Fatalf("failed to open stream: %v", err)
+			}
+			u, _ := url.Parse("https://localhost:4621" + path)
+			if err := stream.SendRequestHeader(&http.Request{Method: method, URL: u, Host: u.Host, Header: http.Header{}}); err != nil {
+				t.Fatalf("failed to send request: %v", err)
+			}
+			_, _ = io.WriteString(stream, `{}`)
+			_ = stream.Close()
+
+			resp, err := stream.ReadResponse()
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if !conn.ConnectionState().Used0RTT {
+				t.Fatalf("expected the server to accept 0-RTT")
+			}
+			return resp
+		}
+
+		resp = send(http.MethodPost, "/orders")
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusTooEarly {
+			t.Fatalf("expected unsafe requests to be rejected, got %d", resp.StatusCode)
+		}
+
+		resp = send(http.MethodGet, "/catalog")
+		if resp.StatusCode != http.StatusOK || !early(t, resp) {
+			t.Fatalf("expected safe requests to be served as early data, got %d", resp.StatusCode)
+		}
+	})
+}
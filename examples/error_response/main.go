@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+)
+
+type CreateUserRequest struct {
+	Email string `json:"email"`
+	Age   int    `json:"age"`
+}
+
+func CreateUser(ctx context.Context, request interface{}) (response interface{}, err error) {
+	req, err := crazyserver.DecodeJsonRequest[CreateUserRequest](request)
+	if err != nil {
+		return nil, errors.BadRequest.Wrap(err, "could not parse request")
+	}
+
+	var fieldErrors []errors.FieldError
+	if req.Email == "" {
+		fieldErrors = append(fieldErrors, errors.FieldError{Field: "email", Message: "is required"})
+	}
+	if req.Age < 18 {
+		fieldErrors = append(fieldErrors, errors.FieldError{Field: "age", Message: "must be at least 18"})
+	}
+
+	if len(fieldErrors) > 0 {
+		// responds with an application/problem+json body
+		return nil, errors.WithFieldErrors(errors.BadRequest.New("validation failed"), fieldErrors...)
+	}
+
+	return req, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.POST("/users").Serve(CreateUser)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
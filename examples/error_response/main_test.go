@@ -0,0 +1,119 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUserRoute_ProblemDetailsResponse(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4451"
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	server.POST("/users").Serve(func(ctx context.Context, req interface{}) (interface{}, error) {
+		err := errors.NotFound.New("user 123 does not exist")
+		err = errors.WithFieldErrors(err, errors.FieldError{Field: "id", Message: "unknown"})
+		err = errors.WithDetail(err, "retry_after", 5)
+		return nil, err
+	})
+
+	server.GET("/wrapped").Serve(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("looking up user: %w", errors.NotFound.New("user does not exist"))
+	})
+	server.GET("/deadline").Serve(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("calling upstream: %w", context.DeadlineExceeded)
+	})
+	server.GET("/grpc").Serve(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unauthenticated, "token expired")
+	})
+
+	go func() {
+		_ = server.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/users?id=123", addr), strings.NewReader("{}"))
+	req.Header.Set("X-Request-Id", "req-1")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected 404 Not Found, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("Expected problem+json content type, got %q", got)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+
+	var problem map[string]interface{}
+	if err := json.Unmarshal(body, &problem); err != nil {
+		t.Fatalf("Error while parsing JSON, got %q", body)
+	}
+
+	want := map[string]interface{}{
+		"type":        "about:blank",
+		"title":       "NOT_FOUND_ERROR",
+		"status":      float64(404),
+		"detail":      "user 123 does not exist",
+		"instance":    "/users?id=123",
+		"request_id":  "req-1",
+		"retry_after": float64(5),
+	}
+	for k, v := range want {
+		if problem[k] != v {
+			t.Errorf("Expected %s=%v, got %v", k, v, problem[k])
+		}
+	}
+	if fieldErrors, ok := problem["errors"].([]interface{}); !ok || len(fieldErrors) != 1 {
+		t.Errorf("Expected one field error, got %v", problem["errors"])
+	}
+
+	resp, err = http.Post(fmt.Sprintf("http://%s/users", addr), "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 Bad Request for malformed JSON, got %d", resp.StatusCode)
+	}
+
+	for path, status := range map[string]int{
+		"/wrapped":  http.StatusNotFound,
+		"/deadline": http.StatusGatewayTimeout,
+		"/grpc":     http.StatusUnauthorized,
+	} {
+		resp, err = http.Get(fmt.Sprintf("http://%s%s", addr, path))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != status {
+			t.Errorf("Expected %d for %s, got %d", status, path, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+type AvatarUpload struct {
+	UserId int                 `form:"user_id"`
+	Avatar *types.UploadedFile `form:"avatar"`
+}
+
+type AvatarResponse struct {
+	UserId      int    `json:"user_id"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+func UploadAvatar(ctx context.Context, request interface{}) (interface{}, error) {
+	req, err := crazyserver.DecodeFormRequest[AvatarUpload](request)
+	if err != nil {
+		return nil, err
+	}
+
+	// the temporary file is removed once the handler returns
+	file, err := req.Avatar.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	_, _ = io.Copy(io.Discard, file)
+
+	return AvatarResponse{
+		UserId:      req.UserId,
+		ContentType: req.Avatar.ContentType,
+		Size:        req.Avatar.Size,
+	}, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.POST("/avatars").Serve(UploadAvatar).
+		WithOptions(types.MethodOptions{
+			Form: types.FormOptions{
+				MaxFileSize:      5 << 20,
+				AllowedMimeTypes: []string{"image/png", "image/jpeg"},
+			},
+		})
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
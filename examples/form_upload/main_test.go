@@ -0,0 +1,170 @@
+package main_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+type upload struct {
+	Name  string              `form:"name"`
+	Tags  []string            `form:"tag"`
+	Count int                 `form:"count"`
+	File  *types.UploadedFile `form:"file"`
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func TestHTTPServer_FormUpload(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4521"
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var tempPath string
+	s.POST("/upload").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, err := crazyserver.DecodeFormRequest[upload](request)
+		if err != nil {
+			return nil, err
+		}
+
+		content := ""
+		if req.File != nil {
+			mu.Lock()
+			tempPath = req.File.Path
+			mu.Unlock()
+
+			f, err := req.File.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			b, _ := io.ReadAll(f)
+			content = req.File.ContentType + ":" + string(b)
+		}
+
+		return fmt.Sprintf("%s %s %d %s", req.Name, strings.Join(req.Tags, "+"), req.Count, content), nil
+	}).WithOptions(types.MethodOptions{
+		Form: types.FormOptions{
+			MaxFileSize:      16,
+			AllowedMimeTypes: []string{"text/*"},
+		},
+	})
+
+	var sink bytes.Buffer
+	s.POST("/sink").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, err := crazyserver.DecodeFormRequest[upload](request)
+		if err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("%s %d", req.File.Filename, req.File.Size), nil
+	}).WithOptions(types.MethodOptions{
+		Form: types.FormOptions{
+			Sink: func(ctx context.Context, file *types.UploadedFile) (io.WriteCloser, error) {
+				return nopCloser{&sink}, nil
+			},
+		},
+	})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	multipartBody := func(fields map[string]string, filename, content string) (*bytes.Buffer, string) {
+		var b bytes.Buffer
+		mw := multipart.NewWriter(&b)
+		for key, value := range fields {
+			_ = mw.WriteField(key, value)
+		}
+		if filename != "" {
+			fw, _ := mw.CreateFormFile("file", filename)
+			_, _ = fw.Write([]byte(content))
+		}
+		mw.Close()
+		return &b, mw.FormDataContentType()
+	}
+
+	post := func(path, contentType string, body io.Reader) (int, string) {
+		resp, err := http.Post(fmt.Sprintf("http://%s%s", addr, path), contentType, body)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+
+	t.Run("multipart", func(t *testing.T) {
+		body, contentType := multipartBody(map[string]string{"name": "report", "count": "3"}, "notes.txt", "hello")
+		status, got := post("/upload", contentType, body)
+
+		if status != http.StatusOK || got != "report  3 text/plain; charset=utf-8:hello" {
+			t.Fatalf("unexpected response %d: %q", status, got)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if tempPath == "" {
+			t.Fatalf("expected file to be streamed to a temporary file")
+		}
+		if _, err := os.Stat(tempPath); !os.IsNotExist(err) {
+			t.Fatalf("expected temporary file to be removed, got %v", err)
+		}
+	})
+
+	t.Run("urlencoded", func(t *testing.T) {
+		values := url.Values{"name": {"tags"}, "tag": {"a", "b"}, "count": {"2"}}
+		status, got := post("/upload", "application/x-www-form-urlencoded", strings.NewReader(values.Encode()))
+
+		if status != http.StatusOK || got != "tags a+b 2 " {
+			t.Fatalf("unexpected response %d: %q", status, got)
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		body, contentType := multipartBody(nil, "big.txt", strings.Repeat("x", 17))
+		if status, _ := post("/upload", contentType, body); status != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected status %d for large file, got %d", http.StatusRequestEntityTooLarge, status)
+		}
+
+		body, contentType = multipartBody(nil, "image.png", "\x89PNG\r\n\x1a\n")
+		if status, _ := post("/upload", contentType, body); status != http.StatusUnsupportedMediaType {
+			t.Fatalf("expected status %d for disallowed type, got %d", http.StatusUnsupportedMediaType, status)
+		}
+
+		body, contentType = multipartBody(map[string]string{"count": "three"}, "", "")
+		if status, _ := post("/upload", contentType, body); status != http.StatusBadRequest {
+			t.Fatalf("expected status %d for invalid field, got %d", http.StatusBadRequest, status)
+		}
+	})
+
+	t.Run("sink", func(t *testing.T) {
+		body, contentType := multipartBody(nil, "data.bin", "streamed")
+		status, got := post("/sink", contentType, body)
+
+		if status != http.StatusOK || got != "data.bin 8" || sink.String() != "streamed" {
+			t.Fatalf("unexpected response %d: %q, sink %q", status, got, sink.String())
+		}
+	})
+}
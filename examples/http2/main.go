@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+)
+
+func Hello(ctx context.Context, request interface{}) (interface{}, error) {
+	return map[string]string{"hello": "world"}, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	// HTTP/2 is served over TLS on the h1_ssl port, and in cleartext on the
+	// h1 port, as configured under service.http.h2
+	server.GET("/hello").Serve(Hello)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
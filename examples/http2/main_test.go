@@ -0,0 +1,182 @@
+package main_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"golang.org/x/net/http2"
+)
+
+// h2cClient speaks HTTP/2 with prior knowledge over cleartext connections.
+var h2cClient = &http.Client{Transport: &http2.Transport{
+	AllowHTTP: true,
+	DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	},
+}}
+
+// upgradeRequest asks to switch the connection to h2c, with empty settings.
+const upgradeRequest = "GET /hello HTTP/1.1\r\nHost: localhost\r\n" +
+	"Connection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: \r\n\r\n"
+
+// readSettings sends the client preface on conn, and returns the settings
+// the server announces.
+func readSettings(t *testing.T, r io.Reader, w io.Writer) map[http2.SettingID]uint32 {
+	if _, err := io.WriteString(w, http2.ClientPreface); err != nil {
+		t.Fatalf("failed to write the preface: %v", err)
+	}
+	framer := http2.NewFramer(w, r)
+	if err := framer.WriteSettings(); err != nil {
+		t.Fatalf("failed to write settings: %v", err)
+	}
+
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			t.Fatalf("failed to read settings: %v", err)
+		}
+		sf, ok := frame.(*http2.SettingsFrame)
+		if !ok || sf.IsAck() {
+			continue
+		}
+		settings := map[http2.SettingID]uint32{}
+		_ = sf.ForeachSetting(func(s http2.Setting) error {
+			settings[s.ID] = s.Val
+			return nil
+		})
+		return settings
+	}
+}
+
+func hello(ctx context.Context, request interface{}) (interface{}, error) {
+	return map[string]string{"hello": "world"}, nil
+}
+
+func TestHTTP2(t *testing.T) {
+	ctx := context.Background()
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+	s.GET("/hello").Serve(hello)
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("h2c with prior knowledge", func(t *testing.T) {
+		resp, err := h2cClient.Get("http://localhost:4641/hello")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "world") {
+			t.Fatalf("unexpected response %s %d: %s", resp.Proto, resp.StatusCode, body)
+		}
+	})
+
+	t.Run("h2c upgrade", func(t *testing.T) {
+		conn, err := net.Dial("tcp", "localhost:4641")
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		defer conn.Close()
+
+		if _, err := io.WriteString(conn, upgradeRequest); err != nil {
+			t.Fatalf("failed to write the request: %v", err)
+		}
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatalf("failed to read the response: %v", err)
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Upgrade") != "h2c" {
+			t.Fatalf("expected the connection to switch to h2c, got %d", resp.StatusCode)
+		}
+
+		settings := readSettings(t, br, conn)
+		if settings[http2.SettingMaxConcurrentStreams] != 50 {
+			t.Fatalf("expected the settings of the config, got %v", settings)
+		}
+	})
+
+	t.Run("settings over TLS", func(t *testing.T) {
+		conn, err := tls.Dial("tcp", "localhost:4640", &tls.Config{InsecureSkipVerify: true, NextProtos: []string{http2.NextProtoTLS}})
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		defer conn.Close()
+		if proto := conn.ConnectionState().NegotiatedProtocol; proto != http2.NextProtoTLS {
+			t.Fatalf("expected h2 to be negotiated, got %q", proto)
+		}
+
+		settings := readSettings(t, conn, conn)
+		if settings[http2.SettingMaxConcurrentStreams] != 50 ||
+			settings[http2.SettingMaxFrameSize] != 65536 ||
+			settings[http2.SettingInitialWindowSize] != 524288 {
+			t.Fatalf("expected the settings of the config, got %v", settings)
+		}
+	})
+
+	t.Run("HTTP/1.1 is still served", func(t *testing.T) {
+		resp, err := http.Get("http://localhost:4641/hello")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		_ = resp.Body.Close()
+		if resp.ProtoMajor != 1 || resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected response %s %d", resp.Proto, resp.StatusCode)
+		}
+	})
+
+	// h2c connections are shut down along with the server
+	shutdownCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	// options set programmatically replace the config
+	s = crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+	s.GET("/hello").Serve(hello)
+	s.WithHTTP2Options(types.HTTP2Options{MaxConcurrentStreams: 10})
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	defer s.Shutdown(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	if resp, err := h2cClient.Get("http://localhost:4641/hello"); err == nil {
+		_ = resp.Body.Close()
+		t.Fatalf("expected h2c to be off, got %s %d", resp.Proto, resp.StatusCode)
+	}
+
+	conn, err := net.Dial("tcp", "localhost:4641")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+	_, _ = io.WriteString(conn, upgradeRequest)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("failed to read the response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the upgrade to be ignored, got %d", resp.StatusCode)
+	}
+}
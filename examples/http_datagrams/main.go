@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"strconv"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// Telemetry ingests readings sent as datagrams, acknowledging each one. Lost
+// readings are simply superseded by the next ones.
+func Telemetry(ctx context.Context, datagrams types.DatagramConn) error {
+	received := 0
+	for {
+		reading, err := datagrams.ReceiveDatagram(ctx)
+		if err == io.EOF {
+			slog.InfoContext(ctx, "telemetry ended", "readings", received)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		received++
+		slog.InfoContext(ctx, "telemetry reading", "reading", string(reading), "capsules", datagrams.Capsules())
+		if err := datagrams.SendDatagram([]byte("ok " + strconv.Itoa(received))); err != nil {
+			return err
+		}
+	}
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.POST("/telemetry").
+		WithOptions(types.MethodOptions{
+			Datagram: types.DatagramOptions{ReceiveBufferSize: 256, MaxDatagramSize: 1200},
+		}).
+		ServeDatagrams(Telemetry)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+package main_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+func TestHttpDatagrams(t *testing.T) {
+	ctx := context.Background()
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	capsules := make(chan bool, 1)
+	s.POST("/telemetry").
+		WithOptions(types.MethodOptions{
+			AllowedOrigins: []string{"https://dashboard.example.com"},
+			Datagram:       types.DatagramOptions{MaxDatagramSize: 1200},
+		}).
+		ServeDatagrams(func(ctx context.Context, datagrams types.DatagramConn) error {
+			capsules <- datagrams.Capsules()
+			for {
+				reading, err := datagrams.ReceiveDatagram(ctx)
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if string(reading) == "fail" {
+					return errors.BadRequest.New("malformed reading")
+				}
+				if err := datagrams.SendDatagram(append([]byte("ack "), reading...)); err != nil {
+					return err
+				}
+			}
+		})
+
+	// the handler only reads once all the capsules were sent
+	release := make(chan struct{})
+	s.POST("/batch").
+		WithOptions(types.MethodOptions{
+			Datagram: types.DatagramOptions{ReceiveBufferSize: 1},
+		}).
+		ServeDatagrams(func(ctx context.Context, datagrams types.DatagramConn) error {
+			<-release
+			count := 0
+			for {
+				_, err := datagrams.ReceiveDatagram(ctx)
+				if err == io.EOF {
+					return datagrams.SendDatagram([]byte(fmt.Sprint(count)))
+				}
+				if err != nil {
+					return err
+				}
+				count++
+			}
+		})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("HTTP/3", func(t *testing.T) {
+		conn, err := quic.DialAddr(ctx, "localhost:4591", &tls.Config{InsecureSkipVerify: true, NextProtos: []string{http3.NextProtoH3}}, &quic.Config{EnableDatagrams: true})
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		defer conn.CloseWithError(0, "")
+
+		cc := (&http3.Transport{EnableDatagrams: true}).NewClientConn(conn)
+		select {
+		case <-cc.ReceivedSettings():
+		case <-time.After(time.Second):
+			t.Fatalf("expected server settings")
+		}
+		if !cc.Settings().EnableDatagrams {
+			t.Fatalf("expected the server to enable datagrams")
+		}
+
+		stream, err := cc.OpenRequestStream(ctx)
+		if err != nil {
+			t.Fatalf("failed to open stream: %v", err)
+		}
+		u, _ := url.Parse("https://localhost:4591/telemetry")
+		err = stream.SendRequestHeader(&http.Request{
+			Method: http.MethodPost,
+			URL:    u,
+			Host:   u.Host,
+			Header: http.Header{"Origin": {"https://dashboard.example.com"}},
+		})
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		resp, err := stream.ReadResponse()
+		if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Capsule-Protocol") != "" {
+			t.Fatalf("unexpected response %v: %v", resp, err)
+		}
+		if <-capsules {
+			t.Fatalf("expected QUIC datagrams rather than capsules")
+		}
+
+		// datagrams may be lost, retry until one round trips
+		var ack []byte
+		for i := 0; i < 10 && ack == nil; i++ {
+			if err := stream.SendDatagram([]byte("temperature 21.5")); err != nil {
+				t.Fatalf("failed to send datagram: %v", err)
+			}
+			receiveCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+			ack, _ = stream.ReceiveDatagram(receiveCtx)
+			cancel()
+		}
+		if string(ack) != "ack temperature 21.5" {
+			t.Fatalf("unexpected datagram %q", ack)
+		}
+
+		// closing the request stream ends the handler, and the response
+		_ = stream.Close()
+		done := make(chan error, 1)
+		go func() {
+			_, err := io.ReadAll(resp.Body)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("expected the response to end cleanly: %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected the response to end")
+		}
+	})
+
+	t.Run("capsules over HTTP/1.1", func(t *testing.T) {
+		body, requestWriter := io.Pipe()
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:4591/telemetry", body)
+		req.Header.Set("Origin", "https://dashboard.example.com")
+		req.Header.Set("Capsule-Protocol", "?1")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Capsule-Protocol") != "?1" {
+			t.Fatalf("unexpected response %v: %v", resp, err)
+		}
+		defer resp.Body.Close()
+		if !<-capsules {
+			t.Fatalf("expected capsules over HTTP/1.1")
+		}
+		responseReader := bufio.NewReader(resp.Body)
+
+		// capsules of unknown types are skipped
+		_, _ = requestWriter.Write(appendCapsule(nil, 0x2a, []byte("ignored")))
+		for _, reading := range []string{"humidity 40", "pressure 1013"} {
+			_, _ = requestWriter.Write(appendCapsule(nil, 0x00, []byte(reading)))
+			capsuleType, value, err := readCapsule(responseReader)
+			if err != nil || capsuleType != 0x00 || string(value) != "ack "+reading {
+				t.Fatalf("unexpected capsule %d %q: %v", capsuleType, value, err)
+			}
+		}
+
+		_ = requestWriter.Close()
+		if _, _, err := readCapsule(responseReader); err != io.EOF {
+			t.Fatalf("expected the response to end: %v", err)
+		}
+	})
+
+	t.Run("capsules are not dropped by a slow handler", func(t *testing.T) {
+		body, requestWriter := io.Pipe()
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:4591/batch", body)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected response %v: %v", resp, err)
+		}
+		defer resp.Body.Close()
+
+		for i := 0; i < 5; i++ {
+			_, _ = requestWriter.Write(appendCapsule(nil, 0x00, []byte(fmt.Sprint("reading ", i))))
+		}
+		_ = requestWriter.Close()
+		close(release)
+
+		_, value, err := readCapsule(bufio.NewReader(resp.Body))
+		if err != nil || string(value) != "5" {
+			t.Fatalf("expected all 5 datagrams to be received, got %q: %v", value, err)
+		}
+	})
+
+	t.Run("handler error aborts the response", func(t *testing.T) {
+		body, requestWriter := io.Pipe()
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:4591/telemetry", body)
+		req.Header.Set("Origin", "https://dashboard.example.com")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected response %v: %v", resp, err)
+		}
+		defer resp.Body.Close()
+		<-capsules
+
+		_, _ = requestWriter.Write(appendCapsule(nil, 0x00, []byte("fail")))
+		if _, err := io.ReadAll(resp.Body); err == nil {
+			t.Fatalf("expected the response to be aborted")
+		}
+		_ = requestWriter.Close()
+	})
+
+	t.Run("origin not allowed", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "http://localhost:4591/telemetry", strings.NewReader(""))
+		req.Header.Set("Origin", "https://evil.example.com")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil || resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected the origin to be rejected, got %v: %v", resp, err)
+		}
+		_ = resp.Body.Close()
+	})
+}
+
+func appendCapsule(b []byte, capsuleType uint64, value []byte) []byte {
+	b = quicvarint.Append(b, capsuleType)
+	b = quicvarint.Append(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func readCapsule(r *bufio.Reader) (uint64, []byte, error) {
+	capsuleType, err := quicvarint.Read(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	length, err := quicvarint.Read(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	value := make([]byte, length)
+	_, err = io.ReadFull(r, value)
+	return capsuleType, value, err
+}
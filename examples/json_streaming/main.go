@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+type Token struct {
+	Text string `json:"text"`
+}
+
+type User struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Completion streams tokens one JSON object per line, as LLM APIs do.
+func Completion(ctx context.Context, request interface{}, stream types.StreamWriter) error {
+	for _, word := range strings.Fields("the quick brown fox jumps over the lazy dog") {
+		time.Sleep(100 * time.Millisecond)
+
+		if err := stream.Send(Token{Text: word}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExportUsers streams all users as a single JSON array, without holding them
+// all in memory.
+func ExportUsers(ctx context.Context, request interface{}, stream types.StreamWriter) error {
+	for i := 1; i <= 1000; i++ {
+		if err := stream.Send(User{Id: i, Name: "user"}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.POST("/completions").ServeStream(Completion).
+		WithOptions(types.MethodOptions{
+			StreamingMode: types.StreamingModeNDJSON,
+		})
+
+	server.GET("/users/export").ServeStream(ExportUsers).
+		WithOptions(types.MethodOptions{
+			StreamingMode: types.StreamingModeJSONArray,
+		})
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+func TestHTTPServer_JSONStreaming(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4501"
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	items := func(n int) types.StreamingHandlerFunc {
+		return func(ctx context.Context, request interface{}, stream types.StreamWriter) error {
+			for i := 0; i < n; i++ {
+				if err := stream.Send(map[string]int{"i": i}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	s.GET("/ndjson").ServeStream(items(2)).
+		WithOptions(types.MethodOptions{StreamingMode: types.StreamingModeNDJSON})
+	s.GET("/jsonl").ServeStream(items(2)).
+		WithOptions(types.MethodOptions{StreamingMode: types.StreamingModeJSONLines})
+	s.GET("/array").ServeStream(items(3)).
+		WithOptions(types.MethodOptions{StreamingMode: types.StreamingModeJSONArray})
+	s.GET("/empty-array").ServeStream(items(0)).
+		WithOptions(types.MethodOptions{StreamingMode: types.StreamingModeJSONArray})
+	s.GET("/raw").ServeStream(func(ctx context.Context, request interface{}, stream types.StreamWriter) error {
+		if err := stream.Send([]byte("a,b\n")); err != nil {
+			return err
+		}
+		return stream.Send([]byte("1,2\n"))
+	}).WithOptions(types.MethodOptions{StreamingContentType: "text/csv"})
+	// strings are JSON values too, line breaks included
+	s.GET("/tokens").ServeStream(func(ctx context.Context, request interface{}, stream types.StreamWriter) error {
+		if err := stream.Send("tok"); err != nil {
+			return err
+		}
+		return stream.Send([]byte("two\nlines"))
+	}).WithOptions(types.MethodOptions{StreamingMode: types.StreamingModeNDJSON})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	tests := []struct {
+		path        string
+		contentType string
+		body        string
+	}{
+		{"/ndjson", "application/x-ndjson", "{\"i\":0}\n{\"i\":1}\n"},
+		{"/jsonl", "application/jsonl", "{\"i\":0}\n{\"i\":1}\n"},
+		{"/array", "application/json", "[{\"i\":0},{\"i\":1},{\"i\":2}]"},
+		{"/empty-array", "application/json", "[]"},
+		{"/raw", "text/csv", "a,b\n1,2\n"},
+		{"/tokens", "application/x-ndjson", "\"tok\"\n\"two\\nlines\"\n"},
+	}
+
+	for _, tt := range tests {
+		resp, err := http.Get(fmt.Sprintf("http://%s%s", addr, tt.path))
+		if err != nil {
+			t.Fatalf("%s: failed to make request: %v", tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if got := resp.Header.Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: expected content type %q, got %q", tt.path, tt.contentType, got)
+		}
+		if string(body) != tt.body {
+			t.Errorf("%s: expected body %q, got %q", tt.path, tt.body, body)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+)
+
+func Hello(ctx context.Context, request interface{}) (interface{}, error) {
+	return map[string]string{"hello": "world"}, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.GET("/hello").Serve(Hello)
+
+	// HTTP/1.1 is served on the Unix socket set at
+	// service.http.h1.address.unix
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
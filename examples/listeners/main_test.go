@@ -0,0 +1,227 @@
+package main_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func hello(ctx context.Context, request interface{}) (interface{}, error) {
+	return map[string]string{"hello": "world"}, nil
+}
+
+var (
+	h3Client  = &http.Client{Transport: &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	tlsClient = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+)
+
+func get(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("request to %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "world") {
+		t.Fatalf("unexpected response from %s %d: %s", url, resp.StatusCode, body)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	ctx := context.Background()
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+	s.GET("/hello").Serve(hello)
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	addrs := s.Addrs()
+	if addrs.H1 == nil || addrs.H1.Network() != "unix" || addrs.H1.String() != "crazyhttp.sock" {
+		t.Fatalf("expected HTTP/1.1 on the Unix socket, got %v", addrs.H1)
+	}
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", "crazyhttp.sock")
+		},
+	}}
+	get(t, unixClient, "http://localhost/hello")
+	get(t, h3Client, "https://localhost:4651/hello")
+	get(t, tlsClient, "https://localhost:4650/hello")
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if _, err := os.Stat("crazyhttp.sock"); !os.IsNotExist(err) {
+		t.Fatalf("expected the socket to be removed on shutdown: %v", err)
+	}
+}
+
+func TestListeners(t *testing.T) {
+	ctx := context.Background()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	h1, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	h1TLS, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+	s.GET("/hello").Serve(hello)
+	s.WithListeners(types.Listeners{H3: conn, H1: h1, H1TLS: h1TLS})
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	defer s.Shutdown(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	addrs := s.Addrs()
+	if addrs.H3 != conn.LocalAddr() || addrs.H1 != h1.Addr() || addrs.H1TLS != h1TLS.Addr() {
+		t.Fatalf("expected the addresses of the listeners, got %+v", addrs)
+	}
+
+	get(t, h3Client, "https://"+addrs.H3.String()+"/hello")
+	get(t, http.DefaultClient, "http://"+addrs.H1.String()+"/hello")
+	get(t, tlsClient, "https://"+addrs.H1TLS.String()+"/hello")
+
+	// the HTTP/3 port bound is advertised
+	resp, err := http.Get("http://" + addrs.H1.String() + "/hello")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if want := fmt.Sprintf(`h3=":%d"`, addrs.H3.(*net.UDPAddr).Port); !strings.HasPrefix(resp.Header.Get("Alt-Svc"), want) {
+		t.Fatalf("expected Alt-Svc %s, got %q", want, resp.Header.Get("Alt-Svc"))
+	}
+}
+
+const systemdConfig = `service:
+  http:
+    h3:
+      enabled: true
+      address:
+        systemd: quic
+    h1:
+      enabled: true
+      address:
+        systemd: http
+    h1_ssl:
+      enabled: false
+  tls:
+    generate_if_missing: true
+    certificate:
+      raw: ""
+      path: cert.pem
+    key:
+      raw: ""
+      path: key.pem
+`
+
+// TestSystemdSocketActivation runs the test binary as a socket-activated
+// service, passing it sockets the way systemd does.
+func TestSystemdSocketActivation(t *testing.T) {
+	if os.Getenv("LISTEN_FDS") != "" {
+		serveActivated(t)
+		return
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer conn.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer l.Close()
+
+	lf, _ := l.(*net.TCPListener).File()
+	cf, _ := conn.(*net.UDPConn).File()
+	defer lf.Close()
+	defer cf.Close()
+
+	dir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(dir, "configs"), 0o755)
+	if err := os.WriteFile(filepath.Join(dir, "configs", "config.yaml"), []byte(systemdConfig), 0o644); err != nil {
+		t.Fatalf("failed to write the config: %v", err)
+	}
+
+	exe, _ := os.Executable()
+	cmd := exec.Command(exe, "-test.run=^TestSystemdSocketActivation$")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "LISTEN_FDS=2", "LISTEN_FDNAMES=http:quic")
+	cmd.ExtraFiles = []*os.File{lf, cf}
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start the service: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	var lastErr error
+	for i := 0; i < 50; i++ {
+		time.Sleep(100 * time.Millisecond)
+		var resp *http.Response
+		if resp, lastErr = (&http.Client{Timeout: time.Second}).Get("http://" + l.Addr().String() + "/hello"); lastErr == nil {
+			_ = resp.Body.Close()
+			break
+		}
+	}
+	if lastErr != nil {
+		t.Fatalf("the service did not serve its socket: %v", lastErr)
+	}
+	get(t, http.DefaultClient, "http://"+l.Addr().String()+"/hello")
+	get(t, h3Client, "https://"+conn.LocalAddr().String()+"/hello")
+}
+
+// serveActivated serves on the sockets passed by the parent test, until
+// killed.
+func serveActivated(t *testing.T) {
+	// systemd sets the PID of the service it starts
+	_ = os.Setenv("LISTEN_PID", fmt.Sprint(os.Getpid()))
+
+	ctx := context.Background()
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+	s.GET("/hello").Serve(hello)
+	if err := s.ListenAndServe(ctx); err != nil {
+		t.Fatalf("server failed: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// Ping replies to health checks.
+func Ping(ctx context.Context, request interface{}) (interface{}, error) {
+	return map[string]string{"status": "ok"}, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	// the transport is read from service.http.h3.quic, these options replace
+	// it for a server behind a load balancer keeping connections open
+	server.WithQUICOptions(types.QUICOptions{
+		MaxIdleTimeout:             5 * time.Minute,
+		KeepAlivePeriod:            time.Minute,
+		MaxIncomingStreams:         500,
+		MaxStreamReceiveWindow:     16 << 20,
+		MaxConnectionReceiveWindow: 64 << 20,
+	})
+
+	server.GET("/ping").Serve(Ping)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package main_test
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// openStreams opens bidirectional streams until the server's limit is reached,
+// returning how many were opened.
+func openStreams(t *testing.T, ctx context.Context) int {
+	conn, err := quic.DialAddr(ctx, "localhost:4611", &tls.Config{InsecureSkipVerify: true, NextProtos: []string{http3.NextProtoH3}}, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.CloseWithError(0, "")
+
+	if conn.ConnectionState().Used0RTT {
+		t.Fatalf("expected no 0-RTT")
+	}
+
+	opened := 0
+	for ; opened < 10; opened++ {
+		if _, err := conn.OpenStream(); err != nil {
+			break
+		}
+	}
+	return opened
+}
+
+func TestQUICTransport(t *testing.T) {
+	ctx := context.Background()
+	defer os.RemoveAll("qlogs")
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// options are read from the config
+	if opened := openStreams(t, ctx); opened != 2 {
+		t.Fatalf("expected the stream limit of the config, opened %d streams", opened)
+	}
+
+	// traces are flushed once connections are closed
+	var traces []string
+	for i := 0; i < 20 && len(traces) == 0; i++ {
+		time.Sleep(50 * time.Millisecond)
+		traces, _ = filepath.Glob("qlogs/*_server.sqlog")
+	}
+	if len(traces) == 0 {
+		t.Fatalf("expected a qlog trace")
+	}
+	if info, err := os.Stat(traces[0]); err != nil || info.Size() == 0 {
+		t.Fatalf("expected a non-empty qlog trace: %v", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	// options set programmatically replace the config
+	s = crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+	s.WithQUICOptions(types.QUICOptions{MaxIncomingStreams: 1})
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	defer s.Shutdown(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	if opened := openStreams(t, ctx); opened != 1 {
+		t.Fatalf("expected the stream limit of the options, opened %d streams", opened)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+type Measurement struct {
+	Sensor string  `json:"sensor"`
+	Value  float64 `json:"value"`
+}
+
+type Summary struct {
+	Count int     `json:"count"`
+	Sum   float64 `json:"sum"`
+}
+
+// Ingest aggregates measurements uploaded as newline delimited JSON, without
+// buffering the whole upload.
+func Ingest(ctx context.Context, request types.RequestStream) (interface{}, error) {
+	var summary Summary
+	for {
+		item, err := request.Recv()
+		if err == io.EOF {
+			return summary, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		measurement, err := crazyserver.DecodeJsonRequest[Measurement](item)
+		if err != nil {
+			return nil, err
+		}
+		summary.Count++
+		summary.Sum += measurement.Value
+	}
+}
+
+// Shout echoes each line sent by the client in upper case, as it is received.
+func Shout(ctx context.Context, request types.RequestStream, stream types.StreamWriter) error {
+	for {
+		item, err := request.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := item.(string)
+		if err := stream.Send(strings.ToUpper(line)); err != nil {
+			return err
+		}
+	}
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.POST("/measurements").ServeClientStream(Ingest).
+		WithOptions(types.MethodOptions{
+			RequestStream: types.RequestStreamOptions{Mode: types.RequestStreamingModeNDJSON},
+		})
+
+	server.POST("/shout").ServeBidiStream(Shout).
+		WithOptions(types.MethodOptions{
+			StreamingMode: types.StreamingModeNDJSON,
+			RequestStream: types.RequestStreamOptions{Mode: types.RequestStreamingModeNDJSON},
+		})
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
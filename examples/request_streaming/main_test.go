@@ -0,0 +1,211 @@
+package main_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+func TestHTTPServer_RequestStreaming(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4511"
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	s.POST("/sum").ServeClientStream(func(ctx context.Context, request types.RequestStream) (interface{}, error) {
+		sum := 0.0
+		for {
+			item, err := request.Recv()
+			if err == io.EOF {
+				return map[string]float64{"sum": sum}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			sum += item.(float64)
+		}
+	}).WithOptions(types.MethodOptions{
+		RequestStream: types.RequestStreamOptions{Mode: types.RequestStreamingModeNDJSON},
+	})
+
+	s.POST("/size").ServeClientStream(func(ctx context.Context, request types.RequestStream) (interface{}, error) {
+		size, chunks := 0, 0
+		for {
+			item, err := request.Recv()
+			if err == io.EOF {
+				return map[string]int{"size": size, "max_chunk": chunks}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if n := len(item.([]byte)); n > chunks {
+				chunks = n
+			}
+			size += len(item.([]byte))
+		}
+	}).WithOptions(types.MethodOptions{
+		RequestStream: types.RequestStreamOptions{ChunkSize: 4},
+	})
+
+	s.POST("/parts").ServeClientStream(func(ctx context.Context, request types.RequestStream) (interface{}, error) {
+		var names []string
+		for {
+			item, err := request.Recv()
+			if err == io.EOF {
+				return strings.Join(names, ","), nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			part := item.(*multipart.Part)
+			content, _ := io.ReadAll(part)
+			names = append(names, part.FormName()+"="+string(content))
+		}
+	}).WithOptions(types.MethodOptions{
+		RequestStream: types.RequestStreamOptions{Mode: types.RequestStreamingModeMultipart},
+	})
+
+	s.POST("/echo").ServeBidiStream(func(ctx context.Context, request types.RequestStream, stream types.StreamWriter) error {
+		for {
+			item, err := request.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(item); err != nil {
+				return err
+			}
+		}
+	}).WithOptions(types.MethodOptions{
+		StreamingMode: types.StreamingModeNDJSON,
+		RequestStream: types.RequestStreamOptions{Mode: types.RequestStreamingModeNDJSON},
+	})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	t.Run("ndjson", func(t *testing.T) {
+		resp, err := http.Post(fmt.Sprintf("http://%s/sum", addr), "application/x-ndjson", strings.NewReader("1\n2.5\n3\n"))
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != `{"sum":6.5}` {
+			t.Fatalf("expected sum 6.5, got %s", body)
+		}
+
+		resp, err = http.Post(fmt.Sprintf("http://%s/sum", addr), "application/x-ndjson", strings.NewReader("1\n{oops\n"))
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected status %d for malformed body, got %d", http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		resp, err := http.Post(fmt.Sprintf("http://%s/size", addr), "application/octet-stream", strings.NewReader("0123456789"))
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != `{"max_chunk":4,"size":10}` {
+			t.Fatalf("unexpected raw stream summary: %s", body)
+		}
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		var b bytes.Buffer
+		mw := multipart.NewWriter(&b)
+		_ = mw.WriteField("first", "a")
+		_ = mw.WriteField("second", "b")
+		mw.Close()
+
+		resp, err := http.Post(fmt.Sprintf("http://%s/parts", addr), mw.FormDataContentType(), &b)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != "first=a,second=b" {
+			t.Fatalf("unexpected parts: %s", body)
+		}
+
+		resp, err = http.Post(fmt.Sprintf("http://%s/parts", addr), "text/plain", strings.NewReader("x"))
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusUnsupportedMediaType {
+			t.Fatalf("expected status %d, got %d", http.StatusUnsupportedMediaType, resp.StatusCode)
+		}
+	})
+
+	t.Run("bidi", func(t *testing.T) {
+		// each item is only uploaded once the previous one is echoed
+		pr, pw := io.Pipe()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/echo", addr), pr)
+
+		go func() {
+			_, _ = pw.Write([]byte("\"ping\"\n"))
+		}()
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var echoes []string
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			lines := bufio.NewScanner(resp.Body)
+			for lines.Scan() {
+				echoes = append(echoes, lines.Text())
+				if len(echoes) == 1 {
+					_, _ = pw.Write([]byte("\"pong\"\n"))
+				} else {
+					pw.Close()
+				}
+			}
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for echoes")
+		}
+
+		// echoed strings are encoded as JSON again
+		if strings.Join(echoes, ",") != `"ping","pong"` {
+			t.Fatalf("unexpected echoes: %q", echoes)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+func Notifications(ctx context.Context, request interface{}) (response interface{}, err error) {
+	channel := ctx.Value(constants.StreamingResponseChannelContextKey).(chan types.StreamChunk)
+
+	// resume after the last event received by the client, if reconnecting
+	start := uint64(0)
+	if lastEventId, ok := crazyserver.LastEventId(ctx); ok {
+		start, _ = strconv.ParseUint(lastEventId, 10, 32)
+	}
+
+	for i := start + 1; i <= start+5; i++ {
+		time.Sleep(time.Second)
+
+		channel <- types.StreamChunk{
+			Id:    uint32(i),
+			Event: "notification",
+			Data:  []byte(fmt.Sprintf("notification %d", i)),
+		}
+	}
+
+	return nil, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.GET("/notifications").Serve(Notifications).
+		WithOptions(types.MethodOptions{
+			IsStreamingResponse: true,
+			StreamingMode:       types.StreamingModeSSE,
+			SSE: types.SSEOptions{
+				Retry:             3 * time.Second,
+				HeartbeatInterval: 15 * time.Second,
+			},
+		})
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+func TestHTTPServer_ServerSentEvents(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4481"
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	s.GET("/events").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		// events are only produced once, reconnecting clients get them replayed
+		if _, ok := crazyserver.LastEventId(ctx); ok {
+			return nil, nil
+		}
+
+		channel := ctx.Value(constants.StreamingResponseChannelContextKey).(chan types.StreamChunk)
+		channel <- types.StreamChunk{Id: 1, Event: "greeting", Data: []byte("hello\nworld")}
+		channel <- types.StreamChunk{Id: 2, Data: []byte("second")}
+		channel <- types.StreamChunk{Id: 3, Data: []byte("third")}
+		return nil, nil
+	}).WithOptions(types.MethodOptions{
+		IsStreamingResponse: true,
+		StreamingMode:       types.StreamingModeSSE,
+		SSE: types.SSEOptions{
+			Retry:            time.Second,
+			ReplayBufferSize: 2,
+		},
+	})
+
+	// one stream per room, only the last room written to is kept
+	s.GET("/rooms").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		if _, ok := crazyserver.LastEventId(ctx); ok {
+			return nil, nil
+		}
+
+		channel := ctx.Value(constants.StreamingResponseChannelContextKey).(chan types.StreamChunk)
+		channel <- types.StreamChunk{Id: 1, Data: []byte("welcome")}
+		return nil, nil
+	}).WithOptions(types.MethodOptions{
+		IsStreamingResponse: true,
+		StreamingMode:       types.StreamingModeSSE,
+		SSE: types.SSEOptions{
+			ReplayBufferSize: 2,
+			ReplayKey:        func(r *http.Request) string { return r.URL.Query().Get("room") },
+			ReplayMaxStreams: 1,
+		},
+	})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/events", addr))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	expected := "retry: 1000\n\n" +
+		"event: greeting\nid: 1\ndata: hello\ndata: world\n\n" +
+		"id: 2\ndata: second\n\n" +
+		"id: 3\ndata: third\n\n"
+	if string(body) != expected {
+		t.Fatalf("expected event stream:\n%q\ngot:\n%q", expected, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/events", addr), nil)
+	req.Header.Set("Last-Event-ID", "2")
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	expected = "retry: 1000\n\nid: 3\ndata: third\n\n"
+	if string(body) != expected {
+		t.Fatalf("expected replayed event stream:\n%q\ngot:\n%q", expected, body)
+	}
+
+	get := func(room, lastEventId string) string {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/rooms?room=%s", addr, room), nil)
+		if lastEventId != "" {
+			req.Header.Set("Last-Event-ID", lastEventId)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to make request: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	get("a", "")
+	get("b", "")
+	if body := get("a", "0"); body != "" {
+		t.Fatalf("expected the stream of room a to be dropped, got %q", body)
+	}
+	if body := get("b", "0"); body != "id: 1\ndata: welcome\n\n" {
+		t.Fatalf("expected the stream of room b to be replayed, got %q", body)
+	}
+}
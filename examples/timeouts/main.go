@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+)
+
+func SlowReport(ctx context.Context, request interface{}) (response interface{}, err error) {
+	select {
+	case <-time.After(5 * time.Second):
+		return "report ready", nil
+	case <-ctx.Done():
+		// the client receives a 504 Gateway Timeout
+		return nil, ctx.Err()
+	}
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.GET("/report").
+		WithTimeout(2 * time.Second).
+		Serve(SlowReport)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
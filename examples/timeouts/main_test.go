@@ -0,0 +1,97 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+)
+
+func TestUserRoute_HandlerTimeout(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4461"
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	// ignores the context, and would block for long without a timeout
+	server.GET("/slow").
+		WithTimeout(100 * time.Millisecond).
+		Serve(func(ctx context.Context, req interface{}) (interface{}, error) {
+			time.Sleep(2 * time.Second)
+			return "done", nil
+		})
+
+	// honours the context deadline
+	server.GET("/slow-with-context").
+		WithTimeout(2 * time.Second).
+		Serve(func(ctx context.Context, req interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+	// keeps setting headers after its deadline
+	server.GET("/slow-headers").
+		WithTimeout(100 * time.Millisecond).
+		Serve(func(ctx context.Context, req interface{}) (interface{}, error) {
+			for i := 0; i < 20; i++ {
+				crazyserver.ResponseHeaders(ctx).Set("X-Attempt", fmt.Sprint(i))
+				time.Sleep(10 * time.Millisecond)
+			}
+			return "done", nil
+		})
+
+	go func() {
+		_ = server.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	resp, err := http.Get(fmt.Sprintf("http://%s/slow", addr))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("Expected 504 Gateway Timeout, got %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected response after the route timeout, took %v", elapsed)
+	}
+
+	// the timeout requested by the client is shorter than the route's
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/slow-with-context", addr), nil)
+	req.Header.Set("Grpc-Timeout", "100m")
+
+	start = time.Now()
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("Expected 504 Gateway Timeout, got %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected response after the requested timeout, took %v", elapsed)
+	}
+
+	// headers set by an abandoned handler are not sent, nor race with the
+	// error response
+	resp, err = http.Get(fmt.Sprintf("http://%s/slow-headers", addr))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusGatewayTimeout || resp.Header.Get("X-Attempt") != "" {
+		t.Errorf("Expected 504 Gateway Timeout without handler headers, got %d %v", resp.StatusCode, resp.Header)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// Authenticate rejects requests without a bearer token.
+func Authenticate(ctx context.Context, request interface{}) (context.Context, interface{}, error) {
+	headers := ctx.Value(constants.HttpRequestHeaders).(http.Header)
+	if headers.Get("Authorization") == "" {
+		return ctx, request, errors.Unauthorized.New("missing credentials")
+	}
+
+	return ctx, request, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	store, err := crazyserver.NewTusFileStore("uploads")
+	if err != nil {
+		log.Fatalf("Failed to create upload store: %v", err)
+	}
+
+	server.Tus("/videos", store, types.TusOptions{
+		MaxSize:    4 << 30,
+		Expiration: 24 * time.Hour,
+		OnComplete: func(ctx context.Context, upload types.TusUpload) error {
+			slog.InfoContext(ctx, "video uploaded", "id", upload.Id, "filename", upload.Metadata["filename"])
+			return nil
+		},
+	}).WithBeforeServe(Authenticate)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
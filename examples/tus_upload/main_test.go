@@ -0,0 +1,254 @@
+package main_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+func TestHTTPServer_TusUpload(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4531"
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	store, err := crazyserver.NewTusFileStore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	completed := make(chan types.TusUpload, 2)
+	s.Tus("/files", store, types.TusOptions{
+		MaxSize: 64,
+		OnComplete: func(ctx context.Context, upload types.TusUpload) error {
+			completed <- upload
+			return nil
+		},
+	}).WithBeforeServe(func(ctx context.Context, request interface{}) (context.Context, interface{}, error) {
+		if ctx.Value(constants.HttpRequestHeaders).(http.Header).Get("Authorization") == "" {
+			return ctx, request, errors.Unauthorized.New("missing credentials")
+		}
+		return ctx, request, nil
+	})
+
+	expiringDir := t.TempDir()
+	expiringStore, err := crazyserver.NewTusFileStore(expiringDir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	s.Tus("/expiring", expiringStore, types.TusOptions{
+		Expiration:    100 * time.Millisecond,
+		SweepInterval: 50 * time.Millisecond,
+	})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	do := func(method, path string, headers map[string]string, body string) *http.Response {
+		req, _ := http.NewRequest(method, fmt.Sprintf("http://%s%s", addr, path), strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		for key, value := range headers {
+			if value == "" {
+				req.Header.Del(key)
+				continue
+			}
+			req.Header.Set(key, value)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: failed to make request: %v", method, path, err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+	patch := func(path string, offset int, body string, headers map[string]string) *http.Response {
+		h := map[string]string{
+			"Content-Type":  "application/offset+octet-stream",
+			"Upload-Offset": fmt.Sprint(offset),
+		}
+		for key, value := range headers {
+			h[key] = value
+		}
+		return do(http.MethodPatch, path, h, body)
+	}
+	expectStatus := func(resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Fatalf("%s %s: expected status %d, got %d", resp.Request.Method, resp.Request.URL.Path, status, resp.StatusCode)
+		}
+		if resp.Header.Get("Tus-Resumable") != "1.0.0" {
+			t.Fatalf("%s %s: expected Tus-Resumable header", resp.Request.Method, resp.Request.URL.Path)
+		}
+	}
+
+	resp := do(http.MethodOptions, "/files", nil, "")
+	expectStatus(resp, http.StatusNoContent)
+	if !strings.Contains(resp.Header.Get("Tus-Extension"), "checksum") || resp.Header.Get("Tus-Max-Size") != "64" {
+		t.Fatalf("unexpected capabilities: %v", resp.Header)
+	}
+
+	expectStatus(do(http.MethodPost, "/files", map[string]string{"Upload-Length": "11", "Tus-Resumable": "0.2.2"}, ""), http.StatusPreconditionFailed)
+	expectStatus(do(http.MethodPost, "/files", map[string]string{"Upload-Length": "65"}, ""), http.StatusRequestEntityTooLarge)
+	if resp := do(http.MethodPost, "/files", map[string]string{"Upload-Length": "11", "Authorization": ""}, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected status %d without credentials, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	resp = do(http.MethodPost, "/files", map[string]string{
+		"Upload-Length":   "11",
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("hello.txt")),
+	}, "")
+	expectStatus(resp, http.StatusCreated)
+	location := resp.Header.Get("Location")
+	if !strings.HasPrefix(location, "/files/") {
+		t.Fatalf("unexpected upload location %q", location)
+	}
+
+	expectStatus(patch(location, 0, "hello", nil), http.StatusNoContent)
+
+	resp = do(http.MethodHead, location, nil, "")
+	expectStatus(resp, http.StatusOK)
+	if resp.Header.Get("Upload-Offset") != "5" || resp.Header.Get("Upload-Length") != "11" {
+		t.Fatalf("unexpected upload state: %v", resp.Header)
+	}
+
+	expectStatus(patch(location, 3, " world", nil), http.StatusConflict)
+
+	expectStatus(patch(location, 5, " world", map[string]string{"Upload-Checksum": "sha1 " + base64.StdEncoding.EncodeToString([]byte("wrong"))}), 460)
+
+	sum := sha1.Sum([]byte(" world"))
+	resp = patch(location, 5, " world", map[string]string{"Upload-Checksum": "sha1 " + base64.StdEncoding.EncodeToString(sum[:])})
+	expectStatus(resp, http.StatusNoContent)
+	if resp.Header.Get("Upload-Offset") != "11" {
+		t.Fatalf("expected offset 11, got %q", resp.Header.Get("Upload-Offset"))
+	}
+
+	select {
+	case upload := <-completed:
+		if upload.Metadata["filename"] != "hello.txt" {
+			t.Fatalf("unexpected metadata %v", upload.Metadata)
+		}
+		data, _ := os.ReadFile(filepath.Join(dir, upload.Id))
+		if string(data) != "hello world" {
+			t.Fatalf("unexpected upload content %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected upload completion hook to be called")
+	}
+
+	// retrying the last PATCH does not complete the upload again
+	expectStatus(patch(location, 11, "", nil), http.StatusNoContent)
+	select {
+	case upload := <-completed:
+		t.Fatalf("unexpected second completion of %s", upload.Id)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	expectStatus(do(http.MethodDelete, location, nil, ""), http.StatusNoContent)
+	expectStatus(do(http.MethodHead, location, nil, ""), http.StatusNotFound)
+
+	// empty uploads are complete once created
+	resp = do(http.MethodPost, "/files", map[string]string{"Upload-Length": "0"}, "")
+	expectStatus(resp, http.StatusCreated)
+	select {
+	case upload := <-completed:
+		if "/files/"+upload.Id != resp.Header.Get("Location") {
+			t.Fatalf("unexpected completed upload %s", upload.Id)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected completion hook to be called for an empty upload")
+	}
+
+	// abandoned uploads are discarded without being requested again
+	expectStatus(do(http.MethodPost, "/expiring", map[string]string{"Upload-Length": "5"}, ""), http.StatusCreated)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		entries, _ := os.ReadDir(expiringDir)
+		if len(entries) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected expired upload to be swept, found %d files", len(entries))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestTusFileStore(t *testing.T) {
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	store, err := crazyserver.NewTusFileStore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	if err := store.Create(ctx, types.TusUpload{Id: "../escape", Size: 1}); errors.TypeOf(err) != errors.BadRequest {
+		t.Fatalf("expected BadRequest for an invalid id, got %v", err)
+	}
+	if _, err := store.Get(ctx, "missing"); errors.TypeOf(err) != errors.NotFound {
+		t.Fatalf("expected NotFound for a missing upload, got %v", err)
+	}
+
+	upload := types.TusUpload{Id: "upload-1", Size: 10, Metadata: map[string]string{"filename": "a.txt"}}
+	if err := store.Create(ctx, upload); err != nil {
+		t.Fatalf("failed to create upload: %v", err)
+	}
+	if err := store.Create(ctx, upload); err == nil {
+		t.Fatalf("expected an error creating an existing upload")
+	}
+
+	if n, err := store.Append(ctx, upload.Id, 0, strings.NewReader("hello")); err != nil || n != 5 {
+		t.Fatalf("expected 5 bytes appended, got %d, %v", n, err)
+	}
+	if _, err := store.Append(ctx, upload.Id, 2, strings.NewReader("x")); errors.TypeOf(err) != errors.Conflict {
+		t.Fatalf("expected Conflict appending at a stale offset, got %v", err)
+	}
+	if _, err := store.Append(ctx, "missing", 0, strings.NewReader("x")); errors.TypeOf(err) != errors.NotFound {
+		t.Fatalf("expected NotFound appending to a missing upload, got %v", err)
+	}
+
+	got, err := store.Get(ctx, upload.Id)
+	if err != nil {
+		t.Fatalf("failed to get upload: %v", err)
+	}
+	if got.Offset != 5 || got.Size != 10 || got.Metadata["filename"] != "a.txt" {
+		t.Fatalf("unexpected upload %+v", got)
+	}
+
+	ids, err := store.List(ctx)
+	if err != nil || len(ids) != 1 || ids[0] != upload.Id {
+		t.Fatalf("expected [%s], got %v, %v", upload.Id, ids, err)
+	}
+
+	if err := store.Delete(ctx, upload.Id); err != nil {
+		t.Fatalf("failed to delete upload: %v", err)
+	}
+	if err := store.Delete(ctx, upload.Id); errors.TypeOf(err) != errors.NotFound {
+		t.Fatalf("expected NotFound deleting twice, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected no files left, found %d", len(entries))
+	}
+}
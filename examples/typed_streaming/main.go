@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+type Progress struct {
+	Step  int `json:"step"`
+	Total int `json:"total"`
+}
+
+func Progresses(ctx context.Context, request interface{}, stream types.StreamWriter) error {
+	// status and headers can be set until the first chunk is sent
+	crazyserver.SetStatusCode(ctx, http.StatusAccepted)
+	if err := stream.SetHeader("X-Total-Steps", "5"); err != nil {
+		return err
+	}
+
+	for i := 1; i <= 5; i++ {
+		select {
+		case <-stream.Done():
+			return nil
+		case <-time.After(time.Second):
+		}
+
+		// blocks until the chunk is written to the client
+		if err := stream.Send(Progress{Step: i, Total: 5}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.GET("/progress").ServeStream(Progresses).
+		WithOptions(types.MethodOptions{
+			StreamingMode: types.StreamingModeNDJSON,
+		})
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+func TestHTTPServer_TypedStreaming(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4491"
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	s.GET("/stream").ServeStream(func(ctx context.Context, request interface{}, stream types.StreamWriter) error {
+		crazyserver.SetStatusCode(ctx, http.StatusAccepted)
+		if err := stream.SetHeader("Content-Type", "application/x-ndjson"); err != nil {
+			return err
+		}
+
+		for i := 0; i < 3; i++ {
+			if err := stream.Send(map[string]int{"i": i}); err != nil {
+				return err
+			}
+			if err := stream.Send("\n"); err != nil {
+				return err
+			}
+		}
+
+		if err := stream.SetHeader("X-Late", "1"); err == nil {
+			return fmt.Errorf("expected headers to be sent after the first chunk")
+		}
+		return nil
+	})
+	s.GET("/fails").ServeStream(func(ctx context.Context, request interface{}, stream types.StreamWriter) error {
+		return errors.NotFound.New("no such stream")
+	})
+	s.GET("/events").ServeStream(func(ctx context.Context, request interface{}, stream types.StreamWriter) error {
+		return stream.SendEvent(types.StreamChunk{Id: 7, Event: "tick", Data: []byte("tock")})
+	}).WithOptions(types.MethodOptions{StreamingMode: types.StreamingModeSSE})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/stream", addr))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/x-ndjson" {
+		t.Fatalf("expected content type application/x-ndjson, got %q", got)
+	}
+	if resp.Header.Get("X-Late") != "" {
+		t.Fatalf("expected header set after the first chunk to be dropped")
+	}
+	expected := "{\"i\":0}\n{\"i\":1}\n{\"i\":2}\n"
+	if string(body) != expected {
+		t.Fatalf("expected body %q, got %q", expected, body)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/fails", addr))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/events", addr))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	expected = "event: tick\nid: 7\ndata: tock\n\n"
+	if string(body) != expected {
+		t.Fatalf("expected event stream %q, got %q", expected, body)
+	}
+}
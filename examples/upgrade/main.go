@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+)
+
+func Version(ctx context.Context, request interface{}) (interface{}, error) {
+	return map[string]int{"pid": os.Getpid()}, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.GET("/version").Serve(Version)
+
+	// replace the binary, then `kill -HUP <pid>` to upgrade without refusing
+	// connections
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+	log.Printf("Upgraded, process %d exits", os.Getpid())
+}
@@ -0,0 +1,135 @@
+package main_test
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func version(ctx context.Context, request interface{}) (interface{}, error) {
+	return map[string]int{"pid": os.Getpid()}, nil
+}
+
+func slow(ctx context.Context, request interface{}) (interface{}, error) {
+	time.Sleep(time.Second)
+	return map[string]int{"pid": os.Getpid()}, nil
+}
+
+// pid returns the process serving url.
+func pid(t *testing.T, client *http.Client, url string) int {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("request to %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	var body map[string]int
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected response from %s %d: %v", url, resp.StatusCode, err)
+	}
+	return body["pid"]
+}
+
+func TestUpgrade(t *testing.T) {
+	ctx := context.Background()
+
+	// the test binary is started again as the new process, which serves
+	// until killed
+	upgraded := os.Getenv("CRAZYHTTP_INHERITED_FDS") != ""
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+	s.GET("/version").Serve(version)
+	s.GET("/slow").Serve(slow)
+
+	if upgraded {
+		if err := s.ListenAndServe(ctx); err != nil {
+			t.Fatalf("server failed: %v", err)
+		}
+		return
+	}
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- s.ListenAndServe(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	if got := pid(t, http.DefaultClient, "http://localhost:4661/version"); got != os.Getpid() {
+		t.Fatalf("expected this process to serve, got %d", got)
+	}
+
+	// a request in flight is completed by this process, still advertising
+	// HTTP/3 as the new process serves it
+	type response struct {
+		pid    int
+		altSvc string
+	}
+	inFlight := make(chan response, 1)
+	go func() {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get("https://localhost:4660/slow")
+		if err != nil {
+			inFlight <- response{}
+			return
+		}
+		defer resp.Body.Close()
+		var body map[string]int
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		inFlight <- response{pid: body["pid"], altSvc: resp.Header.Get("Alt-Svc")}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	self, _ := os.FindProcess(os.Getpid())
+	if err := self.Signal(syscall.SIGHUP); err != nil {
+		t.Fatalf("failed to signal: %v", err)
+	}
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("expected the server to stop after the upgrade, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the server did not upgrade")
+	}
+	got := <-inFlight
+	if got.pid != os.Getpid() {
+		t.Fatalf("expected the request in flight to complete, got %d", got.pid)
+	}
+	if !strings.HasPrefix(got.altSvc, `h3=":4661"`) {
+		t.Fatalf("expected HTTP/3 to be advertised during the upgrade, got %q", got.altSvc)
+	}
+
+	// the new process serves all sockets
+	child := pid(t, &http.Client{}, "http://localhost:4661/version")
+	if child == os.Getpid() {
+		t.Fatalf("expected the new process to serve")
+	}
+	defer func() {
+		if p, err := os.FindProcess(child); err == nil {
+			_ = p.Kill()
+		}
+	}()
+
+	h3Client := &http.Client{Transport: &http3.Transport{TLSClientConfig: tlsConfig}}
+	if got := pid(t, h3Client, "https://localhost:4661/version"); got != child {
+		t.Fatalf("expected the new process to serve HTTP/3, got %d", got)
+	}
+	tlsClient := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	if got := pid(t, tlsClient, "https://localhost:4660/version"); got != child {
+		t.Fatalf("expected the new process to serve HTTPS, got %d", got)
+	}
+}
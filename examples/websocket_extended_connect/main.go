@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// Echo replies to every message with the message itself.
+func Echo(ctx context.Context, request interface{}) (interface{}, error) {
+	return request, nil
+}
+
+// Browsers open WebSockets over HTTP/3 when the server enables extended
+// CONNECT, and fall back to HTTP/1.1 otherwise: the same endpoint serves both.
+// Run with GODEBUG=http2xconnect=1 to serve them over HTTP/2 too.
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.WebSocket("/echo").
+		WithOptions(types.WebSocketOption{Subprotocols: []string{"echo.v1"}}).
+		ServeMessage(Echo)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
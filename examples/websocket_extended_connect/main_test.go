@@ -0,0 +1,221 @@
+package main_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	gws "github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// TestMain reruns the tests with extended CONNECT enabled on the HTTP/2
+// server, which net/http only reads from the environment.
+func TestMain(m *testing.M) {
+	if !strings.Contains(os.Getenv("GODEBUG"), "http2xconnect=1") {
+		cmd := exec.Command(os.Args[0], os.Args[1:]...)
+		cmd.Env = append(os.Environ(), "GODEBUG=http2xconnect=1")
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				os.Exit(exitErr.ExitCode())
+			}
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// writeFrame writes a masked text frame, as clients do.
+func writeFrame(w io.Writer, payload string) error {
+	frame := []byte{0x81, 0x80 | byte(len(payload)), 1, 2, 3, 4}
+	for i := 0; i < len(payload); i++ {
+		frame = append(frame, payload[i]^frame[2+i%4])
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// readFrame reads an unmasked frame of less than 126 bytes, as servers send.
+func readFrame(r io.Reader) (byte, string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, "", err
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, "", err
+	}
+	return header[0] & 0x0f, string(payload), nil
+}
+
+func TestWebsocket_ExtendedConnect(t *testing.T) {
+	ctx := context.Background()
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	s.WebSocket("/echo").
+		WithOptions(types.WebSocketOption{Subprotocols: []string{"echo.v1"}}).
+		ServeMessage(func(ctx context.Context, request interface{}) (interface{}, error) {
+			session, _ := crazyserver.WebsocketConnection(ctx)
+			return fmt.Sprintf("%v via %s", request, session.Subprotocol()), nil
+		})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	exchange := func(t *testing.T, stream io.ReadWriter) {
+		if err := writeFrame(stream, "hello"); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		opcode, payload, err := readFrame(stream)
+		if err != nil || opcode != 1 || payload != "hello via echo.v1" {
+			t.Fatalf("unexpected reply %d %q: %v", opcode, payload, err)
+		}
+	}
+
+	t.Run("HTTP/3", func(t *testing.T) {
+		conn, err := quic.DialAddr(ctx, "localhost:4571", &tls.Config{InsecureSkipVerify: true, NextProtos: []string{http3.NextProtoH3}}, nil)
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		defer conn.CloseWithError(0, "")
+
+		cc := (&http3.Transport{}).NewClientConn(conn)
+		select {
+		case <-cc.ReceivedSettings():
+		case <-time.After(time.Second):
+			t.Fatalf("expected server settings")
+		}
+		if !cc.Settings().EnableExtendedConnect {
+			t.Fatalf("expected the server to enable extended CONNECT")
+		}
+
+		stream, err := cc.OpenRequestStream(ctx)
+		if err != nil {
+			t.Fatalf("failed to open stream: %v", err)
+		}
+		u, _ := url.Parse("https://localhost:4571/echo")
+		err = stream.SendRequestHeader(&http.Request{
+			Method: http.MethodConnect,
+			Proto:  "websocket",
+			URL:    u,
+			Host:   u.Host,
+			Header: http.Header{"Sec-Websocket-Version": {"13"}, "Sec-Websocket-Protocol": {"echo.v1"}},
+		})
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		resp, err := stream.ReadResponse()
+		if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Sec-Websocket-Protocol") != "echo.v1" {
+			t.Fatalf("unexpected response %v: %v", resp, err)
+		}
+
+		exchange(t, stream)
+	})
+
+	t.Run("HTTP/2", func(t *testing.T) {
+		// net/http clients cannot send :protocol yet, so speak HTTP/2 directly
+		conn, err := tls.Dial("tcp", "localhost:4570", &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		defer conn.Close()
+		if conn.ConnectionState().NegotiatedProtocol != "h2" {
+			t.Fatalf("expected HTTP/2 to be negotiated")
+		}
+
+		_, _ = io.WriteString(conn, http2.ClientPreface)
+		framer := http2.NewFramer(conn, conn)
+		_ = framer.WriteSettings()
+
+		var headers bytes.Buffer
+		encoder := hpack.NewEncoder(&headers)
+		for _, field := range [][2]string{
+			{":method", "CONNECT"}, {":protocol", "websocket"}, {":scheme", "https"},
+			{":path", "/echo"}, {":authority", "localhost:4570"},
+			{"sec-websocket-version", "13"}, {"sec-websocket-protocol", "echo.v1"},
+		} {
+			_ = encoder.WriteField(hpack.HeaderField{Name: field[0], Value: field[1]})
+		}
+
+		var sentRequest bool
+		var data bytes.Buffer
+		_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+		for data.Len() < 2 || data.Len() < 2+int(data.Bytes()[1]&0x7f) {
+			frame, err := framer.ReadFrame()
+			if err != nil {
+				t.Fatalf("failed to read frame: %v", err)
+			}
+
+			switch frame := frame.(type) {
+			case *http2.SettingsFrame:
+				if frame.IsAck() || sentRequest {
+					continue
+				}
+				// SETTINGS_ENABLE_CONNECT_PROTOCOL
+				if value, ok := frame.Value(http2.SettingID(0x8)); !ok || value != 1 {
+					t.Fatalf("expected the server to enable extended CONNECT")
+				}
+				_ = framer.WriteSettingsAck()
+				_ = framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: headers.Bytes(), EndHeaders: true})
+				sentRequest = true
+
+			case *http2.HeadersFrame:
+				fields, _ := hpack.NewDecoder(4096, nil).DecodeFull(frame.HeaderBlockFragment())
+				response := map[string]string{}
+				for _, field := range fields {
+					response[field.Name] = field.Value
+				}
+				if response[":status"] != "200" || response["sec-websocket-protocol"] != "echo.v1" {
+					t.Fatalf("unexpected response %v", response)
+				}
+
+				var message bytes.Buffer
+				_ = writeFrame(&message, "hello")
+				_ = framer.WriteData(1, false, message.Bytes())
+
+			case *http2.DataFrame:
+				data.Write(frame.Data())
+			}
+		}
+
+		opcode, payload, err := readFrame(&data)
+		if err != nil || opcode != 1 || payload != "hello via echo.v1" {
+			t.Fatalf("unexpected reply %d %q: %v", opcode, payload, err)
+		}
+	})
+
+	t.Run("HTTP/1.1 fallback", func(t *testing.T) {
+		conn, _, err := (&gws.Dialer{Subprotocols: []string{"echo.v1"}}).Dial("ws://localhost:4571/echo", nil)
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		defer conn.Close()
+
+		_ = conn.WriteMessage(gws.TextMessage, []byte("hello"))
+		if _, p, err := conn.ReadMessage(); err != nil || string(p) != "hello via echo.v1" {
+			t.Fatalf("unexpected reply %q: %v", p, err)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+type RoomMessage struct {
+	Action string `json:"action"`
+	Room   string `json:"room"`
+	Name   string `json:"name,omitempty"`
+	Text   string `json:"text,omitempty"`
+}
+
+type Announcement struct {
+	Room string `json:"room"`
+	Text string `json:"text"`
+}
+
+// Rooms lets clients join and leave rooms, and say something to a room.
+func Rooms(server crazyserver.HttpServer) types.HandlerFunc {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		message, err := crazyserver.DecodeJsonRequest[RoomMessage](request)
+		if err != nil {
+			return nil, err
+		}
+		session, _ := crazyserver.WebsocketConnection(ctx)
+
+		switch message.Action {
+		case "join":
+			if message.Name != "" {
+				session.SetMetadata("name", message.Name)
+			}
+			session.Join(message.Room)
+			return session.Rooms(), nil
+		case "leave":
+			session.Leave(message.Room)
+			return session.Rooms(), nil
+		case "say":
+			_, err := server.Hub().Broadcast(message.Room, message)
+			return nil, err
+		}
+		return nil, errors.BadRequest.Newf("unknown action %q", message.Action)
+	}
+}
+
+// Announce broadcasts an announcement to a room from a regular HTTP endpoint.
+func Announce(server crazyserver.HttpServer) types.HandlerFunc {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		announcement, err := crazyserver.DecodeJsonRequest[Announcement](request)
+		if err != nil {
+			return nil, err
+		}
+
+		sent, err := server.Hub().Broadcast(announcement.Room, announcement)
+		return map[string]int{"sent": sent}, err
+	}
+}
+
+// Presence lists the sessions in a room.
+func Presence(server crazyserver.HttpServer) types.HandlerFunc {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		room := ctx.Value(constants.HttpRequestPathValues).(map[string]string)["room"]
+		return server.Hub().Sessions(room), nil
+	}
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.WebSocket("/rooms").
+		WithOptions(types.WebSocketOption{
+			SendBufferSize:     64,
+			SlowConsumerPolicy: types.WebsocketSlowConsumerDisconnect,
+		}).
+		ServeMessage(Rooms(server))
+
+	server.POST("/announcements").Serve(Announce(server))
+	server.GET("/rooms/{room}/presence").Serve(Presence(server))
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	gws "github.com/gorilla/websocket"
+)
+
+func TestWebsocket_Hub(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4551"
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	s.WebSocket("/rooms").ServeMessage(func(ctx context.Context, request interface{}) (interface{}, error) {
+		message := request.(map[string]interface{})
+		session, ok := crazyserver.WebsocketConnection(ctx)
+		if !ok {
+			t.Errorf("expected the session in the message context")
+		}
+
+		room, _ := message["room"].(string)
+		switch message["action"] {
+		case "join":
+			session.SetMetadata("name", message["name"].(string))
+			session.Join(room)
+			return map[string]interface{}{"id": session.Id(), "rooms": session.Rooms()}, nil
+		case "leave":
+			session.Leave(room)
+			return map[string]interface{}{"id": session.Id(), "rooms": session.Rooms()}, nil
+		}
+		_, err := s.Hub().Broadcast(room, message["text"])
+		return nil, err
+	})
+
+	slowConsumer := func(path string, policy types.WebsocketSlowConsumerPolicy) {
+		s.WebSocket(path).
+			WithOptions(types.WebSocketOption{SendBufferSize: 1, SlowConsumerPolicy: policy}).
+			ServeConn(func(ctx context.Context, conn types.WebsocketConn) error {
+				conn.Join(path)
+				_, err := conn.Recv()
+				return err
+			})
+	}
+	slowConsumer("/drop", types.WebsocketSlowConsumerDrop)
+	slowConsumer("/disconnect", types.WebsocketSlowConsumerDisconnect)
+
+	s.POST("/announce").Serve(func(ctx context.Context, request interface{}) (interface{}, error) {
+		sent, err := s.Hub().Broadcast("lobby", "announcement")
+		return map[string]int{"sent": sent}, err
+	})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	dial := func(path string) *gws.Conn {
+		conn, _, err := gws.DefaultDialer.Dial(fmt.Sprintf("ws://%s%s", addr, path), nil)
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		return conn
+	}
+	read := func(conn *gws.Conn) string {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, p, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		return string(p)
+	}
+	join := func(conn *gws.Conn, name, room string) string {
+		t.Helper()
+		_ = conn.WriteJSON(map[string]string{"action": "join", "name": name, "room": room})
+		var reply struct {
+			Id string `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(read(conn)), &reply); err != nil || reply.Id == "" {
+			t.Fatalf("unexpected join reply: %v", err)
+		}
+		return reply.Id
+	}
+
+	alice, bob := dial("/rooms"), dial("/rooms")
+	defer alice.Close()
+	defer bob.Close()
+
+	aliceId := join(alice, "alice", "lobby")
+	join(bob, "bob", "lobby")
+	if rooms := s.Hub().Rooms(); len(rooms) != 1 || rooms[0] != "lobby" {
+		t.Fatalf("unexpected rooms %v", rooms)
+	}
+
+	_ = alice.WriteJSON(map[string]string{"action": "say", "room": "lobby", "text": "hi all"})
+	if got := read(alice); got != "hi all" {
+		t.Fatalf("alice: unexpected broadcast %q", got)
+	}
+	if got := read(bob); got != "hi all" {
+		t.Fatalf("bob: unexpected broadcast %q", got)
+	}
+
+	resp, err := http.Post(fmt.Sprintf("http://%s/announce", addr), "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("failed to make request: %v", err)
+	}
+	var announced map[string]int
+	_ = json.NewDecoder(resp.Body).Decode(&announced)
+	resp.Body.Close()
+	if announced["sent"] != 2 || read(alice) != "announcement" || read(bob) != "announcement" {
+		t.Fatalf("expected the announcement to reach both sessions, sent to %d", announced["sent"])
+	}
+
+	sessions := s.Hub().Sessions("lobby")
+	if len(sessions) != 2 || sessions[0].Metadata["name"] != "alice" || sessions[1].Metadata["name"] != "bob" {
+		t.Fatalf("unexpected presence %+v", sessions)
+	}
+	if sessions[0].Url != "/rooms" || len(sessions[0].Rooms) != 1 {
+		t.Fatalf("unexpected session %+v", sessions[0])
+	}
+
+	if err := s.Hub().SendTo(aliceId, "just for you"); err != nil || read(alice) != "just for you" {
+		t.Fatalf("expected direct message to alice: %v", err)
+	}
+	if err := s.Hub().SendTo("unknown", "nobody"); err == nil {
+		t.Fatalf("expected an error sending to an unknown session")
+	}
+
+	_ = bob.WriteJSON(map[string]string{"action": "leave", "room": "lobby"})
+	read(bob)
+	if sessions := s.Hub().Sessions("lobby"); len(sessions) != 1 || sessions[0].Id != aliceId {
+		t.Fatalf("expected only alice in the lobby, got %+v", sessions)
+	}
+
+	// sessions leave their rooms once closed
+	alice.Close()
+	deadline := time.Now().Add(time.Second)
+	for len(s.Hub().Rooms()) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rooms := s.Hub().Rooms(); len(rooms) != 0 {
+		t.Fatalf("expected no rooms once alice left, got %v", rooms)
+	}
+
+	// slow consumers which never read fill up their buffers
+	payload := strings.Repeat("x", 256*1024)
+	flood := func(room string) (dropped bool) {
+		for i := 0; i < 200; i++ {
+			if _, err := s.Hub().Broadcast(room, payload); err != nil {
+				return true
+			}
+		}
+		return false
+	}
+
+	drop := dial("/drop")
+	defer drop.Close()
+	disconnect := dial("/disconnect")
+	defer disconnect.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	if !flood("/drop") {
+		t.Fatalf("expected messages to the slow consumer to be dropped")
+	}
+	if len(s.Hub().Sessions("/drop")) != 1 {
+		t.Fatalf("expected the slow consumer to stay connected with the drop policy")
+	}
+
+	if !flood("/disconnect") {
+		t.Fatalf("expected the slow consumer to be disconnected")
+	}
+	if len(s.Hub().Sessions("/disconnect")) != 0 {
+		t.Fatalf("expected the slow consumer to be removed from the hub")
+	}
+}
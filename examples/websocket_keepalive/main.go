@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// Echo replies to every message with the message itself.
+func Echo(ctx context.Context, request interface{}) (interface{}, error) {
+	return request, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	// mobile clients often vanish without closing their connection, pings
+	// detect them within 45 seconds
+	server.WebSocket("/echo").
+		WithOptions(types.WebSocketOption{
+			Subprotocols:      []string{"echo.v2", "echo.v1"},
+			PingInterval:      30 * time.Second,
+			PongTimeout:       15 * time.Second,
+			WriteTimeout:      10 * time.Second,
+			MaxMessageSize:    64 * 1024,
+			EnableCompression: true,
+		}).
+		ServeMessage(Echo)
+
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+
+		// clients are told the server is going away, so they can reconnect
+		shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Server failed to shut down: %v", err)
+		}
+	}()
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Printf("Server stopped: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	gws "github.com/gorilla/websocket"
+)
+
+func TestWebsocket_Keepalive(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4561"
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	s.WebSocket("/echo").
+		WithOptions(types.WebSocketOption{
+			Subprotocols:      []string{"echo.v2", "echo.v1"},
+			PingInterval:      100 * time.Millisecond,
+			PongTimeout:       100 * time.Millisecond,
+			MaxMessageSize:    1024,
+			EnableCompression: true,
+		}).
+		ServeMessage(func(ctx context.Context, request interface{}) (interface{}, error) {
+			return request, nil
+		})
+
+	// handlers which only send still see clients leave
+	feedDone := make(chan struct{}, 2)
+	s.WebSocket("/feed").
+		WithOptions(types.WebSocketOption{
+			PingInterval: 100 * time.Millisecond,
+			PongTimeout:  100 * time.Millisecond,
+		}).
+		ServeConn(func(ctx context.Context, conn types.WebsocketConn) error {
+			defer func() { feedDone <- struct{}{} }()
+			ticker := time.NewTicker(50 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if err := conn.Send("tick"); err != nil {
+						return err
+					}
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	dial := func() *gws.Conn {
+		dialer := gws.Dialer{EnableCompression: true, Subprotocols: []string{"echo.v1", "echo.v2"}}
+		conn, resp, err := dialer.Dial(fmt.Sprintf("ws://%s/echo", addr), nil)
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		if conn.Subprotocol() != "echo.v2" {
+			t.Fatalf("expected the server's preferred subprotocol, got %q", conn.Subprotocol())
+		}
+		if !strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+			t.Fatalf("expected per-message deflate to be negotiated, got %v", resp.Header)
+		}
+		return conn
+	}
+	waitForSessions := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for len(s.Hub().Sessions("")) != n && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := len(s.Hub().Sessions("")); got != n {
+			t.Fatalf("expected %d sessions, got %d", n, got)
+		}
+	}
+
+	// clients which answer pings stay connected
+	alive := dial()
+	defer alive.Close()
+	var pings atomic.Int32
+	alive.SetPingHandler(func(data string) error {
+		pings.Add(1)
+		return alive.WriteControl(gws.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	replies := make(chan string, 1)
+	aliveClosed := make(chan error, 1)
+	go func() {
+		for {
+			_, p, err := alive.ReadMessage()
+			if err != nil {
+				aliveClosed <- err
+				return
+			}
+			replies <- string(p)
+		}
+	}()
+
+	// clients which stop reading never answer pings, and are dropped
+	dead := dial()
+	defer dead.Close()
+	waitForSessions(2)
+	time.Sleep(500 * time.Millisecond)
+	waitForSessions(1)
+	if pings.Load() < 3 {
+		t.Fatalf("expected the live client to be pinged, got %d pings", pings.Load())
+	}
+
+	_ = alive.WriteMessage(gws.TextMessage, []byte(strings.Repeat("a", 512)))
+	if reply := <-replies; reply != strings.Repeat("a", 512) {
+		t.Fatalf("unexpected compressed echo of %d bytes", len(reply))
+	}
+
+	waitForFeed := func(reason string) {
+		t.Helper()
+		select {
+		case <-feedDone:
+		case <-time.After(time.Second):
+			t.Fatalf("expected the sending handler to stop once the client %s", reason)
+		}
+	}
+
+	feedClosing, _, err := gws.DefaultDialer.Dial(fmt.Sprintf("ws://%s/feed", addr), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer feedClosing.Close()
+	_ = feedClosing.WriteControl(gws.CloseMessage, gws.FormatCloseMessage(gws.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	waitForFeed("closes the session")
+
+	feedDead, _, err := gws.DefaultDialer.Dial(fmt.Sprintf("ws://%s/feed", addr), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer feedDead.Close()
+	waitForFeed("stops answering pings")
+
+	// messages over the limit close the session
+	tooBig := dial()
+	defer tooBig.Close()
+	_ = tooBig.WriteMessage(gws.TextMessage, []byte(strings.Repeat("b", 2048)))
+	_ = tooBig.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := tooBig.ReadMessage(); !gws.IsCloseError(err, gws.CloseMessageTooBig) {
+		t.Fatalf("expected close code %d, got %v", gws.CloseMessageTooBig, err)
+	}
+
+	// sessions are told the server is going away on shutdown
+	shutdownCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	select {
+	case err := <-aliveClosed:
+		if !gws.IsCloseError(err, gws.CloseGoingAway) {
+			t.Fatalf("expected close code %d, got %v", gws.CloseGoingAway, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the session to be closed on shutdown")
+	}
+}
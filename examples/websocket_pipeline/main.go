@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	gws "github.com/gorilla/websocket"
+)
+
+type userKey struct{}
+
+type ChatMessage struct {
+	Room string `json:"room"`
+	Text string `json:"text"`
+}
+
+type ChatReply struct {
+	From string `json:"from"`
+	Text string `json:"text"`
+}
+
+// Handshake authenticates the client and selects the chat.v1 subprotocol.
+func Handshake(ctx context.Context, r *http.Request) (context.Context, types.WebSocketHandshake, error) {
+	user := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if user == "" {
+		return ctx, types.WebSocketHandshake{}, errors.Unauthorized.New("missing credentials")
+	}
+
+	for _, protocol := range gws.Subprotocols(r) {
+		if protocol == "chat.v1" {
+			return context.WithValue(ctx, userKey{}, user), types.WebSocketHandshake{Subprotocol: protocol}, nil
+		}
+	}
+	return ctx, types.WebSocketHandshake{}, errors.BadRequest.New("chat.v1 subprotocol is required")
+}
+
+// Chat replies to every message, which is decoded to a ChatMessage.
+func Chat(ctx context.Context, request interface{}) (interface{}, error) {
+	message, err := crazyserver.DecodeJsonRequest[ChatMessage](request)
+	if err != nil {
+		return nil, err
+	}
+	if message.Text == "" {
+		return nil, errors.BadRequest.New("text is required")
+	}
+
+	return ChatReply{From: ctx.Value(userKey{}).(string), Text: message.Text}, nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.WebSocket("/chat").
+		HandleUpgrade(Handshake).
+		ServeMessage(Chat)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
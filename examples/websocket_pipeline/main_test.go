@@ -0,0 +1,191 @@
+package main_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	gws "github.com/gorilla/websocket"
+)
+
+type userKey struct{}
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func TestWebsocket_Pipeline(t *testing.T) {
+	ctx := context.Background()
+	addr := "localhost:4541"
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	handshake := func(ctx context.Context, r *http.Request) (context.Context, types.WebSocketHandshake, error) {
+		if r.Header.Get("Authorization") == "" {
+			return ctx, types.WebSocketHandshake{}, errors.Unauthorized.New("missing credentials")
+		}
+		return context.WithValue(ctx, userKey{}, r.Header.Get("Authorization")), types.WebSocketHandshake{
+			Subprotocol: "greet.v1",
+			Headers:     http.Header{"X-Session": {"abc"}},
+		}, nil
+	}
+
+	var afterServe atomic.Int32
+	s.WebSocket("/greet").
+		HandleUpgrade(handshake).
+		WithBeforeServe(func(ctx context.Context, request interface{}) (context.Context, interface{}, error) {
+			if _, ok := request.(string); ok {
+				return ctx, request, errors.BadRequest.New("expected a JSON message")
+			}
+			return ctx, request, nil
+		}).
+		WithAfterServe(func(ctx context.Context, response interface{}) (interface{}, error) {
+			afterServe.Add(1)
+			return response, nil
+		}).
+		ServeMessage(func(ctx context.Context, request interface{}) (interface{}, error) {
+			req, err := crazyserver.DecodeJsonRequest[greeting](request)
+			if err != nil {
+				return nil, err
+			}
+			return fmt.Sprintf("hello %s from %s", req.Name, ctx.Value(userKey{})), nil
+		})
+
+	s.WebSocket("/upper").
+		WithMessageDecoder(func(ctx context.Context, messageType types.WebsocketMessageType, data []byte) (interface{}, error) {
+			return strings.ToUpper(string(data)), nil
+		}).
+		ServeConn(func(ctx context.Context, conn types.WebsocketConn) error {
+			for {
+				message, err := conn.Recv()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if err := conn.Send(message.Data); err != nil {
+					return err
+				}
+			}
+		})
+
+	// the channels of Serve carry the messages decoded by an HTTP decoder
+	var handshakes atomic.Int32
+	s.WebSocket("/legacy").
+		HandleHandshake(func() { handshakes.Add(1) }).
+		WithDecoder(func(ctx context.Context, r *http.Request) (context.Context, interface{}, error) {
+			var req greeting
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				return ctx, nil, err
+			}
+			return ctx, req, nil
+		}).
+		WithBeforeServe(func(ctx context.Context, request interface{}) (context.Context, interface{}, error) {
+			if request.(greeting).Name == "" {
+				return ctx, request, errors.BadRequest.New("name is required")
+			}
+			return ctx, request, nil
+		}).
+		Serve(func(ctx context.Context) error {
+			requests := ctx.Value(constants.WebsocketRequestChannel).(chan types.WebsocketStreamChunk)
+			responses := ctx.Value(constants.WebsocketResponseChannel).(chan types.WebsocketStreamChunk)
+			for chunk := range requests {
+				select {
+				case responses <- types.WebsocketStreamChunk{Data: []byte("hi " + chunk.Message.(greeting).Name)}:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			return nil
+		})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	dialer := gws.Dialer{Subprotocols: []string{"greet.v1"}}
+
+	_, resp, err := dialer.Dial(fmt.Sprintf("ws://%s/greet", addr), nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected handshake to be rejected with 401, got %v", resp)
+	}
+
+	conn, resp, err := dialer.Dial(fmt.Sprintf("ws://%s/greet", addr), http.Header{"Authorization": {"alice"}})
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != "greet.v1" || resp.Header.Get("X-Session") != "abc" {
+		t.Fatalf("unexpected handshake response: %q %v", conn.Subprotocol(), resp.Header)
+	}
+
+	_ = conn.WriteMessage(gws.TextMessage, []byte(`{"name":"bob"}`))
+	if _, p, err := conn.ReadMessage(); err != nil || string(p) != "hello bob from alice" {
+		t.Fatalf("unexpected reply %q: %v", p, err)
+	}
+
+	// errors are sent as problem details, and the session stays open
+	_ = conn.WriteMessage(gws.TextMessage, []byte("not json"))
+	if _, p, err := conn.ReadMessage(); err != nil || !strings.Contains(string(p), "expected a JSON message") {
+		t.Fatalf("unexpected error reply %q: %v", p, err)
+	}
+
+	_ = conn.WriteMessage(gws.TextMessage, []byte(`{"name":"carol"}`))
+	if _, p, err := conn.ReadMessage(); err != nil || string(p) != "hello carol from alice" {
+		t.Fatalf("unexpected reply %q: %v", p, err)
+	}
+	if afterServe.Load() != 2 {
+		t.Fatalf("expected after serve middleware to run for each reply, ran %d times", afterServe.Load())
+	}
+
+	upper, _, err := gws.DefaultDialer.Dial(fmt.Sprintf("ws://%s/upper", addr), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer upper.Close()
+
+	_ = upper.WriteMessage(gws.TextMessage, []byte("shout"))
+	if _, p, err := upper.ReadMessage(); err != nil || string(p) != "SHOUT" {
+		t.Fatalf("unexpected reply %q: %v", p, err)
+	}
+
+	legacy, _, err := gws.DefaultDialer.Dial(fmt.Sprintf("ws://%s/legacy", addr), nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer legacy.Close()
+
+	if handshakes.Load() != 1 {
+		t.Fatalf("expected the handshake function to run once, ran %d times", handshakes.Load())
+	}
+
+	// a message failing the middlewares is answered with an error, and the
+	// session stays open
+	for _, test := range []struct{ message, reply string }{
+		{`{"name":"dave"}`, "hi dave"},
+		{`{"name":""}`, "name is required"},
+		{`not json`, "could not decode message"},
+		{`{"name":"erin"}`, "hi erin"},
+	} {
+		_ = legacy.WriteMessage(gws.TextMessage, []byte(test.message))
+		if _, p, err := legacy.ReadMessage(); err != nil || !strings.Contains(string(p), test.reply) {
+			t.Fatalf("unexpected reply to %s %q: %v", test.message, p, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// Game echoes positions sent as datagrams, which may be lost or reordered,
+// while chat messages on streams are delivered reliably.
+func Game(ctx context.Context, session types.WebTransportSession) error {
+	go func() {
+		for {
+			position, err := session.ReceiveDatagram(ctx)
+			if err != nil {
+				return
+			}
+			_ = session.SendDatagram(position)
+		}
+	}()
+
+	for {
+		stream, err := session.AcceptStream(ctx)
+		if err != nil {
+			return nil
+		}
+		go func() {
+			defer stream.Close()
+			_, _ = io.Copy(stream, stream)
+		}()
+	}
+}
+
+func main() {
+	ctx := context.Background()
+
+	server := crazyserver.NewHttpServer(ctx)
+	if err := server.Initialize(ctx); err != nil {
+		log.Fatalf("Server failed to Initialize: %v", err)
+	}
+
+	server.WebTransport("/game").
+		WithOptions(types.WebTransportOption{AllowedOrigins: []string{"https://game.example.com"}}).
+		Serve(Game)
+
+	if err := server.ListenAndServe(ctx); err != nil {
+		log.Fatalf("Server failed to start: %v", err)
+	}
+}
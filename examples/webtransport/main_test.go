@@ -0,0 +1,138 @@
+package main_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	crazyserver "github.com/ayushanand18/crazyhttp/pkg/server"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/quic-go/webtransport-go"
+)
+
+type playerKey struct{}
+
+func TestWebTransport(t *testing.T) {
+	ctx := context.Background()
+	url := "https://localhost:4581/game"
+
+	s := crazyserver.NewHttpServer(ctx)
+	if err := s.Initialize(ctx); err != nil {
+		t.Fatalf("server initialization failed: %v", err)
+	}
+
+	s.WebTransport("/game").
+		WithOptions(types.WebTransportOption{AllowedOrigins: []string{"https://game.example.com"}}).
+		HandleHandshake(func(ctx context.Context, r *http.Request) (context.Context, error) {
+			player := r.Header.Get("X-Player")
+			if player == "" {
+				return ctx, errors.Unauthorized.New("missing player")
+			}
+			return context.WithValue(ctx, playerKey{}, player), nil
+		}).
+		Serve(func(ctx context.Context, session types.WebTransportSession) error {
+			// greet the player on a stream of its own
+			greeting, err := session.OpenUniStream(ctx)
+			if err != nil {
+				return err
+			}
+			_, _ = io.WriteString(greeting, "welcome "+ctx.Value(playerKey{}).(string))
+			_ = greeting.Close()
+
+			go func() {
+				for {
+					datagram, err := session.ReceiveDatagram(ctx)
+					if err != nil {
+						return
+					}
+					_ = session.SendDatagram(append([]byte("ack "), datagram...))
+				}
+			}()
+
+			stream, err := session.AcceptStream(ctx)
+			if err != nil {
+				return err
+			}
+			_, _ = io.Copy(stream, stream)
+			_ = stream.Close()
+
+			<-ctx.Done()
+			return nil
+		})
+
+	go func() {
+		_ = s.ListenAndServe(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	dialer := &webtransport.Dialer{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true, NextProtos: []string{http3.NextProtoH3}},
+		QUICConfig:      &quic.Config{EnableDatagrams: true},
+	}
+	defer dialer.Close()
+
+	dialCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	resp, _, err := dialer.Dial(dialCtx, url, http.Header{"Origin": {"https://evil.example.com"}, "X-Player": {"mallory"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected the origin to be rejected, got %v: %v", resp, err)
+	}
+	resp, _, err = dialer.Dial(dialCtx, url, http.Header{"Origin": {"https://game.example.com"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the handshake to reject the session, got %v: %v", resp, err)
+	}
+
+	resp, session, err := dialer.Dial(dialCtx, url, http.Header{"Origin": {"https://game.example.com"}, "X-Player": {"alice"}})
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("dial failed: %v", err)
+	}
+
+	greeting, err := session.AcceptUniStream(dialCtx)
+	if err != nil {
+		t.Fatalf("expected a greeting stream: %v", err)
+	}
+	if p, err := io.ReadAll(greeting); err != nil || string(p) != "welcome alice" {
+		t.Fatalf("unexpected greeting %q: %v", p, err)
+	}
+
+	stream, err := session.OpenStreamSync(dialCtx)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	_, _ = io.WriteString(stream, "hello over a stream")
+	_ = stream.Close()
+	if p, err := io.ReadAll(stream); err != nil || string(p) != "hello over a stream" {
+		t.Fatalf("unexpected echo %q: %v", p, err)
+	}
+
+	// datagrams may be lost, retry until one round trips
+	var ack []byte
+	for i := 0; i < 10 && ack == nil; i++ {
+		if err := session.SendDatagram([]byte("position 1,2")); err != nil {
+			t.Fatalf("failed to send datagram: %v", err)
+		}
+		receiveCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		ack, _ = session.ReceiveDatagram(receiveCtx)
+		cancel()
+	}
+	if string(ack) != "ack position 1,2" {
+		t.Fatalf("unexpected datagram %q", ack)
+	}
+
+	// sessions are closed on shutdown
+	shutdownCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	_ = s.Shutdown(shutdownCtx)
+	select {
+	case <-session.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatalf("expected the session to be closed on shutdown")
+	}
+}
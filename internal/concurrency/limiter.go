@@ -0,0 +1,202 @@
+package concurrency
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+var ErrLimitExceeded = errors.New("concurrency limit exceeded")
+
+type Algorithm int
+
+const (
+	// Fixed keeps the limit constant.
+	Fixed Algorithm = iota
+	// AIMD increases the limit by one per window of requests completing within
+	// the target latency, and cuts it by a factor when one does not.
+	AIMD
+	// Gradient scales the limit by the ratio of the lowest observed latency to
+	// the current one, leaving headroom to probe for more capacity.
+	Gradient
+)
+
+const (
+	aimdBackoff       = 0.9
+	gradientSmoothing = 0.2
+	gradientMinRatio  = 0.5
+	// minLatencyDrift lets the lowest observed latency rise slowly, so the
+	// limiter adapts when the baseline latency changes
+	minLatencyDrift = 1.001
+)
+
+type Options struct {
+	Limit         int
+	MinLimit      int
+	MaxLimit      int
+	MaxQueue      int
+	QueueTimeout  time.Duration
+	Algorithm     Algorithm
+	TargetLatency time.Duration
+}
+
+// Limiter bounds the number of operations in flight, queueing a bounded number
+// of callers when saturated.
+type Limiter struct {
+	mu       sync.Mutex
+	options  Options
+	limit    float64
+	inFlight int
+	waiters  *list.List
+
+	minLatency time.Duration
+}
+
+func NewLimiter(options Options) *Limiter {
+	if options.Limit < 1 {
+		options.Limit = 1
+	}
+	if options.MinLimit < 1 {
+		options.MinLimit = 1
+	}
+	if options.MaxLimit < options.Limit {
+		options.MaxLimit = options.Limit
+		if options.Algorithm != Fixed {
+			options.MaxLimit = options.Limit * 10
+		}
+	}
+
+	return &Limiter{
+		options: options,
+		limit:   float64(options.Limit),
+		waiters: list.New(),
+	}
+}
+
+// Acquire admits the caller, waiting in the queue up to the queue timeout if the
+// limit is reached. The returned func must be called once the operation is done.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	l.mu.Lock()
+	if l.inFlight < l.currentLimit() && l.waiters.Len() == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return l.releaser(), nil
+	}
+
+	if l.waiters.Len() >= l.options.MaxQueue {
+		l.mu.Unlock()
+		return nil, ErrLimitExceeded
+	}
+
+	ready := make(chan struct{})
+	elem := l.waiters.PushBack(ready)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if l.options.QueueTimeout > 0 {
+		timer := time.NewTimer(l.options.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ready:
+		return l.releaser(), nil
+	case <-ctx.Done():
+	case <-timeout:
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-ready:
+		// admitted while giving up, so the slot is ours
+		return l.releaser(), nil
+	default:
+		l.waiters.Remove(elem)
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, ErrLimitExceeded
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.currentLimit()
+}
+
+// InFlight returns the number of operations currently admitted.
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inFlight
+}
+
+func (l *Limiter) currentLimit() int {
+	return int(l.limit)
+}
+
+func (l *Limiter) releaser() func() {
+	start := time.Now()
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			l.release(time.Since(start))
+		})
+	}
+}
+
+func (l *Limiter) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	l.adapt(latency)
+
+	for l.waiters.Len() > 0 && l.inFlight < l.currentLimit() {
+		ready := l.waiters.Remove(l.waiters.Front()).(chan struct{})
+		l.inFlight++
+		close(ready)
+	}
+}
+
+func (l *Limiter) adapt(latency time.Duration) {
+	switch l.options.Algorithm {
+	case AIMD:
+		if l.options.TargetLatency > 0 && latency > l.options.TargetLatency {
+			l.limit *= aimdBackoff
+		} else {
+			l.limit += 1 / l.limit
+		}
+
+	case Gradient:
+		if l.minLatency == 0 || latency < l.minLatency {
+			l.minLatency = latency
+		} else {
+			l.minLatency = time.Duration(float64(l.minLatency) * minLatencyDrift)
+		}
+		if latency <= 0 {
+			return
+		}
+
+		gradient := math.Max(gradientMinRatio, math.Min(1, float64(l.minLatency)/float64(latency)))
+		newLimit := l.limit*gradient + math.Sqrt(l.limit)
+		l.limit = l.limit*(1-gradientSmoothing) + newLimit*gradientSmoothing
+
+	default:
+		return
+	}
+
+	l.limit = math.Max(float64(l.options.MinLimit), math.Min(float64(l.options.MaxLimit), l.limit))
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -73,6 +74,23 @@ func GetBool(ctx context.Context, keyString string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// GetDuration reads a duration written either as a Go duration string (e.g.
+// "1m30s") or as a number of seconds.
+func GetDuration(ctx context.Context, keyString string, defaultValue time.Duration) time.Duration {
+	val := GetValue(ctx, keyString)
+	switch v := val.(type) {
+	case string:
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	case int:
+		return time.Duration(v) * time.Second
+	case float64:
+		return time.Duration(v * float64(time.Second))
+	}
+	return defaultValue
+}
+
 func GetBytes(ctx context.Context, keyString string) []byte {
 	val := GetValue(ctx, keyString)
 	return []byte(val.(string))
@@ -0,0 +1,153 @@
+// Package handoff passes the sockets of a running process to the process
+// replacing it, so that no connection is refused while upgrading.
+//
+// Sockets are inherited from file descriptor 3 onwards, named in order by
+// CRAZYHTTP_INHERITED_FDS. The new process writes to the pipe at
+// CRAZYHTTP_READY_FD once it serves them.
+package handoff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	envInheritedFds = "CRAZYHTTP_INHERITED_FDS"
+	envReadyFd      = "CRAZYHTTP_READY_FD"
+
+	inheritedFdsStart = 3
+)
+
+// Socket is a socket to pass to the new process, under a name.
+type Socket struct {
+	Name string
+	File *os.File
+}
+
+var (
+	once  sync.Once
+	files map[string]*os.File
+	ready *os.File
+)
+
+// inherit collects the sockets and the ready pipe passed by the previous
+// process. The environment is unset so that child processes do not take
+// them.
+func inherit() {
+	once.Do(func() {
+		names, fd := os.Getenv(envInheritedFds), os.Getenv(envReadyFd)
+		_ = os.Unsetenv(envInheritedFds)
+		_ = os.Unsetenv(envReadyFd)
+
+		if names != "" {
+			files = map[string]*os.File{}
+			for i, name := range strings.Split(names, ":") {
+				files[name] = os.NewFile(uintptr(inheritedFdsStart+i), name)
+			}
+		}
+		if n, err := strconv.Atoi(fd); err == nil {
+			ready = os.NewFile(uintptr(n), "ready")
+		}
+	})
+}
+
+// File returns the socket passed under name by the previous process, or nil
+// if none was. Each socket is returned once, as its listener takes ownership
+// of it.
+func File(name string) *os.File {
+	inherit()
+
+	f := files[name]
+	delete(files, name)
+	return f
+}
+
+// Ready tells the previous process that this one serves the sockets, so it
+// can stop serving them. It does nothing if the process was not started by
+// Start.
+func Ready() error {
+	inherit()
+	if ready == nil {
+		return nil
+	}
+
+	// sockets not taken are not served by this process
+	for _, f := range files {
+		_ = f.Close()
+	}
+	files = nil
+
+	_, err := ready.Write([]byte{1})
+	_ = ready.Close()
+	ready = nil
+	return err
+}
+
+// Start runs the executable of this process again, with the same arguments,
+// passing it sockets. It returns once the new process is ready, or kills it
+// if it exits or ctx is done before.
+func Start(ctx context.Context, sockets []Socket) (*os.Process, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the executable: %v", err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the ready pipe: %v", err)
+	}
+	defer r.Close()
+
+	names := make([]string, 0, len(sockets))
+	extraFiles := make([]*os.File, 0, len(sockets)+1)
+	for _, socket := range sockets {
+		names = append(names, socket.Name)
+		extraFiles = append(extraFiles, socket.File)
+	}
+	extraFiles = append(extraFiles, w)
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(),
+		envInheritedFds+"="+strings.Join(names, ":"),
+		envReadyFd+"="+strconv.Itoa(inheritedFdsStart+len(sockets)),
+	)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = extraFiles
+	err = cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", exe, err)
+	}
+
+	// the pipe is closed without a write if the new process exits
+	readyChan := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(r, make([]byte, 1))
+		readyChan <- err
+	}()
+
+	select {
+	case err = <-readyChan:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		if errors.Is(err, io.EOF) {
+			err = errors.New("exited before it was ready")
+		}
+		return nil, fmt.Errorf("new process %d failed: %v", cmd.Process.Pid, err)
+	}
+
+	// the new process outlives this one, it is not waited for
+	go func() { _ = cmd.Wait() }()
+	return cmd.Process, nil
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+// CapsuleTypeDatagram is the type of DATAGRAM capsules, see RFC 9297.
+const CapsuleTypeDatagram uint64 = 0x00
+
+// AppendCapsule appends a capsule of the given type to b.
+func AppendCapsule(b []byte, capsuleType uint64, value []byte) []byte {
+	b = quicvarint.Append(b, capsuleType)
+	b = quicvarint.Append(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// ReadCapsule reads the next capsule from r. Capsules longer than maxSize
+// fail, since their value cannot be skipped without reading it.
+func ReadCapsule(r quicvarint.Reader, maxSize int) (capsuleType uint64, value []byte, err error) {
+	capsuleType, err = quicvarint.Read(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, err := quicvarint.Read(r)
+	if err != nil {
+		return 0, nil, noEOF(err)
+	}
+	if length > uint64(maxSize) {
+		return 0, nil, fmt.Errorf("capsule of %d bytes exceeds the maximum of %d", length, maxSize)
+	}
+
+	value = make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return 0, nil, noEOF(err)
+	}
+	return capsuleType, value, nil
+}
+
+// noEOF reports a body ending within a capsule as truncated.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
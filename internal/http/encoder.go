@@ -3,7 +3,11 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
 )
 
 func DefaultHttpEncode(ctx context.Context, response interface{}) (headers map[string][]string, body []byte, err error) {
@@ -25,6 +29,10 @@ func DefaultHttpDecode(ctx context.Context, r *http.Request) (outgoingContext co
 	}
 
 	if e := json.NewDecoder(r.Body).Decode(&outgoingRequest); e != nil {
+		// an empty body is not an error, e.g. for GET requests
+		if e == io.EOF {
+			return ctx, nil, nil
+		}
 		return ctx, outgoingRequest, e
 	}
 
@@ -46,3 +54,25 @@ func GetDefaultSerialization(req interface{}) (body []byte, err error) {
 
 	return body, nil
 }
+
+// DefaultHttpErrorEncode encodes reqErr as an RFC 9457 Problem Details response.
+func DefaultHttpErrorEncode(ctx context.Context, response interface{}, reqErr error) (headers map[string][]string, body []byte, err error) {
+	headers = map[string][]string{
+		"Content-Type": {errors.ProblemDetailsContentType},
+	}
+
+	problem := errors.NewProblemDetails(reqErr)
+	if uri, ok := ctx.Value(constants.HttpRequestURI).(string); ok {
+		problem.Instance = uri
+	}
+	if requestId, ok := ctx.Value(constants.HttpRequestId).(string); ok {
+		problem.RequestId = requestId
+	}
+
+	body, err = json.Marshal(problem)
+	if err != nil {
+		return headers, nil, err
+	}
+
+	return headers, body, nil
+}
@@ -0,0 +1,198 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+const (
+	defaultMaxFormSize  = 32 << 20
+	defaultMaxFieldSize = 1 << 20
+
+	// bytes read to sniff the content type of a file, as per
+	// http.DetectContentType
+	sniffLength = 512
+)
+
+// IsFormRequest reports whether the body of r is a multipart/form-data or
+// application/x-www-form-urlencoded form.
+func IsFormRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "multipart/form-data" || mediaType == "application/x-www-form-urlencoded"
+}
+
+// DecodeForm decodes the form body of r. Uploaded files are streamed to
+// options.Sink, or to temporary files which the caller must remove with
+// Form.RemoveAll.
+func DecodeForm(ctx context.Context, r *http.Request, options types.FormOptions) (*types.Form, error) {
+	maxTotalSize := options.MaxTotalSize
+	if maxTotalSize <= 0 {
+		maxTotalSize = defaultMaxFormSize
+	}
+	r.Body = http.MaxBytesReader(nil, r.Body, maxTotalSize)
+
+	form := &types.Form{
+		Values: make(url.Values),
+		Files:  make(map[string][]*types.UploadedFile),
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if err := r.ParseForm(); err != nil {
+			return nil, formError(err)
+		}
+		form.Values = r.PostForm
+		return form, nil
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.BadRequest.Wrap(err, "request body is not valid multipart")
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			_ = form.RemoveAll()
+			return nil, formError(err)
+		}
+
+		if part.FileName() == "" {
+			err = readFormField(part, form, options)
+		} else {
+			err = readFormFile(ctx, part, form, options, maxTotalSize)
+		}
+		part.Close()
+
+		if err != nil {
+			_ = form.RemoveAll()
+			return nil, err
+		}
+	}
+}
+
+func readFormField(part *multipart.Part, form *types.Form, options types.FormOptions) error {
+	maxFieldSize := options.MaxFieldSize
+	if maxFieldSize <= 0 {
+		maxFieldSize = defaultMaxFieldSize
+	}
+
+	value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+	if err != nil {
+		return formError(err)
+	}
+	if int64(len(value)) > maxFieldSize {
+		return errors.ContentTooLarge.Newf("form field %q is too large", part.FormName())
+	}
+
+	form.Values.Add(part.FormName(), string(value))
+	return nil
+}
+
+func readFormFile(ctx context.Context, part *multipart.Part, form *types.Form, options types.FormOptions, maxTotalSize int64) error {
+	maxFileSize := options.MaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = maxTotalSize
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return formError(err)
+	}
+	head = head[:n]
+
+	file := &types.UploadedFile{
+		FieldName:   part.FormName(),
+		Filename:    part.FileName(),
+		ContentType: http.DetectContentType(head),
+		Header:      part.Header,
+	}
+	if !isMimeTypeAllowed(file.ContentType, options.AllowedMimeTypes) {
+		return errors.UnsupportedMediaType.Newf("file %q of type %s is not allowed", file.Filename, file.ContentType)
+	}
+
+	var dst io.WriteCloser
+	if options.Sink != nil {
+		dst, err = options.Sink(ctx, file)
+		if err != nil {
+			return err
+		}
+	} else {
+		tmp, err := os.CreateTemp(options.TempDir, "crazyhttp-upload-*")
+		if err != nil {
+			return errors.InternalServerError.Wrap(err, "could not store uploaded file")
+		}
+		file.Path = tmp.Name()
+		dst = tmp
+	}
+	// registered right away, so the file is removed if the upload fails
+	form.Files[file.FieldName] = append(form.Files[file.FieldName], file)
+
+	size, err := io.Copy(dst, io.MultiReader(
+		bytes.NewReader(head),
+		io.LimitReader(part, maxFileSize-int64(n)+1),
+	))
+	if closeErr := dst.Close(); err == nil && closeErr != nil {
+		return errors.InternalServerError.Wrap(closeErr, "could not store uploaded file")
+	}
+	if err != nil {
+		return formError(err)
+	}
+	if size > maxFileSize {
+		return errors.ContentTooLarge.Newf("file %q is too large", file.Filename)
+	}
+
+	file.Size = size
+	return nil
+}
+
+// isMimeTypeAllowed reports whether contentType matches one of allowed, which
+// may contain wildcards such as "image/*".
+func isMimeTypeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, a := range allowed {
+		if a == "*/*" || strings.EqualFold(a, mediaType) {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(mediaType, strings.ToLower(prefix)+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// formError maps errors reading a form body to ContentTooLarge when the body
+// exceeds its limit, and BadRequest otherwise.
+func formError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if stderrors.As(err, &maxBytesErr) {
+		return errors.ContentTooLarge.Wrap(err, "request body is too large")
+	}
+	return errors.BadRequest.Wrap(err, "request body is not a valid form")
+}
@@ -3,6 +3,8 @@ package http
 import (
 	"context"
 	"net/http"
+
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
 )
 
 func PopulateDefaultServerHeaders(ctx context.Context, r *http.Request, headers map[string][]string) map[string][]string {
@@ -11,6 +13,9 @@ func PopulateDefaultServerHeaders(ctx context.Context, r *http.Request, headers
 	}
 
 	headers["X-Server"] = []string{"crazyhttp"}
+	if requestId, ok := ctx.Value(constants.HttpRequestId).(string); ok {
+		headers[constants.HeaderRequestId] = []string{requestId}
+	}
 	// relay the origin back since we check for allowed origins, earlier
 	headers["Access-Control-Allow-Origin"] = []string{r.Header.Get("Origin")}
 	headers["Access-Control-Allow-Methods"] = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH", "HEAD"}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// FormatServerSentEvent frames a chunk as a Server-Sent Event, as per the HTML
+// living standard. data is the encoded payload of the chunk; multi-line data
+// is split across several data fields.
+func FormatServerSentEvent(chunk types.StreamChunk, data []byte) []byte {
+	var b bytes.Buffer
+
+	if chunk.Comment != "" {
+		b.Write(FormatServerSentComment(chunk.Comment))
+	}
+	if chunk.Event != "" {
+		b.WriteString("event: ")
+		b.WriteString(sanitizeField(chunk.Event))
+		b.WriteByte('\n')
+	}
+	if chunk.Id != 0 {
+		b.WriteString("id: ")
+		b.WriteString(strconv.FormatUint(uint64(chunk.Id), 10))
+		b.WriteByte('\n')
+	}
+	if chunk.Retry > 0 {
+		b.WriteString("retry: ")
+		b.WriteString(strconv.FormatInt(chunk.Retry.Milliseconds(), 10))
+		b.WriteByte('\n')
+	}
+	if data != nil || chunk.Event != "" || chunk.Id != 0 {
+		for _, line := range splitLines(string(data)) {
+			b.WriteString("data: ")
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// FormatServerSentComment frames comment as one or more SSE comment lines.
+// Comments are not dispatched to clients.
+func FormatServerSentComment(comment string) []byte {
+	var b bytes.Buffer
+	for _, line := range splitLines(comment) {
+		b.WriteString(": ")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// splitLines splits s on any of the line terminators allowed in an event
+// stream: CRLF, LF or CR.
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
+
+// sanitizeField strips line terminators, which would end the field early.
+func sanitizeField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
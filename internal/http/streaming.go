@@ -0,0 +1,56 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// StreamContentType returns the default content type of a streaming response
+// framed with mode.
+func StreamContentType(mode types.StreamingMode) string {
+	switch mode {
+	case types.StreamingModeNDJSON:
+		return "application/x-ndjson"
+	case types.StreamingModeJSONLines:
+		return "application/jsonl"
+	case types.StreamingModeJSONArray:
+		return "application/json"
+	default:
+		return "text/event-stream"
+	}
+}
+
+// EncodeJSONValue encodes v as a value of a JSON stream. Unlike the default
+// serialization, strings and byte slices are encoded as JSON strings, so that
+// every value is valid JSON. json.RawMessage values are kept as they are.
+func EncodeJSONValue(v interface{}) ([]byte, error) {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	return json.Marshal(v)
+}
+
+// FormatJSONLine frames an encoded JSON value as a single line. Values encoded
+// by EncodeJSONValue have their line breaks escaped, so only trailing ones need
+// trimming; custom encoders must not produce line breaks within a value.
+func FormatJSONLine(data []byte) []byte {
+	data = bytes.TrimRight(data, "\r\n")
+
+	line := make([]byte, 0, len(data)+1)
+	line = append(line, data...)
+	return append(line, '\n')
+}
+
+// FormatJSONArrayElement frames an encoded JSON value as the element at index
+// of a streamed JSON array, opening the array with the first element.
+func FormatJSONArrayElement(index int, data []byte) []byte {
+	element := make([]byte, 0, len(data)+1)
+	if index == 0 {
+		element = append(element, '[')
+	} else {
+		element = append(element, ',')
+	}
+	return append(element, bytes.TrimSpace(data)...)
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// GetRequestTimeout returns the timeout requested by the client, either through
+// a Grpc-Timeout header (e.g. "500m") or a Request-Timeout header holding a Go
+// duration or a number of seconds (e.g. "1.5").
+func GetRequestTimeout(header http.Header) (time.Duration, bool) {
+	if value := header.Get("Grpc-Timeout"); value != "" {
+		return parseGrpcTimeout(value)
+	}
+
+	if value := header.Get("Request-Timeout"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d, true
+		}
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+	}
+
+	return 0, false
+}
+
+// parseGrpcTimeout parses a timeout as per the gRPC over HTTP/2 specification,
+// i.e. at most 8 digits followed by a unit.
+func parseGrpcTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+
+	unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+
+	if n > math.MaxInt64/int64(unit) {
+		return time.Duration(math.MaxInt64), true
+	}
+
+	return time.Duration(n) * unit, true
+}
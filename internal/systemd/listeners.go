@@ -0,0 +1,63 @@
+// Package systemd receives the sockets systemd passes to socket-activated
+// services, as described in sd_listen_fds(3).
+package systemd
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd.
+const listenFdsStart = 3
+
+var (
+	once  sync.Once
+	files map[string]*os.File
+)
+
+// File returns the socket passed with the given name, set by
+// FileDescriptorName= in the socket unit, or nil if none was. Each socket is
+// returned once, as its listener takes ownership of it.
+func File(name string) *os.File {
+	once.Do(func() { files = listenFiles() })
+
+	f := files[name]
+	delete(files, name)
+	return f
+}
+
+// listenFiles collects the sockets passed to this process, by name. The
+// environment is unset so that child processes do not take them.
+func listenFiles() map[string]*os.File {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	files := make(map[string]*os.File, n)
+	for i := 0; i < n; i++ {
+		// systemd names sockets "unknown" unless configured
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		if _, ok := files[name]; ok {
+			continue
+		}
+
+		files[name] = os.NewFile(uintptr(listenFdsStart+i), name)
+	}
+	return files
+}
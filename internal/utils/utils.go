@@ -1,6 +1,10 @@
 package utils
 
-import "log"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+)
 
 func PrintStartBanner() {
 	log.Println("\033[1;34m")
@@ -15,3 +19,10 @@ func PrintStartBanner() {
 	log.Println("\033[0m\033[1;32mcrazyhttp - Faster HTTP/3-native alternative to FastAPI in Go\033[0m")
 	log.Println("\033[1;32m(Crazy-ingly fast performance)\033[0m")
 }
+
+// GenerateRequestId returns a random 128-bit hex encoded id for a request.
+func GenerateRequestId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
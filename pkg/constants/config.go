@@ -1,5 +1,7 @@
 package constants
 
+import "time"
+
 const (
 	DEFAULT_SERVER_IP_ADDRESS = ""
 	DEFAULT_SERVER_PORT       = 443
@@ -14,3 +16,13 @@ const (
 	DEFAULT_MCP_SERVER_IP_ADDRESS = ""
 	DEFAULT_MCP_SERVER_PORT       = 4432
 )
+
+const (
+	DEFAULT_READ_TIMEOUT        = 0
+	DEFAULT_READ_HEADER_TIMEOUT = 10 * time.Second
+	// write timeouts are disabled by default, as they cut long-lived streams
+	DEFAULT_WRITE_TIMEOUT = 0
+	DEFAULT_IDLE_TIMEOUT  = 120 * time.Second
+	// cap applied to timeouts requested by clients through request headers
+	DEFAULT_MAX_REQUEST_TIMEOUT = 60 * time.Second
+)
@@ -22,6 +22,12 @@ const (
 	ResponseTypeJSONResponse      ResponseTypes = 2
 )
 
+const (
+	HeaderRequestId = "X-Request-Id"
+	// set by proxies forwarding requests received in TLS early data, RFC 8470
+	HeaderEarlyData = "Early-Data"
+)
+
 type ContextKeys string
 
 const (
@@ -30,8 +36,14 @@ const (
 	HttpRequestURLParams               ContextKeys = "request_url_params"
 	HttpRequestPathValues              ContextKeys = "request_path_values"
 	RateLimitCustomKey                 ContextKeys = "rate_limit_custom_key"
+	HttpResponseState                  ContextKeys = "response_state"
+	HttpRequestURI                     ContextKeys = "request_uri"
+	HttpRequestId                      ContextKeys = "request_id"
+	LastEventId                        ContextKeys = "last_event_id"
+	EarlyData                          ContextKeys = "early_data"
 
 	// websocket specific context keys
 	WebsocketRequestChannel  ContextKeys = "websocket_request_channel"
 	WebsocketResponseChannel ContextKeys = "websocket_response_channel"
+	WebsocketConnection      ContextKeys = "websocket_connection"
 )
@@ -1,6 +1,9 @@
 package errors
 
 import (
+	"context"
+	stderrors "errors"
+	"fmt"
 	"net/http"
 
 	"github.com/pkg/errors"
@@ -10,9 +13,41 @@ type customError struct {
 	errorType     ErrorType
 	errorMessage  string
 	originalError error
+	// cause is the error wrapped by this one, if any
+	cause error
+
+	// meta is replaced rather than modified, and kept behind a pointer so
+	// that errors stay comparable with ==
+	meta *errorMeta
+}
+
+// errorMeta is the metadata attached to an error.
+type errorMeta struct {
+	errorCode   string
+	retryable   *bool
+	keyValues   []interface{}
+	fieldErrors []FieldError
+	details     map[string]interface{}
+}
+
+// metadata returns a copy of the metadata attached to err.
+func (err customError) metadata() errorMeta {
+	if err.meta == nil {
+		return errorMeta{}
+	}
+
+	return *err.meta
+}
+
+// FieldError describes a validation failure on a single field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 // defined methods on CustomError
+
+// Message returns the public message of the error, safe to be sent to clients.
 func (err customError) Message() string {
 	return err.errorMessage
 }
@@ -30,15 +65,254 @@ func (err customError) String() string {
 	return errorTypeToMessageMap[err.errorType]
 }
 
+// Error returns the internal message of the error, including its causes.
 func (err customError) Error() string {
 	return err.originalError.Error()
 }
 
+// Unwrap returns the error wrapped by err, if any.
+func (err customError) Unwrap() error {
+	return err.cause
+}
+
+// Is reports whether target is an error of the same type and message, so errors
+// declared once (e.g. var ErrUserNotFound = NotFound.New("user not found")) can
+// be matched with errors.Is.
+func (err customError) Is(target error) bool {
+	t, ok := target.(customError)
+	if !ok {
+		return false
+	}
+
+	return err.errorType == t.errorType && err.errorMessage == t.errorMessage
+}
+
 // defined methods on ErrorType
 func (errType ErrorType) New(message string) error {
-	return customError{errorType: errType, originalError: errors.New(message)}
+	return customError{errorType: errType, errorMessage: message, originalError: errors.New(message)}
 }
 
 func (errType ErrorType) Wrap(err error, message string) error {
-	return customError{errorType: errType, originalError: errors.Wrap(err, message)}
+	return customError{errorType: errType, errorMessage: message, originalError: errors.Wrap(err, message), cause: err}
+}
+
+// Newf creates an error of errType with a formatted message.
+func (errType ErrorType) Newf(format string, args ...interface{}) error {
+	err := errors.Errorf(format, args...)
+	return customError{errorType: errType, errorMessage: err.Error(), originalError: err}
+}
+
+// Wrapf wraps err into an error of errType with a formatted message.
+func (errType ErrorType) Wrapf(err error, format string, args ...interface{}) error {
+	wrapped := errors.Wrapf(err, format, args...)
+	return customError{errorType: errType, errorMessage: fmt.Sprintf(format, args...), originalError: wrapped, cause: err}
+}
+
+// TypeOf returns the type of the first error in the chain of err created by this
+// package, or mapped from the code of a gRPC status. context.DeadlineExceeded
+// and context.Canceled anywhere in the chain map to GatewayTimeout and
+// ClientClosedRequest. It returns NoType otherwise.
+func TypeOf(err error) ErrorType {
+	if err == nil {
+		return NoType
+	}
+
+	if errTyped, ok := findCustomError(err); ok {
+		return errTyped.errorType
+	}
+
+	switch {
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return GatewayTimeout
+	case stderrors.Is(err, context.Canceled):
+		return ClientClosedRequest
+	}
+
+	return NoType
+}
+
+// IsType reports whether the type of err, as per TypeOf, is errType.
+func IsType(err error, errType ErrorType) bool {
+	return TypeOf(err) == errType
+}
+
+// Cause returns the innermost error in the chain of err.
+func Cause(err error) error {
+	for err != nil {
+		next := stderrors.Unwrap(err)
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+
+	return nil
+}
+
+// PublicMessage returns the message of err which is safe to be sent to clients.
+// It is empty for errors not created by this package, since their message may
+// leak internals.
+func PublicMessage(err error) string {
+	errTyped, ok := findCustomError(err)
+	if !ok {
+		return ""
+	}
+
+	return errTyped.errorMessage
+}
+
+// WithPublicMessage replaces the message of err sent to clients, keeping the
+// internal message returned by Error intact.
+func WithPublicMessage(err error, message string) error {
+	errTyped := toCustomError(err)
+	errTyped.errorMessage = message
+
+	return errTyped
+}
+
+// WithErrorCode attaches an application specific error code to err, e.g.
+// "USER_NOT_FOUND", to be sent to clients.
+func WithErrorCode(err error, code string) error {
+	errTyped := toCustomError(err)
+	meta := errTyped.metadata()
+	meta.errorCode = code
+	errTyped.meta = &meta
+
+	return errTyped
+}
+
+// ErrorCode returns the error code attached to err, or the name of its type if
+// none was attached.
+func ErrorCode(err error) string {
+	if errTyped, ok := findCustomError(err); ok && errTyped.metadata().errorCode != "" {
+		return errTyped.metadata().errorCode
+	}
+
+	return errorTypeToMessageMap[TypeOf(err)]
+}
+
+// WithRetryable marks whether the operation which failed with err may be retried.
+func WithRetryable(err error, retryable bool) error {
+	errTyped := toCustomError(err)
+	meta := errTyped.metadata()
+	meta.retryable = &retryable
+	errTyped.meta = &meta
+
+	return errTyped
+}
+
+// IsRetryable reports whether the operation which failed with err may be
+// retried. Unless set with WithRetryable, it is true for RequestTimeout,
+// TooEarly, TooManyRequests, ServiceUnavailable and GatewayTimeout.
+func IsRetryable(err error) bool {
+	if errTyped, ok := findCustomError(err); ok && errTyped.metadata().retryable != nil {
+		return *errTyped.metadata().retryable
+	}
+
+	switch TypeOf(err) {
+	case RequestTimeout, TooEarly, TooManyRequests, ServiceUnavailable, GatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// WithKeyValues attaches key/value pairs to err, in the same form as arguments
+// to log/slog. They are meant for logging and are never sent to clients.
+func WithKeyValues(err error, keyValues ...interface{}) error {
+	errTyped := toCustomError(err)
+	meta := errTyped.metadata()
+	meta.keyValues = append(append([]interface{}{}, meta.keyValues...), keyValues...)
+	errTyped.meta = &meta
+
+	return errTyped
+}
+
+// KeyValues returns the key/value pairs attached to err, if any.
+func KeyValues(err error) []interface{} {
+	errTyped, ok := findCustomError(err)
+	if !ok {
+		return nil
+	}
+
+	return errTyped.metadata().keyValues
+}
+
+// WithFieldErrors attaches field level errors to err.
+func WithFieldErrors(err error, fieldErrors ...FieldError) error {
+	errTyped := toCustomError(err)
+	meta := errTyped.metadata()
+	meta.fieldErrors = append(append([]FieldError{}, meta.fieldErrors...), fieldErrors...)
+	errTyped.meta = &meta
+
+	return errTyped
+}
+
+// WithDetail attaches an arbitrary key/value detail to err, which is sent as an
+// extension member of the error response.
+func WithDetail(err error, key string, value interface{}) error {
+	errTyped := toCustomError(err)
+	meta := errTyped.metadata()
+
+	details := make(map[string]interface{}, len(meta.details)+1)
+	for k, v := range meta.details {
+		details[k] = v
+	}
+	details[key] = value
+	meta.details = details
+	errTyped.meta = &meta
+
+	return errTyped
+}
+
+// FieldErrors returns the field errors attached to err, if any.
+func FieldErrors(err error) []FieldError {
+	errTyped, ok := findCustomError(err)
+	if !ok {
+		return nil
+	}
+
+	return errTyped.metadata().fieldErrors
+}
+
+// Details returns the details attached to err, if any.
+func Details(err error) map[string]interface{} {
+	errTyped, ok := findCustomError(err)
+	if !ok {
+		return nil
+	}
+
+	return errTyped.metadata().details
+}
+
+// findCustomError returns the first error in the chain of err created by this
+// package, or converted from a gRPC status.
+func findCustomError(err error) (customError, bool) {
+	var errTyped customError
+	if stderrors.As(err, &errTyped) {
+		return errTyped, true
+	}
+
+	return customErrorFromGrpc(err)
+}
+
+// toCustomError returns err itself if it was created by this package, or wraps
+// it otherwise, keeping the type of its chain as per TypeOf.
+func toCustomError(err error) customError {
+	if errTyped, ok := err.(customError); ok {
+		return errTyped
+	}
+
+	errType := TypeOf(err)
+	if errType == NoType {
+		errType = InternalServerError
+	}
+
+	errTyped := customError{errorType: errType, originalError: err, cause: err}
+	if inner, ok := findCustomError(err); ok {
+		errTyped.errorMessage = inner.errorMessage
+		errTyped.meta = inner.meta
+	}
+
+	return errTyped
 }
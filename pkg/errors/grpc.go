@@ -0,0 +1,223 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+)
+
+// grpcErrorInfoDomain is the domain of the ErrorInfo details attached to gRPC
+// statuses created by this package.
+const grpcErrorInfoDomain = "crazyhttp"
+
+var errorTypeToGrpcCodeMap = map[ErrorType]codes.Code{
+	NoType: codes.Unknown,
+
+	BadRequest:                  codes.InvalidArgument,
+	Unauthorized:                codes.Unauthenticated,
+	PaymentRequired:             codes.FailedPrecondition,
+	Forbidden:                   codes.PermissionDenied,
+	NotFound:                    codes.NotFound,
+	MethodNotAllowed:            codes.Unimplemented,
+	NotAcceptable:               codes.InvalidArgument,
+	ProxyAuthenticationRequired: codes.Unauthenticated,
+	RequestTimeout:              codes.DeadlineExceeded,
+	Conflict:                    codes.AlreadyExists,
+	LengthRequired:              codes.InvalidArgument,
+	PreconditionFailed:          codes.FailedPrecondition,
+	ContentTooLarge:             codes.ResourceExhausted,
+	URITooLong:                  codes.InvalidArgument,
+	UnsupportedMediaType:        codes.InvalidArgument,
+	RangeNotSatisfiable:         codes.OutOfRange,
+	FailedDependency:            codes.FailedPrecondition,
+	TooEarly:                    codes.Unavailable,
+	TooManyRequests:             codes.ResourceExhausted,
+	UnavailableForLegalReasons:  codes.PermissionDenied,
+	ClientClosedRequest:         codes.Canceled,
+
+	InternalServerError:           codes.Internal,
+	NotImplemented:                codes.Unimplemented,
+	BadGateway:                    codes.Unavailable,
+	ServiceUnavailable:            codes.Unavailable,
+	GatewayTimeout:                codes.DeadlineExceeded,
+	HTTPVersionNotSupported:       codes.Unimplemented,
+	VariantAlsoNegotiates:         codes.Internal,
+	InsufficientStorage:           codes.ResourceExhausted,
+	LoopDetected:                  codes.Internal,
+	NotExtended:                   codes.Unimplemented,
+	NetworkAuthenticationRequired: codes.Unauthenticated,
+}
+
+// follows https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto
+var grpcCodeToErrorTypeMap = map[codes.Code]ErrorType{
+	codes.OK:                 NoType,
+	codes.Canceled:           ClientClosedRequest,
+	codes.Unknown:            InternalServerError,
+	codes.InvalidArgument:    BadRequest,
+	codes.DeadlineExceeded:   GatewayTimeout,
+	codes.NotFound:           NotFound,
+	codes.AlreadyExists:      Conflict,
+	codes.PermissionDenied:   Forbidden,
+	codes.ResourceExhausted:  TooManyRequests,
+	codes.FailedPrecondition: BadRequest,
+	codes.Aborted:            Conflict,
+	codes.OutOfRange:         BadRequest,
+	codes.Unimplemented:      NotImplemented,
+	codes.Internal:           InternalServerError,
+	codes.Unavailable:        ServiceUnavailable,
+	codes.DataLoss:           InternalServerError,
+	codes.Unauthenticated:    Unauthorized,
+}
+
+type grpcStatusError interface {
+	GRPCStatus() *status.Status
+}
+
+// GRPCStatus converts err to a gRPC status, so errors of this package returned
+// from gRPC server handlers carry the right code.
+func (err customError) GRPCStatus() *status.Status {
+	return ToGrpcStatus(err)
+}
+
+// GrpcCode returns the gRPC code for errType.
+func (errType ErrorType) GrpcCode() codes.Code {
+	code, ok := errorTypeToGrpcCodeMap[errType]
+	if !ok {
+		return codes.Unknown
+	}
+
+	return code
+}
+
+// ErrorTypeFromGrpcCode returns the error type for a gRPC code.
+func ErrorTypeFromGrpcCode(code codes.Code) ErrorType {
+	errType, ok := grpcCodeToErrorTypeMap[code]
+	if !ok {
+		return InternalServerError
+	}
+
+	return errType
+}
+
+// ToGrpcStatus converts err to a gRPC status. The public message of err is used
+// as the status message, and its field errors, error code and details are kept
+// as BadRequest and ErrorInfo status details.
+func ToGrpcStatus(err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+
+	errTyped, ok := findCustomError(err)
+	if !ok {
+		return status.New(TypeOf(err).GrpcCode(), err.Error())
+	}
+
+	st := status.New(errTyped.errorType.GrpcCode(), errTyped.errorMessage)
+	meta := errTyped.metadata()
+
+	if len(meta.fieldErrors) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, fieldError := range meta.fieldErrors {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fieldError.Field,
+				Description: fieldError.Message,
+			})
+		}
+		st = withGrpcDetail(st, badRequest)
+	}
+
+	if meta.errorCode != "" || len(meta.details) > 0 {
+		errorInfo := &errdetails.ErrorInfo{
+			Reason:   ErrorCode(errTyped),
+			Domain:   grpcErrorInfoDomain,
+			Metadata: make(map[string]string, len(meta.details)),
+		}
+		for k, v := range meta.details {
+			errorInfo.Metadata[k] = fmt.Sprint(v)
+		}
+		st = withGrpcDetail(st, errorInfo)
+	}
+
+	return st
+}
+
+// FromGrpcError converts an error returned by a gRPC client call into an error
+// of this package, with the type mapped from its code. The status message is
+// kept as the public message, and BadRequest, ErrorInfo and RetryInfo details
+// are kept as field errors, error code, details and the retryable flag.
+// Errors without a gRPC status are returned as is.
+//
+// Handlers may return gRPC errors without converting them, since the type of
+// an error is looked up the same way.
+func FromGrpcError(err error) error {
+	if errTyped, ok := findCustomError(err); ok {
+		return errTyped
+	}
+
+	return err
+}
+
+// customErrorFromGrpc converts the first gRPC status in the chain of err.
+func customErrorFromGrpc(err error) (customError, bool) {
+	grpcErr, ok := findGrpcStatusError(err)
+	if !ok {
+		return customError{}, false
+	}
+
+	st := grpcErr.GRPCStatus()
+	errTyped := customError{
+		errorType:     ErrorTypeFromGrpcCode(st.Code()),
+		errorMessage:  st.Message(),
+		originalError: err,
+		cause:         err,
+	}
+
+	var meta errorMeta
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.BadRequest:
+			for _, violation := range d.GetFieldViolations() {
+				meta.fieldErrors = append(meta.fieldErrors, FieldError{
+					Field:   violation.GetField(),
+					Message: violation.GetDescription(),
+				})
+			}
+		case *errdetails.ErrorInfo:
+			meta.errorCode = d.GetReason()
+			if len(d.GetMetadata()) > 0 {
+				meta.details = make(map[string]interface{}, len(d.GetMetadata()))
+				for k, v := range d.GetMetadata() {
+					meta.details[k] = v
+				}
+			}
+		case *errdetails.RetryInfo:
+			retryable := true
+			meta.retryable = &retryable
+		}
+	}
+	errTyped.meta = &meta
+
+	return errTyped, true
+}
+
+func findGrpcStatusError(err error) (grpcStatusError, bool) {
+	var grpcErr grpcStatusError
+	ok := stderrors.As(err, &grpcErr)
+
+	return grpcErr, ok
+}
+
+// withGrpcDetail returns st with detail attached, or st itself if the detail
+// could not be marshalled.
+func withGrpcDetail(st *status.Status, detail protoiface.MessageV1) *status.Status {
+	withDetail, err := st.WithDetails(detail)
+	if err != nil {
+		return st
+	}
+
+	return withDetail
+}
@@ -0,0 +1,92 @@
+package errors
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemDetailsContentType is the media type of an RFC 9457 Problem Details
+// response.
+const ProblemDetailsContentType = "application/problem+json"
+
+// ProblemDetails is the RFC 9457 representation of an error response.
+//
+// Fields
+//
+//	Type:       A URI reference identifying the problem type. Defaults to
+//	            "about:blank".
+//	Title:      A short summary of the problem type.
+//	Status:     The HTTP status code of the response.
+//	Detail:     An explanation specific to this occurrence of the problem.
+//	Instance:   A URI reference identifying this occurrence, usually the
+//	            request path.
+//	RequestId:  The id of the request which caused the problem.
+//	Code:       The application specific error code, if one was attached.
+//	Errors:     Field level errors, e.g. for validation failures.
+//	Extensions: Additional members serialised alongside the standard ones.
+type ProblemDetails struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Status     int                    `json:"status"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	RequestId  string                 `json:"request_id,omitempty"`
+	Code       string                 `json:"code,omitempty"`
+	Errors     []FieldError           `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// NewProblemDetails builds the problem details for err. Messages of errors not
+// created by this package are not exposed, since they may leak internals.
+func NewProblemDetails(err error) ProblemDetails {
+	status := DecodeErrorToHttpErrorStatus(err)
+
+	problem := ProblemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+
+	if title, ok := errorTypeToMessageMap[TypeOf(err)]; ok && TypeOf(err) != NoType {
+		problem.Title = title
+	}
+
+	errTyped, ok := findCustomError(err)
+	if !ok {
+		return problem
+	}
+
+	problem.Detail = errTyped.Message()
+	meta := errTyped.metadata()
+	problem.Code = meta.errorCode
+	problem.Errors = meta.fieldErrors
+	problem.Extensions = meta.details
+
+	return problem
+}
+
+// MarshalJSON serialises the standard members, with extension members inlined
+// at the top level as required by RFC 9457. Extensions never override standard
+// members.
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	type standard ProblemDetails
+	raw, err := json.Marshal(standard(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return raw, err
+	}
+
+	members := make(map[string]interface{}, len(p.Extensions))
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+
+	var standardMembers map[string]interface{}
+	if err := json.Unmarshal(raw, &standardMembers); err != nil {
+		return nil, err
+	}
+	for k, v := range standardMembers {
+		members[k] = v
+	}
+
+	return json.Marshal(members)
+}
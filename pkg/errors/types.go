@@ -41,9 +41,15 @@ const (
 	LoopDetected
 	NotExtended
 	NetworkAuthenticationRequired
+
+	// appended after the 5xx types, so that the values of existing types do
+	// not change
+	ClientClosedRequest
 )
 
 var errorTypeToMessageMap = map[ErrorType]string{
+	NoType: "UNKNOWN_ERROR",
+
 	BadRequest:                  "BAD_REQUEST_ERROR",
 	Unauthorized:                "UNAUTHORISED_ERROR",
 	PaymentRequired:             "PAYMENT_REQUIRED_ERROR",
@@ -64,6 +70,7 @@ var errorTypeToMessageMap = map[ErrorType]string{
 	TooEarly:                    "TOO_EARLY_ERROR",
 	TooManyRequests:             "TOO_MANY_REQUESTS_ERROR",
 	UnavailableForLegalReasons:  "UNAVAILABLE_FOR_LEGAL_REASONS_ERROR",
+	ClientClosedRequest:         "CLIENT_CLOSED_REQUEST_ERROR",
 
 	InternalServerError:           "INTERNAL_SERVER_ERROR",
 	NotImplemented:                "NOT_IMPLEMENTED_ERROR",
@@ -79,6 +86,8 @@ var errorTypeToMessageMap = map[ErrorType]string{
 }
 
 var errorTypeToStatusCodeMap = map[ErrorType]int{
+	NoType: 500,
+
 	BadRequest:                  400,
 	Unauthorized:                401,
 	PaymentRequired:             402,
@@ -99,6 +108,8 @@ var errorTypeToStatusCodeMap = map[ErrorType]int{
 	TooEarly:                    425,
 	TooManyRequests:             429,
 	UnavailableForLegalReasons:  451,
+	// non-standard, used by nginx when the client closes the connection
+	ClientClosedRequest: 499,
 
 	InternalServerError:           500,
 	NotImplemented:                501,
@@ -113,11 +124,21 @@ var errorTypeToStatusCodeMap = map[ErrorType]int{
 	NetworkAuthenticationRequired: 511,
 }
 
+// DecodeErrorToHttpErrorStatus returns the HTTP status code for err, looking at
+// its whole chain as per TypeOf.
 func DecodeErrorToHttpErrorStatus(err error) int {
-	errTyped, ok := err.(customError)
+	code, ok := errorTypeToStatusCodeMap[TypeOf(err)]
 	if !ok {
 		return http.StatusInternalServerError
 	}
 
-	return errTyped.Code()
+	return code
+}
+
+func (errType ErrorType) String() string {
+	if message, ok := errorTypeToMessageMap[errType]; ok {
+		return message
+	}
+
+	return errorTypeToMessageMap[NoType]
 }
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/internal/concurrency"
+	"github.com/ayushanand18/crazyhttp/internal/config"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+const defaultRetryAfter = time.Second
+
+// admissionController limits the number of requests in flight, telling rejected
+// clients when to retry.
+type admissionController struct {
+	limiter    *concurrency.Limiter
+	retryAfter time.Duration
+}
+
+func newAdmissionController(options types.ConcurrencyLimitOptions) *admissionController {
+	if options.MaxInFlight <= 0 {
+		return nil
+	}
+
+	algorithm := concurrency.Fixed
+	switch options.Algorithm {
+	case types.AdmissionAIMD:
+		algorithm = concurrency.AIMD
+	case types.AdmissionGradient:
+		algorithm = concurrency.Gradient
+	}
+
+	retryAfter := options.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = defaultRetryAfter
+	}
+
+	return &admissionController{
+		limiter: concurrency.NewLimiter(concurrency.Options{
+			Limit:         options.MaxInFlight,
+			MinLimit:      options.MinInFlight,
+			MaxLimit:      options.MaxAdaptive,
+			MaxQueue:      options.MaxQueue,
+			QueueTimeout:  options.QueueTimeout,
+			Algorithm:     algorithm,
+			TargetLatency: options.TargetLatency,
+		}),
+		retryAfter: retryAfter,
+	}
+}
+
+// concurrencyLimitOptionsFromConfig reads the concurrency limit under prefix,
+// e.g. service.http.concurrency.requests.
+func concurrencyLimitOptionsFromConfig(ctx context.Context, prefix string) types.ConcurrencyLimitOptions {
+	options := types.ConcurrencyLimitOptions{
+		MaxInFlight:   config.GetInt(ctx, prefix+".max_in_flight", 0),
+		MaxQueue:      config.GetInt(ctx, prefix+".max_queue", 0),
+		QueueTimeout:  config.GetDuration(ctx, prefix+".queue_timeout", 0),
+		RetryAfter:    config.GetDuration(ctx, prefix+".retry_after", defaultRetryAfter),
+		MinInFlight:   config.GetInt(ctx, prefix+".adaptive.min_in_flight", 0),
+		MaxAdaptive:   config.GetInt(ctx, prefix+".adaptive.max_in_flight", 0),
+		TargetLatency: config.GetDuration(ctx, prefix+".adaptive.target_latency", 0),
+	}
+
+	switch strings.ToLower(config.GetString(ctx, prefix+".adaptive.algorithm", "")) {
+	case "aimd":
+		options.Algorithm = types.AdmissionAIMD
+	case "gradient":
+		options.Algorithm = types.AdmissionGradient
+	}
+
+	return options
+}
+
+// admit acquires a slot from each of the controllers, in order. Nil controllers
+// are skipped. On rejection, a Retry-After header is set on w and a
+// ServiceUnavailable error is returned.
+func admit(ctx context.Context, w http.ResponseWriter, controllers ...*admissionController) (release func(), err error) {
+	var releases []func()
+	release = func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	for _, controller := range controllers {
+		if controller == nil {
+			continue
+		}
+
+		r, err := controller.limiter.Acquire(ctx)
+		if err != nil {
+			release()
+
+			if ctx.Err() != nil {
+				return nil, errors.ClientClosedRequest.Wrap(err, "request canceled while queued")
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(controller.retryAfter.Seconds()))))
+			return nil, errors.WithRetryable(errors.ServiceUnavailable.Wrap(err, "server is overloaded, retry later"), true)
+		}
+		releases = append(releases, r)
+	}
+
+	return release, nil
+}
+
+// admitMethod admits a request to m through the route limiter and the server
+// limiter for its kind, streaming responses being limited separately.
+func (s *server) admitMethod(ctx context.Context, w http.ResponseWriter, m *method) (func(), error) {
+	serverAdmission := s.requestAdmission
+	if m.isStreaming() {
+		serverAdmission = s.streamAdmission
+	}
+
+	return admit(ctx, w, m.admission, serverAdmission)
+}
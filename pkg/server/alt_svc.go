@@ -0,0 +1,161 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/internal/config"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+const defaultAltSvcMaxAge = 30 * 24 * time.Hour
+
+// altSvc advertises the HTTP/3 server on HTTP/1.1 and HTTP/2 responses.
+type altSvc struct {
+	options types.AltSvcOptions
+
+	// the Alt-Svc header, empty until the HTTP/3 server is listening
+	value   atomic.Value
+	cleared atomic.Bool
+}
+
+func newAltSvc(options types.AltSvcOptions) *altSvc {
+	a := &altSvc{}
+	a.configure(options)
+	return a
+}
+
+// altSvcOptionsFromConfig reads the Alt-Svc options under
+// service.http.alt_svc.
+func altSvcOptionsFromConfig(ctx context.Context) types.AltSvcOptions {
+	prefix := "service.http.alt_svc"
+	return types.AltSvcOptions{
+		Disabled: !config.GetBool(ctx, prefix+".enabled", true),
+		MaxAge:   config.GetDuration(ctx, prefix+".max_age", 0),
+		Host:     config.GetString(ctx, prefix+".host", ""),
+		Port:     config.GetInt(ctx, prefix+".port", 0),
+		Persist:  config.GetBool(ctx, prefix+".persist", false),
+		DNSName:  config.GetString(ctx, prefix+".dns_name", ""),
+	}
+}
+
+func (a *altSvc) configure(options types.AltSvcOptions) {
+	if options.MaxAge <= 0 {
+		options.MaxAge = defaultAltSvcMaxAge
+	}
+	a.options = options
+}
+
+// listening starts advertising the HTTP/3 server bound to h3Port. tlsPort is
+// the port of the HTTPS server, or 0 if disabled.
+func (a *altSvc) listening(ctx context.Context, h3Port, tlsPort int) {
+	if a.options.Disabled {
+		return
+	}
+
+	port := h3Port
+	if a.options.Port > 0 {
+		port = a.options.Port
+	}
+
+	value := fmt.Sprintf(`h3="%s"; ma=%d`, net.JoinHostPort(a.options.Host, strconv.Itoa(port)), int64(a.options.MaxAge/time.Second))
+	if a.options.Persist {
+		value += "; persist=1"
+	}
+	a.value.Store(value)
+
+	if a.options.DNSName != "" {
+		slog.InfoContext(ctx, "Publish this DNS record to advertise HTTP/3", "record", httpsRecord(a.options.DNSName, port, tlsPort))
+	}
+}
+
+// clear tells clients to stop using the HTTP/3 server, as it shuts down.
+func (a *altSvc) clear() {
+	a.cleared.Store(true)
+}
+
+// header returns the Alt-Svc header to send, or "" if none.
+func (a *altSvc) header() string {
+	if a.cleared.Load() {
+		if _, ok := a.value.Load().(string); ok {
+			return "clear"
+		}
+		return ""
+	}
+	value, _ := a.value.Load().(string)
+	return value
+}
+
+// advertise sets the Alt-Svc header on the responses of next, once they are
+// sent, so responses in flight on shutdown clear it.
+func (a *altSvc) advertise(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&altSvcWriter{ResponseWriter: w, a: a}, r)
+	})
+}
+
+// altSvcWriter sets the Alt-Svc header before the response header is
+// written.
+type altSvcWriter struct {
+	http.ResponseWriter
+	a           *altSvc
+	wroteHeader bool
+}
+
+func (w *altSvcWriter) WriteHeader(statusCode int) {
+	// informational responses are followed by the final one
+	if !w.wroteHeader && statusCode >= http.StatusOK {
+		w.wroteHeader = true
+		if value := w.a.header(); value != "" {
+			w.Header().Set("Alt-Svc", value)
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *altSvcWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *altSvcWriter) Flush() {
+	_ = w.FlushError()
+}
+
+func (w *altSvcWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *altSvcWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+func (w *altSvcWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// httpsRecord returns an HTTPS DNS record advertising HTTP/3 on h3Port, and
+// HTTP/2 as well if served on the same port.
+func httpsRecord(name string, h3Port, tlsPort int) string {
+	params := []string{`alpn="h3"`, "no-default-alpn"}
+	if tlsPort == h3Port {
+		params = []string{`alpn="h3,h2"`}
+	}
+	if h3Port != 443 {
+		params = append(params, "port="+strconv.Itoa(h3Port))
+	}
+	return fmt.Sprintf("%s. 3600 IN HTTPS 1 . %s", strings.TrimSuffix(name, "."), strings.Join(params, " "))
+}
@@ -0,0 +1,431 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"github.com/gorilla/mux"
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+// capsule types of CONNECT-IP, see RFC 9484
+const (
+	capsuleTypeAddressAssign      uint64 = 0x01
+	capsuleTypeAddressRequest     uint64 = 0x02
+	capsuleTypeRouteAdvertisement uint64 = 0x03
+)
+
+const (
+	// addresses requested by the client not yet received by the handler
+	maxPendingAddressRequests = 64
+	ipProtocolICMP            = 1
+	ipProtocolICMPv6          = 58
+)
+
+// serveIP serves a CONNECT-IP request (RFC 9484) with the IP handler, which
+// exchanges IP packets in datagrams with context ID 0.
+func (p *connectProxy) serveIP(w http.ResponseWriter, r *http.Request) {
+	if extendedConnectProtocol(r) != "connect-ip" {
+		p.s.writeError(r.Context(), w, r, nil, nil, errors.BadRequest.New("expected a CONNECT-IP request"))
+		return
+	}
+
+	vars := mux.Vars(r)
+	target, scope, err := parseIPTarget(vars["target"], vars["ipproto"])
+	if err != nil {
+		p.s.writeError(r.Context(), w, r, nil, nil, err)
+		return
+	}
+
+	ctx, release, err := p.accept(w, r, target)
+	if err != nil {
+		p.s.writeError(ctx, w, r, nil, nil, err)
+		return
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tunnel := &ipTunnel{
+		p:         p,
+		ctx:       ctx,
+		target:    target,
+		scope:     scope,
+		requested: make(chan struct{}, 1),
+	}
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.ErrorContext(ctx, "panic recovered in CONNECT-IP tunnel", "panic:=", rec, "stack", string(debug.Stack()))
+			err = errors.InternalServerError.New("panic in handler")
+		}
+		if tunnel.datagrams == nil {
+			return
+		}
+
+		// once the status is sent, errors can only abort the stream
+		if err != nil {
+			slog.ErrorContext(ctx, "error in serving CONNECT-IP tunnel", append([]interface{}{"err:=", err}, errors.KeyValues(err)...)...)
+			tunnel.datagrams.abort()
+			return
+		}
+		tunnel.datagrams.close()
+	}()
+
+	w.Header().Set("Capsule-Protocol", "?1")
+	tunnel.datagrams, err = newDatagramConn(ctx, cancel, w, r, p.options.Datagram, tunnel.onCapsule)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not open CONNECT-IP tunnel", "err:=", err, "target", target.Host)
+		err = nil
+		return
+	}
+
+	err = p.ipHandler(ctx, target, tunnel)
+}
+
+// parseIPTarget parses the variables of a CONNECT-IP URI template, and
+// returns the prefix the tunnel is scoped to, invalid unless the target is an
+// IP address or prefix.
+func parseIPTarget(host, ipproto string) (types.ProxyTarget, netip.Prefix, error) {
+	target := types.ProxyTarget{Network: "ip", Host: host, IPProtocol: -1}
+	var scope netip.Prefix
+
+	if ipproto != "*" {
+		protocol, err := strconv.Atoi(ipproto)
+		if err != nil || protocol < 0 || protocol > 255 {
+			return target, scope, errors.BadRequest.Newf("invalid CONNECT-IP protocol %q", ipproto)
+		}
+		target.IPProtocol = protocol
+	}
+
+	switch {
+	case host == "":
+		return target, scope, errors.BadRequest.New("missing CONNECT-IP target")
+	case host == "*":
+	case strings.Contains(host, "/"):
+		prefix, err := netip.ParsePrefix(host)
+		if err != nil {
+			return target, scope, errors.BadRequest.Wrapf(err, "invalid CONNECT-IP target %q", host)
+		}
+		scope = prefix.Masked()
+	default:
+		// host names are resolved by the handler, which advertises routes
+		// to their addresses
+		if addr, err := netip.ParseAddr(host); err == nil {
+			if addr.Zone() != "" {
+				return target, scope, errors.BadRequest.Newf("invalid CONNECT-IP target %q", host)
+			}
+			scope = netip.PrefixFrom(addr, addr.BitLen())
+		}
+	}
+
+	return target, scope, nil
+}
+
+// ipTunnel implements types.IPTunnel over the datagrams of a CONNECT-IP
+// request.
+type ipTunnel struct {
+	p         *connectProxy
+	ctx       context.Context
+	datagrams *datagramConn
+	target    types.ProxyTarget
+	scope     netip.Prefix
+
+	mu       sync.Mutex
+	assigned []netip.Prefix
+	routes   []types.IPRoute
+	pending  []types.IPAddress
+	// signaled once addresses are requested
+	requested chan struct{}
+}
+
+func (t *ipTunnel) ReadPacket(ctx context.Context) ([]byte, error) {
+	for {
+		datagram, err := t.datagrams.ReceiveDatagram(ctx)
+		if err != nil {
+			return nil, err
+		}
+		contextID, n, err := quicvarint.Parse(datagram)
+		if err != nil || contextID != 0 {
+			// unknown context IDs belong to extensions we do not support
+			continue
+		}
+
+		packet := datagram[n:]
+		if err := t.checkPacket(packet); err != nil {
+			slog.DebugContext(ctx, "dropping CONNECT-IP packet", "err:=", err, "target", t.target.Host)
+			continue
+		}
+		return packet, nil
+	}
+}
+
+func (t *ipTunnel) WritePacket(packet []byte) error {
+	return t.datagrams.SendDatagram(append(quicvarint.Append(nil, 0), packet...))
+}
+
+func (t *ipTunnel) AssignAddresses(addresses ...types.IPAddress) error {
+	var value []byte
+	var assigned []netip.Prefix
+	for _, address := range addresses {
+		if !address.Prefix.IsValid() || address.Prefix.Addr().Zone() != "" {
+			return errors.InternalServerError.Newf("invalid assigned address %s", address.Prefix)
+		}
+		value = appendIPAddress(value, address.RequestId, address.Prefix)
+
+		// unspecified addresses reject requests
+		if !address.Prefix.Addr().IsUnspecified() {
+			assigned = append(assigned, address.Prefix.Masked())
+		}
+	}
+
+	t.mu.Lock()
+	t.assigned = assigned
+	t.mu.Unlock()
+
+	return t.datagrams.sendCapsule(capsuleTypeAddressAssign, value)
+}
+
+func (t *ipTunnel) AdvertiseRoutes(routes ...types.IPRoute) error {
+	routes = append([]types.IPRoute(nil), routes...)
+	for _, route := range routes {
+		if !route.Start.IsValid() || !route.End.IsValid() || route.Start.Is4() != route.End.Is4() ||
+			route.Start.Zone() != "" || route.End.Zone() != "" || route.End.Less(route.Start) {
+			return errors.InternalServerError.Newf("invalid route %s-%s", route.Start, route.End)
+		}
+	}
+
+	// ranges are ordered by IP version, then protocol, then address
+	sort.Slice(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if a.Start.Is4() != b.Start.Is4() {
+			return a.Start.Is4()
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Start.Less(b.Start)
+	})
+
+	var value []byte
+	for i, route := range routes {
+		if i > 0 {
+			previous := routes[i-1]
+			if previous.Start.Is4() == route.Start.Is4() && previous.Protocol == route.Protocol && !previous.End.Less(route.Start) {
+				return errors.InternalServerError.Newf("route %s-%s overlaps %s-%s", route.Start, route.End, previous.Start, previous.End)
+			}
+		}
+		value = appendIPVersion(value, route.Start)
+		value = append(value, route.Start.AsSlice()...)
+		value = append(value, route.End.AsSlice()...)
+		value = append(value, route.Protocol)
+	}
+
+	t.mu.Lock()
+	t.routes = routes
+	t.mu.Unlock()
+
+	return t.datagrams.sendCapsule(capsuleTypeRouteAdvertisement, value)
+}
+
+func (t *ipTunnel) ReceiveAddressRequest(ctx context.Context) ([]types.IPAddress, error) {
+	for {
+		if addresses := t.takePending(); addresses != nil {
+			return addresses, nil
+		}
+
+		select {
+		case <-t.requested:
+		case <-t.datagrams.done:
+			// requests received before the end of the request stream first
+			if addresses := t.takePending(); addresses != nil {
+				return addresses, nil
+			}
+			return nil, t.datagrams.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (t *ipTunnel) takePending() []types.IPAddress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	addresses := t.pending
+	t.pending = nil
+	return addresses
+}
+
+// onCapsule queues the addresses requested by the client. Addresses and
+// routes sent by the client are ignored, as the proxy forwards its packets
+// to the routes advertised by the handler only.
+func (t *ipTunnel) onCapsule(capsuleType uint64, value []byte) error {
+	if capsuleType != capsuleTypeAddressRequest {
+		return nil
+	}
+
+	addresses, err := parseIPAddresses(value)
+	if err != nil {
+		return errors.BadRequest.Wrap(err, "invalid ADDRESS_REQUEST capsule")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.pending)+len(addresses) > maxPendingAddressRequests {
+		return errors.BadRequest.New("too many pending address requests")
+	}
+	t.pending = append(t.pending, addresses...)
+	select {
+	case t.requested <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// checkPacket returns an error if a packet from the client must not be
+// forwarded: its source address must be assigned to the client, and its
+// destination and protocol be within the advertised routes and the scope of
+// the tunnel. ICMP is allowed regardless of the protocol, see RFC 9484.
+func (t *ipTunnel) checkPacket(packet []byte) error {
+	source, destination, protocol, err := parseIPHeader(packet)
+	if err != nil {
+		return err
+	}
+	icmp := (destination.Is4() && protocol == ipProtocolICMP) || (destination.Is6() && protocol == ipProtocolICMPv6)
+
+	if t.scope.IsValid() && !t.scope.Contains(destination) {
+		return fmt.Errorf("destination %s is out of the scope of the tunnel", destination)
+	}
+	if !icmp && t.target.IPProtocol >= 0 && int(protocol) != t.target.IPProtocol {
+		return fmt.Errorf("protocol %d is out of the scope of the tunnel", protocol)
+	}
+	if err := t.p.checkAddr(destination); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	assigned := false
+	for _, prefix := range t.assigned {
+		if prefix.Contains(source) {
+			assigned = true
+			break
+		}
+	}
+	if !assigned {
+		return fmt.Errorf("source %s is not assigned to the client", source)
+	}
+
+	for _, route := range t.routes {
+		if route.Start.Is4() != destination.Is4() || destination.Less(route.Start) || route.End.Less(destination) {
+			continue
+		}
+		if icmp || route.Protocol == 0 || route.Protocol == protocol {
+			return nil
+		}
+	}
+	return fmt.Errorf("no route to %s for protocol %d", destination, protocol)
+}
+
+// parseIPHeader returns the source and destination addresses and the
+// protocol of an IPv4 or IPv6 packet. The protocol of an IPv6 packet is its
+// first next header.
+func parseIPHeader(packet []byte) (source, destination netip.Addr, protocol uint8, err error) {
+	if len(packet) == 0 {
+		return source, destination, 0, fmt.Errorf("empty packet")
+	}
+
+	switch packet[0] >> 4 {
+	case 4:
+		if len(packet) < 20 {
+			return source, destination, 0, fmt.Errorf("truncated IPv4 header")
+		}
+		source = netip.AddrFrom4([4]byte(packet[12:16]))
+		destination = netip.AddrFrom4([4]byte(packet[16:20]))
+		return source, destination, packet[9], nil
+	case 6:
+		if len(packet) < 40 {
+			return source, destination, 0, fmt.Errorf("truncated IPv6 header")
+		}
+		source = netip.AddrFrom16([16]byte(packet[8:24]))
+		destination = netip.AddrFrom16([16]byte(packet[24:40]))
+		return source, destination, packet[6], nil
+	default:
+		return source, destination, 0, fmt.Errorf("unknown IP version %d", packet[0]>>4)
+	}
+}
+
+// parseIPAddresses parses the addresses of an ADDRESS_REQUEST capsule, each
+// made of a request id, an IP version, an address and a prefix length.
+func parseIPAddresses(value []byte) ([]types.IPAddress, error) {
+	var addresses []types.IPAddress
+	for len(value) > 0 {
+		requestId, n, err := quicvarint.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+		if requestId == 0 {
+			return nil, fmt.Errorf("request id must not be zero")
+		}
+		value = value[n:]
+
+		if len(value) == 0 {
+			return nil, fmt.Errorf("truncated address")
+		}
+		size := 0
+		switch value[0] {
+		case 4:
+			size = 4
+		case 6:
+			size = 16
+		default:
+			return nil, fmt.Errorf("unknown IP version %d", value[0])
+		}
+		value = value[1:]
+
+		if len(value) < size+1 {
+			return nil, fmt.Errorf("truncated address")
+		}
+		addr, _ := netip.AddrFromSlice(value[:size])
+		bits := int(value[size])
+		if bits > addr.BitLen() {
+			return nil, fmt.Errorf("invalid prefix length %d", bits)
+		}
+		value = value[size+1:]
+
+		addresses = append(addresses, types.IPAddress{RequestId: requestId, Prefix: netip.PrefixFrom(addr, bits)})
+	}
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no address requested")
+	}
+	return addresses, nil
+}
+
+// appendIPAddress appends an address of an ADDRESS_ASSIGN capsule to b.
+func appendIPAddress(b []byte, requestId uint64, prefix netip.Prefix) []byte {
+	b = quicvarint.Append(b, requestId)
+	b = appendIPVersion(b, prefix.Addr())
+	b = append(b, prefix.Addr().AsSlice()...)
+	return append(b, byte(prefix.Bits()))
+}
+
+func appendIPVersion(b []byte, addr netip.Addr) []byte {
+	if addr.Is4() {
+		return append(b, 4)
+	}
+	return append(b, 6)
+}
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	ashttp "github.com/ayushanand18/crazyhttp/internal/http"
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"github.com/quic-go/quic-go"
+	qchttp3 "github.com/quic-go/quic-go/http3"
+)
+
+const (
+	defaultDatagramReceiveBufferSize = 64
+	defaultMaxDatagramSize           = 64 * 1024
+	// how long to wait for the client's HTTP/3 SETTINGS, to learn whether it
+	// supports datagrams
+	datagramSettingsTimeout = 5 * time.Second
+	// H3_NO_ERROR and H3_REQUEST_CANCELLED
+	h3NoError          quic.StreamErrorCode = 0x100
+	h3RequestCancelled quic.StreamErrorCode = 0x10c
+)
+
+// datagramHandler serves a request exchanging HTTP Datagrams (RFC 9297). Over
+// HTTP/3 with datagrams enabled by the client, they are sent as QUIC
+// datagrams, and as DATAGRAM capsules on the request stream otherwise.
+func datagramHandler(
+	ctx context.Context,
+	w http.ResponseWriter,
+	handler types.DatagramHandlerFunc,
+	r *http.Request,
+	m *method,
+) {
+	var conn *datagramConn
+	var err error
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.ErrorContext(ctx, "panic recovered in datagram handler", "panic:=", rec, "stack", string(debug.Stack()))
+			err = errors.InternalServerError.New("panic in handler")
+		}
+
+		if err == nil {
+			if conn != nil {
+				conn.close()
+			}
+			return
+		}
+
+		// once the status is sent, errors can only abort the stream
+		if conn != nil {
+			slog.ErrorContext(ctx, "error in serving datagrams", append([]interface{}{"err:=", err}, errors.KeyValues(err)...)...)
+			conn.abort()
+			return
+		}
+
+		m.s.writeError(ctx, w, r, m.errorEncoder, nil, err)
+	}()
+
+	ctx, err = defaultMiddleware(ctx, r)
+	if err != nil {
+		slog.ErrorContext(ctx, "error in default middlewares", "err:=", err)
+		return
+	}
+
+	if len(m.options.AllowedOrigins) > 0 && !ashttp.IsOriginAllowed(r.Header.Get("Origin"), m.options.AllowedOrigins) {
+		slog.ErrorContext(ctx, "origin not allowed", "origin", r.Header.Get("Origin"))
+		err = errors.Forbidden.New("origin not allowed")
+		return
+	}
+
+	if m.rateLimiter != nil {
+		key := ctx.Value(constants.RateLimitCustomKey)
+		if key == nil || key == "" {
+			key = strings.Split(r.RemoteAddr, ":")[0]
+		}
+		k, ok := key.(string)
+		if !ok {
+			slog.ErrorContext(ctx, "rate limit key is not a string", "key:=", key)
+			err = errors.InternalServerError.New("rate limit key is not a string")
+			return
+		}
+		m.rateLimiter.Allow(k)
+	}
+
+	// the request body carries capsules, so middlewares get no request
+	for _, mw := range m.beforeServeMiddlewares {
+		ctx, _, err = mw(ctx, nil)
+		if err != nil {
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn, err = newDatagramConn(ctx, cancel, w, r, m.options.Datagram, nil)
+	if err != nil {
+		return
+	}
+
+	err = handler(ctx, conn)
+}
+
+// datagramConn implements types.DatagramConn over QUIC datagrams, or
+// capsules on the request stream.
+type datagramConn struct {
+	ctx      context.Context
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	r        *http.Request
+	str      qchttp3.Stream
+	capsules bool
+	// called with the capsules of other types than DATAGRAM, if not nil
+	onCapsule func(capsuleType uint64, value []byte) error
+
+	received chan []byte
+	// closed once the client closed the request stream, after setting err
+	done chan struct{}
+	err  error
+
+	writeMu sync.Mutex
+}
+
+// newDatagramConn sends the response status of r, and then exchanges its
+// datagrams until the client closes the request stream, canceling ctx.
+// Capsules of other types are passed to onCapsule, or skipped if nil.
+func newDatagramConn(ctx context.Context, cancel context.CancelFunc, w http.ResponseWriter, r *http.Request, options types.DatagramOptions, onCapsule func(capsuleType uint64, value []byte) error) (*datagramConn, error) {
+	bufferSize := options.ReceiveBufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultDatagramReceiveBufferSize
+	}
+	maxSize := options.MaxDatagramSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxDatagramSize
+	}
+
+	c := &datagramConn{
+		ctx:       ctx,
+		w:         w,
+		rc:        http.NewResponseController(w),
+		r:         r,
+		capsules:  !quicDatagramsEnabled(ctx, w),
+		onCapsule: onCapsule,
+		received:  make(chan []byte, bufferSize),
+		done:      make(chan struct{}),
+	}
+
+	// HTTP/1.1 servers stop reading the request body once the response
+	// starts, unless full duplex
+	_ = c.rc.EnableFullDuplex()
+
+	if c.capsules {
+		w.Header().Set("Capsule-Protocol", "?1")
+	}
+	populateHeaders(ashttp.PopulateDefaultServerHeaders(ctx, r, nil), w)
+	status := http.StatusOK
+	if code := applyResponseState(ctx, w); code != 0 {
+		status = code
+	}
+	w.WriteHeader(status)
+	if err := c.rc.Flush(); err != nil {
+		return nil, errors.ClientClosedRequest.Wrap(err, "could not send response")
+	}
+
+	if !c.capsules {
+		c.str = w.(qchttp3.HTTPStreamer).HTTPStream()
+		go func() {
+			for {
+				data, err := c.str.ReceiveDatagram(ctx)
+				if err != nil {
+					return
+				}
+				c.enqueue(data)
+			}
+		}()
+	}
+
+	// capsules may be sent on the request stream even with QUIC datagrams
+	go func() {
+		defer cancel()
+		c.err = c.readCapsules(maxSize)
+		close(c.done)
+	}()
+
+	return c, nil
+}
+
+// quicDatagramsEnabled reports whether the request is an HTTP/3 request from
+// a client which enabled datagrams.
+func quicDatagramsEnabled(ctx context.Context, w http.ResponseWriter) bool {
+	hijacker, ok := w.(qchttp3.Hijacker)
+	if !ok {
+		return false
+	}
+	if _, ok := w.(qchttp3.HTTPStreamer); !ok {
+		return false
+	}
+
+	conn := hijacker.Connection()
+	timer := time.NewTimer(datagramSettingsTimeout)
+	defer timer.Stop()
+	select {
+	case <-conn.ReceivedSettings():
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+	return conn.Settings().EnableDatagrams
+}
+
+func (c *datagramConn) SendDatagram(data []byte) error {
+	if !c.capsules {
+		return c.str.SendDatagram(data)
+	}
+	return c.sendCapsule(ashttp.CapsuleTypeDatagram, data)
+}
+
+// sendCapsule sends a capsule on the response stream.
+func (c *datagramConn) sendCapsule(capsuleType uint64, value []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if _, err := c.w.Write(ashttp.AppendCapsule(nil, capsuleType, value)); err != nil {
+		return errors.ClientClosedRequest.Wrap(err, "could not send capsule")
+	}
+	if err := c.rc.Flush(); err != nil {
+		return errors.ClientClosedRequest.Wrap(err, "could not send capsule")
+	}
+	return nil
+}
+
+func (c *datagramConn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case data := <-c.received:
+		return data, nil
+	case <-c.done:
+	case <-ctx.Done():
+	}
+
+	// the end of the request stream cancels ctx, but datagrams queued before
+	// it are still received, and then io.EOF
+	select {
+	case data := <-c.received:
+		return data, nil
+	default:
+	}
+	select {
+	case <-c.done:
+		return nil, c.err
+	default:
+		return nil, ctx.Err()
+	}
+}
+
+func (c *datagramConn) Capsules() bool {
+	return c.capsules
+}
+
+// readCapsules queues the DATAGRAM capsules of the request body until it
+// ends, passing capsules of other types to onCapsule.
+func (c *datagramConn) readCapsules(maxSize int) error {
+	r := bufio.NewReader(c.r.Body)
+	for {
+		capsuleType, value, err := ashttp.ReadCapsule(r, maxSize)
+		if err == io.EOF {
+			return io.EOF
+		}
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return errors.ClientClosedRequest.Wrap(err, "request stream closed")
+			}
+			return errors.BadRequest.Wrap(err, "could not read capsule")
+		}
+
+		switch {
+		case capsuleType == ashttp.CapsuleTypeDatagram:
+			if err := c.deliver(value); err != nil {
+				return err
+			}
+		case c.onCapsule != nil:
+			if err := c.onCapsule(capsuleType, value); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// enqueue queues a QUIC datagram, dropping it if the handler is not keeping
+// up, as QUIC datagrams are unreliable anyway.
+func (c *datagramConn) enqueue(data []byte) {
+	select {
+	case c.received <- data:
+	default:
+		slog.DebugContext(c.ctx, "dropping datagram, receive buffer is full")
+	}
+}
+
+// deliver queues a datagram received in a capsule, waiting for the handler to
+// catch up if the buffer is full, since capsules are delivered reliably. Not
+// reading the request body meanwhile applies backpressure to the client.
+func (c *datagramConn) deliver(data []byte) error {
+	select {
+	case c.received <- data:
+		return nil
+	case <-c.ctx.Done():
+		return errors.ClientClosedRequest.Wrap(c.ctx.Err(), "request stream closed")
+	}
+}
+
+// close ends the response once the handler returned. A hijacked HTTP/3
+// stream is not cleaned up by the server, so the request side is canceled if
+// the client left it open.
+func (c *datagramConn) close() {
+	if c.str == nil {
+		return
+	}
+	_ = c.str.Close()
+	select {
+	case <-c.done:
+	default:
+		c.str.CancelRead(h3NoError)
+	}
+}
+
+// abort resets the request stream, so the client can tell the handler
+// failed.
+func (c *datagramConn) abort() {
+	if c.str != nil {
+		c.str.CancelRead(h3RequestCancelled)
+		c.str.CancelWrite(h3RequestCancelled)
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+)
+
+// IsEarlyData reports whether the request was received in 0-RTT data, which
+// an attacker may have replayed, either by this server or by a proxy in front
+// of it.
+func IsEarlyData(ctx context.Context) bool {
+	earlyData, _ := ctx.Value(constants.EarlyData).(bool)
+	return earlyData
+}
+
+// isEarlyData reports whether r was received before the handshake completed,
+// or forwarded by a proxy which did. Only HTTP/3 requests can be early, as
+// crypto/tls does not accept early data over TCP.
+func isEarlyData(r *http.Request) bool {
+	if r.Header.Get(constants.HeaderEarlyData) == "1" {
+		return true
+	}
+	return r.ProtoMajor == 3 && r.TLS != nil && !r.TLS.HandshakeComplete
+}
+
+// rejectEarlyData returns a TooEarly error for early requests with unsafe
+// methods, or upgrading the connection, unless allowed, so clients retry them
+// once the handshake completed (RFC 8470).
+func rejectEarlyData(r *http.Request, allow bool) error {
+	if allow || !isEarlyData(r) {
+		return nil
+	}
+
+	if r.Header.Get("Upgrade") != "" {
+		return errors.TooEarly.New("upgrade requests are not accepted in early data")
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return nil
+	}
+	return errors.TooEarly.Newf("%s requests are not accepted in early data", r.Method)
+}
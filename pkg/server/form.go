@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+var uploadedFileType = reflect.TypeOf(&types.UploadedFile{})
+
+// DecodeFormRequest binds a form request, as passed to handlers, to a struct.
+// Fields are matched by their `form` tag, or their name otherwise, and may be
+// strings, booleans, numbers, *types.UploadedFile or slices of these.
+func DecodeFormRequest[T any](in interface{}) (T, error) {
+	var out T
+
+	form, ok := in.(*types.Form)
+	if !ok {
+		return out, errors.UnsupportedMediaType.New("request body is not a form")
+	}
+
+	v := reflect.ValueOf(&out).Elem()
+	if v.Kind() != reflect.Struct {
+		return out, errors.InternalServerError.Newf("cannot bind a form to %s", v.Type())
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("form"); ok {
+			name, _, _ = strings.Cut(tag, ",")
+		}
+		if name == "-" {
+			continue
+		}
+
+		if err := bindFormField(v.Field(i), form.Values[name], form.Files[name]); err != nil {
+			return out, errors.WithFieldErrors(
+				errors.BadRequest.Wrapf(err, "invalid form field %q", name),
+				errors.FieldError{Field: name, Message: err.Error()},
+			)
+		}
+	}
+
+	return out, nil
+}
+
+func bindFormField(field reflect.Value, values []string, files []*types.UploadedFile) error {
+	switch {
+	case field.Type() == uploadedFileType:
+		if len(files) > 0 {
+			field.Set(reflect.ValueOf(files[0]))
+		}
+		return nil
+	case field.Kind() == reflect.Slice && field.Type().Elem() == uploadedFileType:
+		field.Set(reflect.ValueOf(files))
+		return nil
+	case field.Kind() == reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setFormValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	case len(values) > 0:
+		return setFormValue(field, values[0])
+	}
+
+	return nil
+}
+
+func setFormValue(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return errors.InternalServerError.Newf("cannot bind a form value to %s", field.Type())
+	}
+
+	return nil
+}
+
+// removeForm removes the temporary files of a form once its handler returns.
+func removeForm(ctx context.Context, form *types.Form) {
+	if err := form.RemoveAll(); err != nil {
+		slog.ErrorContext(ctx, "error in removing uploaded files", "err:=", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	ashttp "github.com/ayushanand18/crazyhttp/internal/http"
+	"github.com/ayushanand18/crazyhttp/internal/utils"
 	"github.com/ayushanand18/crazyhttp/pkg/constants"
 	"github.com/ayushanand18/crazyhttp/pkg/errors"
 	"github.com/ayushanand18/crazyhttp/pkg/types"
@@ -34,25 +35,7 @@ func httpDefaultHandler(
 		}
 
 		if err != nil {
-			headers := make(map[string][]string)
-			var body []byte
-			var resErr error
-
-			if m.errorEncoder != nil {
-				headers, body, resErr = m.errorEncoder(ctx, response, err)
-			}
-
-			if resErr != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				slog.ErrorContext(ctx, "error in encoding error response", "err:=", resErr)
-				return
-			}
-
-			errCode := errors.DecodeErrorToHttpErrorStatus(err)
-			w.WriteHeader(errCode)
-
-			populateHeaders(headers, w)
-			populateBody(w, body)
+			m.s.writeError(ctx, w, r, m.errorEncoder, response, err)
 			return
 		}
 	}()
@@ -64,27 +47,24 @@ func httpDefaultHandler(
 	}
 
 	if len(m.options.AllowedOrigins) > 0 && !ashttp.IsOriginAllowed(r.Header.Get("Origin"), m.options.AllowedOrigins) {
-		w.WriteHeader(http.StatusForbidden)
 		slog.ErrorContext(ctx, "origin not allowed", "origin", r.Header.Get("Origin"))
-		err = nil
+		err = errors.Forbidden.New("origin not allowed")
 		return
 	}
 
-	if decoder != nil {
-		ctx, request, err = decoder(ctx, r)
-		if err != nil {
-			slog.ErrorContext(ctx, "error in decoding request", "err:=", err)
-			err = nil
-			return
-		}
-	} else {
-		ctx, request, err = ashttp.DefaultHttpDecode(ctx, r)
-		if err != nil {
-			slog.ErrorContext(ctx, "error in decoding headers", "err:=", err)
-			err = nil
-			return
-		}
+	ctx, request, err = m.decodeRequest(ctx, r, decoder)
+	if err != nil {
+		return
+	}
+	var cleanup func()
+	if form, ok := request.(*types.Form); ok {
+		cleanup = func() { removeForm(ctx, form) }
 	}
+	defer func() {
+		if cleanup != nil {
+			cleanup()
+		}
+	}()
 
 	if m.rateLimiter != nil {
 		key := ctx.Value(constants.RateLimitCustomKey)
@@ -103,9 +83,15 @@ func httpDefaultHandler(
 
 	for _, mw := range m.beforeServeMiddlewares {
 		ctx, request, err = mw(ctx, request)
+		if err != nil {
+			return
+		}
 	}
 
-	response, err = handler(ctx, request)
+	// files of the form are removed once the handler returns, even if it
+	// outlives its deadline
+	response, err = serveWithTimeout(ctx, handler, request, m.handlerTimeout(r), cleanup)
+	cleanup = nil
 	if err != nil {
 		return
 	}
@@ -117,16 +103,21 @@ func httpDefaultHandler(
 		}
 	}
 
+	responseBody, envelope := unwrapResponse(response)
+
 	var headers map[string][]string
 	var body []byte
-	if encoder != nil {
-		headers, body, err = encoder(ctx, response, err)
+	switch {
+	case envelope != nil && responseBody == nil:
+		// nothing to encode, e.g. redirects and 204 No Content
+	case encoder != nil:
+		headers, body, err = encoder(ctx, responseBody, err)
 		if err != nil {
 			slog.ErrorContext(ctx, "error in encoding response", "err:=", err)
 			return
 		}
-	} else {
-		headers, body, err = ashttp.DefaultHttpEncode(ctx, response)
+	default:
+		headers, body, err = ashttp.DefaultHttpEncode(ctx, responseBody)
 		if err != nil {
 			slog.ErrorContext(ctx, "error in default encoding response", "err:=", err)
 			return
@@ -137,7 +128,19 @@ func httpDefaultHandler(
 
 	populateHeaders(headers, w)
 
-	populateBody(w, body)
+	// handler provided status, headers and cookies override the encoder's
+	status := http.StatusOK
+	if code := applyResponseState(ctx, w); code != 0 {
+		status = code
+	}
+	if code := applyResponseEnvelope(w, envelope); code != 0 {
+		status = code
+	}
+	w.WriteHeader(status)
+
+	if bodyAllowedForStatus(status) {
+		populateBody(w, body)
+	}
 }
 
 func defaultMiddleware(ctx context.Context, r *http.Request) (outgoingContext context.Context, err error) {
@@ -154,5 +157,85 @@ func defaultMiddleware(ctx context.Context, r *http.Request) (outgoingContext co
 
 	ctx = context.WithValue(ctx, constants.HttpRequestPathValues, mux.Vars(r))
 
+	ctx = context.WithValue(ctx, constants.HttpResponseState, newResponseState())
+
+	ctx = context.WithValue(ctx, constants.HttpRequestURI, r.URL.RequestURI())
+
+	requestId := r.Header.Get(constants.HeaderRequestId)
+	if requestId == "" {
+		requestId = utils.GenerateRequestId()
+	}
+	ctx = context.WithValue(ctx, constants.HttpRequestId, requestId)
+
+	ctx = context.WithValue(ctx, constants.EarlyData, isEarlyData(r))
+
 	return ctx, nil
 }
+
+// decodeRequest decodes the body of r with decoder, or the default form or JSON
+// decoder if nil. Methods streaming their request body get a types.RequestStream
+// instead, without reading the body.
+func (m *method) decodeRequest(ctx context.Context, r *http.Request, decoder types.HttpDecoder) (context.Context, interface{}, error) {
+	if m.requestStreaming {
+		request, err := newRequestStream(ctx, r, m.options.RequestStream)
+		if err != nil {
+			slog.ErrorContext(ctx, "error in opening request stream", "err:=", err)
+			return ctx, nil, err
+		}
+		return ctx, request, nil
+	}
+
+	if decoder != nil {
+		outCtx, request, err := decoder(ctx, r)
+		if err != nil {
+			slog.ErrorContext(ctx, "error in decoding request", "err:=", err)
+		}
+		return outCtx, request, err
+	}
+
+	if ashttp.IsFormRequest(r) {
+		form, err := ashttp.DecodeForm(ctx, r, m.options.Form)
+		if err != nil {
+			slog.ErrorContext(ctx, "error in decoding form", "err:=", err)
+			return ctx, nil, err
+		}
+		return ctx, form, nil
+	}
+
+	outCtx, request, err := ashttp.DefaultHttpDecode(ctx, r)
+	if err != nil {
+		slog.ErrorContext(ctx, "error in decoding headers", "err:=", err)
+		return outCtx, request, errors.BadRequest.Wrap(err, "request body is not valid JSON")
+	}
+	return outCtx, request, nil
+}
+
+// writeError sends err to the client, encoded with encoder, or the server's
+// error encoder if encoder is nil.
+func (s *server) writeError(ctx context.Context, w http.ResponseWriter, r *http.Request, encoder types.HttpEncoder, response interface{}, err error) {
+	slog.ErrorContext(ctx, "error in serving request", append([]interface{}{"err:=", err}, errors.KeyValues(err)...)...)
+
+	if encoder == nil {
+		encoder = s.errorEncoder
+	}
+	if encoder == nil {
+		encoder = ashttp.DefaultHttpErrorEncode
+	}
+
+	headers, body, resErr := encoder(ctx, response, err)
+	if resErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		slog.ErrorContext(ctx, "error in encoding error response", "err:=", resErr)
+		return
+	}
+
+	headers = ashttp.PopulateDefaultServerHeaders(ctx, r, headers)
+
+	applyResponseState(ctx, w)
+	populateHeaders(headers, w)
+
+	errCode := errors.DecodeErrorToHttpErrorStatus(err)
+	w.WriteHeader(errCode)
+
+	populateBody(w, body)
+}
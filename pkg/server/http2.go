@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ayushanand18/crazyhttp/internal/config"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// http2OptionsFromConfig reads the HTTP/2 options under service.http.h2.
+// h2c is off unless enabled.
+func http2OptionsFromConfig(ctx context.Context) types.HTTP2Options {
+	prefix := "service.http.h2"
+	return types.HTTP2Options{
+		H2C:                  config.GetBool(ctx, prefix+".h2c", false),
+		MaxConcurrentStreams: uint32(config.GetInt(ctx, prefix+".max_concurrent_streams", 0)),
+		MaxReadFrameSize:     uint32(config.GetInt(ctx, prefix+".max_read_frame_size", 0)),
+		ConnectionWindowSize: int32(config.GetInt(ctx, prefix+".connection_window_size", 0)),
+		StreamWindowSize:     int32(config.GetInt(ctx, prefix+".stream_window_size", 0)),
+		IdleTimeout:          config.GetDuration(ctx, prefix+".idle_timeout", 0),
+		ReadIdleTimeout:      config.GetDuration(ctx, prefix+".read_idle_timeout", 0),
+		PingTimeout:          config.GetDuration(ctx, prefix+".ping_timeout", 0),
+		WriteByteTimeout:     config.GetDuration(ctx, prefix+".write_byte_timeout", 0),
+	}
+}
+
+// newHTTP2Server builds the HTTP/2 server of one listener. Each listener needs
+// its own, as it tracks the connections to shut down with it.
+func newHTTP2Server(options types.HTTP2Options) *http2.Server {
+	return &http2.Server{
+		MaxConcurrentStreams:         options.MaxConcurrentStreams,
+		MaxReadFrameSize:             options.MaxReadFrameSize,
+		MaxUploadBufferPerConnection: options.ConnectionWindowSize,
+		MaxUploadBufferPerStream:     options.StreamWindowSize,
+		IdleTimeout:                  options.IdleTimeout,
+		ReadIdleTimeout:              options.ReadIdleTimeout,
+		PingTimeout:                  options.PingTimeout,
+		WriteByteTimeout:             options.WriteByteTimeout,
+	}
+}
+
+// configureHTTP2 serves HTTP/2 on the HTTPS server, and h2c on the HTTP/1.1
+// server if enabled. Connections are sent a GOAWAY frame on shutdown.
+func (s *server) configureHTTP2() error {
+	if err := http2.ConfigureServer(&s.http1ServerTLS, newHTTP2Server(s.http2Options)); err != nil {
+		return fmt.Errorf("failed to configure HTTP/2: %v", err)
+	}
+
+	if !s.http2Options.H2C {
+		return nil
+	}
+
+	h2server := newHTTP2Server(s.http2Options)
+	if err := http2.ConfigureServer(&s.http1Server, h2server); err != nil {
+		return fmt.Errorf("failed to configure h2c: %v", err)
+	}
+	s.http1Server.Handler = h2c.NewHandler(s.http1Server.Handler, h2server)
+	return nil
+}
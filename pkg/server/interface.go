@@ -3,27 +3,78 @@ package server
 import (
 	"context"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ayushanand18/crazyhttp/internal/config"
 	"github.com/ayushanand18/crazyhttp/internal/utils"
 	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
 	"github.com/gorilla/mux"
-	"github.com/quic-go/quic-go"
 	qchttp3 "github.com/quic-go/quic-go/http3"
-	"github.com/quic-go/quic-go/qlog"
+	"github.com/quic-go/webtransport-go"
 )
 
 type server struct {
 	// HTTP server assets
-	h3server       qchttp3.Server
+	h3server       *qchttp3.Server
+	quicOptions    types.QUICOptions
+	altSvc         *altSvc
 	mux            *mux.Router
 	routeMatchMap  map[string]map[constants.HttpMethodTypes]*method
 	http1ServerTLS http.Server
 	http1Server    http.Server
+	http2Options   types.HTTP2Options
+
+	// sockets given with WithListeners, and those bound by ListenAndServe
+	listeners types.Listeners
+	bound     types.Listeners
+	boundMu   sync.Mutex
+
+	// hands the sockets to a new process, closing upgraded once drained
+	upgradeOptions types.UpgradeOptions
+	upgrading      atomic.Bool
+	upgraded       chan struct{}
+	// closed on shutdown
+	done     chan struct{}
+	doneOnce sync.Once
+
+	// encodes errors for methods without their own error encoder
+	errorEncoder types.HttpEncoder
+	// cap on timeouts requested by clients through request headers
+	maxRequestTimeout time.Duration
+
+	// limits on requests in flight, streams and websockets counting separately
+	requestAdmission *admissionController
+	streamAdmission  *admissionController
+
+	// sessions of all websocket endpoints
+	hub *websocketHub
+
+	// serves the HTTP/3 server once a WebTransport endpoint is registered
+	webtransport         *webtransport.Server
+	webtransportEnabled  bool
+	webtransportSessions sync.Map
+
+	// serves CONNECT requests once registered
+	proxy *connectProxy
 }
 
 type HttpServer interface {
 	Initialize(context.Context) error
 	ListenAndServe(context.Context) error
+	// Shutdown closes the websocket sessions with code 1001, going away, and
+	// then stops the servers gracefully, waiting for requests in flight until
+	// ctx is done.
+	Shutdown(context.Context) error
+	// Addrs returns the addresses the servers are bound to, once
+	// ListenAndServe has bound them.
+	Addrs() types.ListenerAddrs
+	// Upgrade starts the executable of this process again, handing it the
+	// bound sockets, and shuts down once the new process serves them.
+	// ListenAndServe then returns nil, once requests in flight are done.
+	Upgrade(context.Context) error
 
 	// HTTP Methods
 	GET(string) Method
@@ -38,28 +89,100 @@ type HttpServer interface {
 
 	// Websocket
 	WebSocket(string) WebSocket
+
+	// Hub addresses the open websocket sessions of all endpoints, e.g. to
+	// broadcast to a room from an HTTP handler.
+	Hub() WebsocketHub
+
+	// WebTransport mounts a WebTransport endpoint at path, on the HTTP/3
+	// server.
+	WebTransport(path string) WebTransport
+
+	// Proxy serves CONNECT requests as a forward proxy: classic CONNECT
+	// tunnels to TCP targets over any HTTP version, and MASQUE CONNECT-UDP
+	// and CONNECT-IP over HTTP/3, or HTTP/2 with capsules. Extended CONNECT
+	// over HTTP/2 requires GODEBUG=http2xconnect=1.
+	Proxy() Proxy
+
+	// Tus mounts a tus resumable upload endpoint at path, storing uploads in
+	// store. Uploads are created at path, and resumed at path/{id}.
+	Tus(path string, store types.TusStore, options types.TusOptions) Tus
+
+	// WithErrorEncoder sets the error encoder for all methods which do not
+	// have one of their own. Defaults to RFC 9457 Problem Details.
+	WithErrorEncoder(encoder types.HttpEncoder) HttpServer
+	// WithConcurrencyLimit limits the number of requests served concurrently
+	// across all methods, excluding streaming responses and websockets.
+	WithConcurrencyLimit(options types.ConcurrencyLimitOptions) HttpServer
+	// WithAltSvc configures how HTTP/3 is advertised to HTTP/1.1 and HTTP/2
+	// clients, replacing the options read from service.http.alt_svc.
+	WithAltSvc(options types.AltSvcOptions) HttpServer
+	// WithListeners serves on the given sockets, instead of binding the
+	// configured addresses.
+	WithListeners(listeners types.Listeners) HttpServer
+	// WithUpgrade configures upgrades, replacing the options read from
+	// service.upgrade.
+	WithUpgrade(options types.UpgradeOptions) HttpServer
+	// WithQUICOptions configures the QUIC transport of the HTTP/3 server,
+	// replacing the options read from service.http.h3.quic.
+	WithQUICOptions(options types.QUICOptions) HttpServer
+	// WithHTTP2Options configures HTTP/2 and h2c, replacing the options read
+	// from service.http.h2.
+	WithHTTP2Options(options types.HTTP2Options) HttpServer
+	// WithStreamConcurrencyLimit limits the number of streaming responses,
+	// websocket and WebTransport sessions open concurrently.
+	WithStreamConcurrencyLimit(options types.ConcurrencyLimitOptions) HttpServer
 }
 
 func NewHttpServer(ctx context.Context) HttpServer {
-	quicConfig := &quic.Config{
-		Tracer:          qlog.DefaultConnectionTracer,
-		Allow0RTT:       true,
-		EnableDatagrams: true,
-	}
-	return &server{
-		h3server: qchttp3.Server{
+	quicOptions := quicOptionsFromConfig(ctx)
+	readTimeout := config.GetDuration(ctx, "service.http.timeouts.read", constants.DEFAULT_READ_TIMEOUT)
+	readHeaderTimeout := config.GetDuration(ctx, "service.http.timeouts.read_header", constants.DEFAULT_READ_HEADER_TIMEOUT)
+	writeTimeout := config.GetDuration(ctx, "service.http.timeouts.write", constants.DEFAULT_WRITE_TIMEOUT)
+	idleTimeout := config.GetDuration(ctx, "service.http.timeouts.idle", constants.DEFAULT_IDLE_TIMEOUT)
+
+	// the HTTP/3 server is owned by the WebTransport server, which configures
+	// it for WebTransport if used
+	wt := &webtransport.Server{
+		H3: qchttp3.Server{
 			Addr:            utils.GetListeningAddress(ctx),
 			Handler:         nil,
 			EnableDatagrams: true,
-			QUICConfig:      quicConfig,
+			QUICConfig:      newQUICConfig(quicOptions),
+			IdleTimeout:     idleTimeout,
 		},
+		// origins are checked per endpoint
+		CheckOrigin: func(*http.Request) bool { return true },
+	}
+
+	return &server{
+		h3server:     &wt.H3,
+		quicOptions:  quicOptions,
+		altSvc:       newAltSvc(altSvcOptionsFromConfig(ctx)),
+		webtransport: wt,
 		http1Server: http.Server{
-			Addr: utils.GetHttp1ListeningAddress(ctx),
+			Addr:              utils.GetHttp1ListeningAddress(ctx),
+			ReadTimeout:       readTimeout,
+			ReadHeaderTimeout: readHeaderTimeout,
+			WriteTimeout:      writeTimeout,
+			IdleTimeout:       idleTimeout,
 		},
 		http1ServerTLS: http.Server{
-			Addr: utils.GetHttp1TLSListeningAddress(ctx),
+			Addr:              utils.GetHttp1TLSListeningAddress(ctx),
+			ReadTimeout:       readTimeout,
+			ReadHeaderTimeout: readHeaderTimeout,
+			WriteTimeout:      writeTimeout,
+			IdleTimeout:       idleTimeout,
 		},
-		mux:           mux.NewRouter(),
-		routeMatchMap: make(map[string]map[constants.HttpMethodTypes]*method),
+		http2Options:      http2OptionsFromConfig(ctx),
+		upgradeOptions:    upgradeOptionsFromConfig(ctx),
+		upgraded:          make(chan struct{}),
+		done:              make(chan struct{}),
+		mux:               mux.NewRouter(),
+		routeMatchMap:     make(map[string]map[constants.HttpMethodTypes]*method),
+		maxRequestTimeout: config.GetDuration(ctx, "service.http.timeouts.max_request", constants.DEFAULT_MAX_REQUEST_TIMEOUT),
+		requestAdmission:  newAdmissionController(concurrencyLimitOptionsFromConfig(ctx, "service.http.concurrency.requests")),
+		streamAdmission:   newAdmissionController(concurrencyLimitOptionsFromConfig(ctx, "service.http.concurrency.streams")),
+		hub:               newWebsocketHub(),
 	}
 }
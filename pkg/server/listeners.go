@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/internal/config"
+	"github.com/ayushanand18/crazyhttp/internal/handoff"
+	"github.com/ayushanand18/crazyhttp/internal/systemd"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// listen binds the sockets of the servers to serve. Listeners set with
+// WithListeners are used as they are. Otherwise, for an enabled server, in
+// order of preference: the socket handed off by the previous process on an
+// upgrade, the socket passed by systemd under the name set at
+// address.systemd, the Unix socket at address.unix, or the configured
+// address.
+func (s *server) listen(ctx context.Context) (err error) {
+	bound := s.listeners
+	defer func() {
+		if err != nil {
+			closeListeners(bound, s.listeners)
+		}
+	}()
+
+	if bound.H3 == nil && config.GetBool(ctx, "service.http.h3.enabled", false) {
+		if bound.H3, err = listenPacket(ctx, "service.http.h3", s.h3server.Addr); err != nil {
+			return err
+		}
+	}
+	if bound.H1 == nil && config.GetBool(ctx, "service.http.h1.enabled", false) {
+		if bound.H1, err = listenStream(ctx, "service.http.h1", s.http1Server.Addr); err != nil {
+			return err
+		}
+	}
+	if bound.H1TLS == nil && config.GetBool(ctx, "service.http.h1_ssl.enabled", false) {
+		if bound.H1TLS, err = listenStream(ctx, "service.http.h1_ssl", s.http1ServerTLS.Addr); err != nil {
+			return err
+		}
+	}
+
+	s.boundMu.Lock()
+	s.bound = bound
+	s.boundMu.Unlock()
+	return nil
+}
+
+// listenStream binds the listener of a TCP server configured under prefix.
+func listenStream(ctx context.Context, prefix, addr string) (net.Listener, error) {
+	if f := handoff.File(handoffName(prefix)); f != nil {
+		defer f.Close()
+		l, err := net.FileListener(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inherit the %s socket: %v", handoffName(prefix), err)
+		}
+		// the previous process left the socket for this one to remove
+		if l, ok := l.(*net.UnixListener); ok {
+			l.SetUnlinkOnClose(true)
+		}
+		return l, nil
+	}
+
+	if name := config.GetString(ctx, prefix+".address.systemd", ""); name != "" {
+		f := systemd.File(name)
+		if f == nil {
+			return nil, fmt.Errorf("no socket named %q was passed by systemd", name)
+		}
+		defer f.Close()
+		return net.FileListener(f)
+	}
+
+	if path := config.GetString(ctx, prefix+".address.unix", ""); path != "" {
+		removeStaleSocket(path)
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %v", path, err)
+		}
+		return l, nil
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	return l, nil
+}
+
+// listenPacket binds the UDP socket of the HTTP/3 server configured under
+// prefix.
+func listenPacket(ctx context.Context, prefix, addr string) (net.PacketConn, error) {
+	if f := handoff.File(handoffName(prefix)); f != nil {
+		defer f.Close()
+		conn, err := net.FilePacketConn(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inherit the %s socket: %v", handoffName(prefix), err)
+		}
+		return conn, nil
+	}
+
+	if name := config.GetString(ctx, prefix+".address.systemd", ""); name != "" {
+		f := systemd.File(name)
+		if f == nil {
+			return nil, fmt.Errorf("no socket named %q was passed by systemd", name)
+		}
+		defer f.Close()
+		return net.FilePacketConn(f)
+	}
+
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	return conn, nil
+}
+
+// handoffName names the socket of the server configured under prefix, when
+// handed off on upgrades.
+func handoffName(prefix string) string {
+	return strings.TrimPrefix(prefix, "service.http.")
+}
+
+// removeStaleSocket removes the Unix socket at path if left behind by a
+// process which did not shut down, i.e. if nothing accepts connections on it.
+func removeStaleSocket(path string) {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return
+	}
+	_ = os.Remove(path)
+}
+
+// closeListeners closes the listeners bound, except those given.
+func closeListeners(bound, given types.Listeners) {
+	if bound.H3 != nil && bound.H3 != given.H3 {
+		_ = bound.H3.Close()
+	}
+	if bound.H1 != nil && bound.H1 != given.H1 {
+		_ = bound.H1.Close()
+	}
+	if bound.H1TLS != nil && bound.H1TLS != given.H1TLS {
+		_ = bound.H1TLS.Close()
+	}
+}
+
+// addrPort returns the port of a TCP or UDP address, or 0 for other ones.
+func addrPort(addr net.Addr) int {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.Port
+	case *net.UDPAddr:
+		return addr.Port
+	default:
+		return 0
+	}
+}
+
+func (s *server) Addrs() types.ListenerAddrs {
+	s.boundMu.Lock()
+	defer s.boundMu.Unlock()
+
+	var addrs types.ListenerAddrs
+	if s.bound.H3 != nil {
+		addrs.H3 = s.bound.H3.LocalAddr()
+	}
+	if s.bound.H1 != nil {
+		addrs.H1 = s.bound.H1.Addr()
+	}
+	if s.bound.H1TLS != nil {
+		addrs.H1TLS = s.bound.H1TLS.Addr()
+	}
+	return addrs
+}
@@ -1,11 +1,13 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
 	"github.com/ayushanand18/crazyhttp/internal/ratelimiter"
 	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
 	"github.com/ayushanand18/crazyhttp/pkg/types"
 )
 
@@ -16,22 +18,38 @@ type method struct {
 
 	// utility
 	rateLimiter *ratelimiter.RateLimiter
+	admission   *admissionController
+	sseReplay   *sseReplayBuffer
 
 	description            string
 	inputSchema            interface{}
 	outputSchema           interface{}
 	name                   string
 	handler                types.HandlerFunc
+	streamHandler          types.StreamingHandlerFunc
+	datagramHandler        types.DatagramHandlerFunc
+	requestStreaming       bool
 	decoder                types.HttpDecoder
 	encoder                types.HttpEncoder
 	beforeServeMiddlewares []types.HttpRequestMiddleware
 	afterServeMiddlewares  []types.HttpResponseMiddleware
 	options                types.MethodOptions
 	errorEncoder           types.HttpEncoder
+	timeout                time.Duration
 }
 
 type Method interface {
 	Serve(types.HandlerFunc) Method
+	// ServeStream serves a streaming response, sent through a StreamWriter
+	ServeStream(types.StreamingHandlerFunc) Method
+	// ServeClientStream serves requests whose body is received as a stream of
+	// items while it is uploaded, see MethodOptions.RequestStream
+	ServeClientStream(types.ClientStreamingHandlerFunc) Method
+	// ServeBidiStream serves requests whose body and response are both streamed
+	ServeBidiStream(types.BidiStreamingHandlerFunc) Method
+	// ServeDatagrams serves requests exchanging HTTP Datagrams on their
+	// request stream, see MethodOptions.Datagram
+	ServeDatagrams(types.DatagramHandlerFunc) Method
 
 	WithDecoder(decoder types.HttpDecoder) Method
 	WithEncoder(encoder types.HttpEncoder) Method
@@ -46,6 +64,13 @@ type Method interface {
 	WithName(name string) Method
 	// WithErrorEncoder to encode errors
 	WithErrorEncoder(encoder types.HttpEncoder) Method
+	// WithTimeout sets a deadline on the handler context, after which a
+	// GatewayTimeout error is sent to the client. Handlers must honour the
+	// context, as one still running past the deadline is abandoned
+	WithTimeout(timeout time.Duration) Method
+	// WithConcurrencyLimit limits the number of requests to this method served
+	// concurrently, in addition to the server-wide limit
+	WithConcurrencyLimit(options types.ConcurrencyLimitOptions) Method
 }
 
 func NewMethod(httpMethod constants.HttpMethodTypes, url string, s *server) Method {
@@ -58,15 +83,68 @@ func NewMethod(httpMethod constants.HttpMethodTypes, url string, s *server) Meth
 
 func (m *method) Serve(handler types.HandlerFunc) Method {
 	m.handler = handler
+	m.requestStreaming = false
+	m.register()
 
+	return m
+}
+
+func (m *method) ServeStream(handler types.StreamingHandlerFunc) Method {
+	m.streamHandler = handler
+	m.requestStreaming = false
+	m.register()
+
+	return m
+}
+
+func (m *method) ServeClientStream(handler types.ClientStreamingHandlerFunc) Method {
+	m.handler = func(ctx context.Context, request interface{}) (interface{}, error) {
+		stream, ok := request.(types.RequestStream)
+		if !ok {
+			return nil, errors.InternalServerError.Newf("request is a %T, not a request stream", request)
+		}
+		return handler(ctx, stream)
+	}
+	m.requestStreaming = true
+	m.register()
+
+	return m
+}
+
+func (m *method) ServeBidiStream(handler types.BidiStreamingHandlerFunc) Method {
+	m.streamHandler = func(ctx context.Context, request interface{}, stream types.StreamWriter) error {
+		requestStream, ok := request.(types.RequestStream)
+		if !ok {
+			return errors.InternalServerError.Newf("request is a %T, not a request stream", request)
+		}
+		return handler(ctx, requestStream, stream)
+	}
+	m.requestStreaming = true
+	m.register()
+
+	return m
+}
+
+func (m *method) ServeDatagrams(handler types.DatagramHandlerFunc) Method {
+	m.datagramHandler = handler
+	m.requestStreaming = false
+	m.register()
+
+	return m
+}
+
+func (m *method) register() {
 	if _, ok := m.s.routeMatchMap[m.URL]; !ok {
 		m.s.routeMatchMap[m.URL] = make(map[constants.HttpMethodTypes]*method)
 	}
 
 	// if the combination exists, reassign it
 	m.s.routeMatchMap[m.URL][m.Method] = m
+}
 
-	return m
+// isStreaming reports whether m serves a streaming response.
+func (m *method) isStreaming() bool {
+	return m.streamHandler != nil || m.datagramHandler != nil || m.options.IsStreamingResponse
 }
 
 func DecodeJsonRequest[T any](in interface{}) (T, error) {
@@ -128,10 +206,25 @@ func (m *method) WithAfterServe(middleware types.HttpResponseMiddleware) Method
 
 func (m *method) WithOptions(options types.MethodOptions) Method {
 	m.options = options
+
+	m.sseReplay = nil
+	if options.StreamingMode == types.StreamingModeSSE && options.SSE.ReplayBufferSize > 0 {
+		m.sseReplay = newSSEReplayBuffer(options.SSE)
+	}
 	return m
 }
 
 func (m *method) WithErrorEncoder(encoder types.HttpEncoder) Method {
 	m.errorEncoder = encoder
 	return m
-}
\ No newline at end of file
+}
+
+func (m *method) WithTimeout(timeout time.Duration) Method {
+	m.timeout = timeout
+	return m
+}
+
+func (m *method) WithConcurrencyLimit(options types.ConcurrencyLimitOptions) Method {
+	m.admission = newAdmissionController(options)
+	return m
+}
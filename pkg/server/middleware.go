@@ -3,8 +3,6 @@ package server
 import (
 	"fmt"
 	"net/http"
-
-	"github.com/gorilla/mux"
 )
 
 type responseRecorder struct {
@@ -33,6 +31,6 @@ func (r *responseRecorder) Flush() {
 }
 
 type rootHandler struct {
-	mux *mux.Router
-	s   *server
+	next http.Handler
+	s    *server
 }
@@ -0,0 +1,382 @@
+package server
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	ashttp "github.com/ayushanand18/crazyhttp/internal/http"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"github.com/gorilla/mux"
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+const (
+	defaultProxyUDPPathTemplate = "/.well-known/masque/udp/{target_host}/{target_port}/"
+	defaultProxyIPPathTemplate  = "/.well-known/masque/ip/{target}/{ipproto}/"
+	defaultProxyDialTimeout     = 10 * time.Second
+)
+
+type connectProxy struct {
+	s *server
+
+	admission *admissionController
+	policy    types.ProxyPolicyFunc
+	ipHandler types.ProxyIPHandlerFunc
+	options   types.ProxyOption
+}
+
+type Proxy interface {
+	// Serve starts proxying CONNECT requests: classic CONNECT tunnels to TCP
+	// targets, CONNECT-UDP requests at the UDP path template, and CONNECT-IP
+	// requests at the IP path template if an IP handler is set
+	Serve()
+
+	// WithPolicy to allow or deny the target of each tunnel
+	WithPolicy(types.ProxyPolicyFunc) Proxy
+	// WithIPHandler serves CONNECT-IP tunnels, which carry IP packets the
+	// handler forwards, e.g. to a TUN device
+	WithIPHandler(types.ProxyIPHandlerFunc) Proxy
+	// WithOptions to add proxy options
+	WithOptions(options types.ProxyOption) Proxy
+	// WithConcurrencyLimit limits the number of tunnels open concurrently, in
+	// addition to the server-wide stream limit
+	WithConcurrencyLimit(options types.ConcurrencyLimitOptions) Proxy
+}
+
+func NewProxy(s *server) Proxy {
+	return &connectProxy{s: s}
+}
+
+func (p *connectProxy) Serve() {
+	if p.options.UDPPathTemplate == "" {
+		p.options.UDPPathTemplate = defaultProxyUDPPathTemplate
+	}
+	if p.options.IPPathTemplate == "" {
+		p.options.IPPathTemplate = defaultProxyIPPathTemplate
+	}
+	if p.options.DialTimeout <= 0 {
+		p.options.DialTimeout = defaultProxyDialTimeout
+	}
+
+	p.s.proxy = p
+	p.s.mux.HandleFunc(p.options.UDPPathTemplate, p.serveUDP).Methods(http.MethodConnect)
+	if p.ipHandler != nil {
+		// prefix targets contain an encoded slash, decoded in the path
+		template := strings.Replace(p.options.IPPathTemplate, "{target}", "{target:.+}", 1)
+		p.s.mux.HandleFunc(template, p.serveIP).Methods(http.MethodConnect)
+	}
+}
+
+func (p *connectProxy) WithPolicy(policy types.ProxyPolicyFunc) Proxy {
+	p.policy = policy
+	return p
+}
+
+func (p *connectProxy) WithIPHandler(handler types.ProxyIPHandlerFunc) Proxy {
+	p.ipHandler = handler
+	return p
+}
+
+func (p *connectProxy) WithOptions(options types.ProxyOption) Proxy {
+	p.options = options
+	return p
+}
+
+func (p *connectProxy) WithConcurrencyLimit(options types.ConcurrencyLimitOptions) Proxy {
+	p.admission = newAdmissionController(options)
+	return p
+}
+
+// intercept serves classic CONNECT requests, whose target is an authority
+// rather than a path the mux could route, and passes others to next.
+func (p *connectProxy) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect || extendedConnectProtocol(r) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p.serveTCP(w, r)
+	})
+}
+
+// serveTCP tunnels the stream of a classic CONNECT request to a TCP target.
+// Over HTTP/1.1 the connection is taken over, while HTTP/2 and HTTP/3
+// tunnels run on the request stream.
+func (p *connectProxy) serveTCP(w http.ResponseWriter, r *http.Request) {
+	host, portValue, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		p.s.writeError(r.Context(), w, r, nil, nil, errors.BadRequest.Wrap(err, "invalid CONNECT target"))
+		return
+	}
+
+	target, conn, ctx, release, err := p.open(w, r, "tcp", host, portValue)
+	if err != nil {
+		p.s.writeError(ctx, w, r, nil, nil, err)
+		return
+	}
+	defer release()
+	defer conn.Close()
+
+	if r.ProtoMajor == 1 {
+		client, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			p.s.writeError(ctx, w, r, nil, nil, errors.InternalServerError.Wrap(err, "could not take over the connection"))
+			return
+		}
+		defer client.Close()
+
+		// the server deadlines apply to requests, not to tunnels
+		_ = client.SetDeadline(time.Time{})
+		_, _ = brw.WriteString("HTTP/1.1 200 Connection Established\r\n\r\n")
+		if err := brw.Flush(); err != nil {
+			return
+		}
+		// the client may have sent data right after the request head
+		if n := brw.Reader.Buffered(); n > 0 {
+			buffered, _ := brw.Reader.Peek(n)
+			if _, err := conn.Write(buffered); err != nil {
+				return
+			}
+		}
+
+		tunnel(ctx, client, client, conn, target)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+	populateHeaders(ashttp.PopulateDefaultServerHeaders(ctx, r, nil), w)
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	tunnel(ctx, r.Body, &flushWriter{w: w, rc: rc}, conn, target)
+}
+
+// serveUDP proxies the datagrams of a CONNECT-UDP request (RFC 9298) to a
+// UDP target.
+func (p *connectProxy) serveUDP(w http.ResponseWriter, r *http.Request) {
+	if extendedConnectProtocol(r) != "connect-udp" {
+		p.s.writeError(r.Context(), w, r, nil, nil, errors.BadRequest.New("expected a CONNECT-UDP request"))
+		return
+	}
+
+	vars := mux.Vars(r)
+	target, conn, ctx, release, err := p.open(w, r, "udp", vars["target_host"], vars["target_port"])
+	if err != nil {
+		p.s.writeError(ctx, w, r, nil, nil, err)
+		return
+	}
+	defer release()
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var datagrams *datagramConn
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.ErrorContext(ctx, "panic recovered in CONNECT-UDP tunnel", "panic:=", rec, "stack", string(debug.Stack()))
+			if datagrams != nil {
+				datagrams.abort()
+			}
+			return
+		}
+		if datagrams != nil {
+			datagrams.close()
+		}
+	}()
+
+	w.Header().Set("Capsule-Protocol", "?1")
+	datagrams, err = newDatagramConn(ctx, cancel, w, r, p.options.Datagram, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "could not open CONNECT-UDP tunnel", "err:=", err, "target", target)
+		return
+	}
+
+	// UDP payloads travel in datagrams with context ID 0
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				// ICMP errors for earlier packets do not end the flow
+				if stderrors.Is(err, syscall.ECONNREFUSED) {
+					continue
+				}
+				cancel()
+				return
+			}
+			payload := append(quicvarint.Append(nil, 0), buf[:n]...)
+			if err := datagrams.SendDatagram(payload); err != nil {
+				slog.DebugContext(ctx, "could not send CONNECT-UDP datagram", "err:=", err)
+			}
+		}
+	}()
+
+	for {
+		datagram, err := datagrams.ReceiveDatagram(ctx)
+		if err != nil {
+			return
+		}
+		contextID, n, err := quicvarint.Parse(datagram)
+		if err != nil || contextID != 0 {
+			// unknown context IDs belong to extensions we do not support
+			continue
+		}
+		if _, err := conn.Write(datagram[n:]); err != nil {
+			slog.DebugContext(ctx, "could not send UDP payload", "err:=", err, "target", target)
+		}
+	}
+}
+
+// open accepts a tunnel request, and connects to its target.
+func (p *connectProxy) open(w http.ResponseWriter, r *http.Request, network, host, portValue string) (string, net.Conn, context.Context, func(), error) {
+	ctx := r.Context()
+
+	port, err := strconv.Atoi(portValue)
+	if err != nil || port <= 0 || port > 65535 || host == "" {
+		return "", nil, ctx, nil, errors.BadRequest.Newf("invalid CONNECT target %s:%s", host, portValue)
+	}
+	target := net.JoinHostPort(host, portValue)
+
+	ctx, release, err := p.accept(w, r, types.ProxyTarget{Network: network, Host: host, Port: port})
+	if err != nil {
+		return target, nil, ctx, nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:        p.options.DialTimeout,
+		ControlContext: p.checkAddress,
+	}
+	conn, err := dialer.DialContext(ctx, network, target)
+	if err != nil {
+		release()
+		slog.ErrorContext(ctx, "could not connect to CONNECT target", "err:=", err, "target", target)
+		var netErr net.Error
+		switch {
+		case errors.TypeOf(err) != errors.NoType:
+			return target, nil, ctx, nil, err
+		case stderrors.As(err, &netErr) && netErr.Timeout():
+			return target, nil, ctx, nil, errors.GatewayTimeout.Wrapf(err, "timed out connecting to %s", target)
+		default:
+			return target, nil, ctx, nil, errors.BadGateway.Wrapf(err, "could not connect to %s", target)
+		}
+	}
+
+	return target, conn, ctx, release, nil
+}
+
+// accept admits a tunnel request, and checks its target against the policy.
+func (p *connectProxy) accept(w http.ResponseWriter, r *http.Request, target types.ProxyTarget) (context.Context, func(), error) {
+	ctx := r.Context()
+
+	// a replayed CONNECT would replay whatever is sent in the tunnel
+	if err := rejectEarlyData(r, false); err != nil {
+		return ctx, nil, err
+	}
+
+	release, err := admit(ctx, w, p.admission, p.s.streamAdmission)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	ctx, err = defaultMiddleware(ctx, r)
+	if err != nil {
+		release()
+		return ctx, nil, err
+	}
+
+	if p.policy != nil {
+		if err := p.policy(ctx, r, target); err != nil {
+			release()
+			return ctx, nil, err
+		}
+	}
+
+	return ctx, release, nil
+}
+
+// checkAddress rejects connections to addresses denied by checkAddr, once the
+// target is resolved.
+func (p *connectProxy) checkAddress(_ context.Context, _, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errors.Forbidden.Wrapf(err, "target address %s is not allowed", address)
+	}
+	return p.checkAddr(addrPort.Addr())
+}
+
+// checkAddr rejects multicast and broadcast addresses, and unless allowed
+// addresses not reachable from the internet.
+func (p *connectProxy) checkAddr(addr netip.Addr) error {
+	addr = addr.Unmap()
+
+	// a single tunnel must not reach many hosts
+	if addr.IsMulticast() || addr == netip.AddrFrom4([4]byte{255, 255, 255, 255}) {
+		return errors.Forbidden.Newf("target address %s is not allowed", addr)
+	}
+	if p.options.AllowPrivateAddresses {
+		return nil
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsLinkLocalUnicast() {
+		return errors.Forbidden.Newf("target address %s is not allowed", addr)
+	}
+	return nil
+}
+
+// tunnel copies between a client and a target until the target stops
+// sending, or ctx is done.
+func tunnel(ctx context.Context, clientReader io.Reader, clientWriter io.Writer, target net.Conn, address string) {
+	go func() {
+		if _, err := io.Copy(target, clientReader); err != nil {
+			slog.DebugContext(ctx, "tunnel from client ended", "err:=", err, "target", address)
+		}
+		// let the target finish its response
+		if conn, ok := target.(interface{ CloseWrite() error }); ok {
+			_ = conn.CloseWrite()
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := io.Copy(clientWriter, target); err != nil {
+			slog.DebugContext(ctx, "tunnel to client ended", "err:=", err, "target", address)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		_ = target.Close()
+		<-done
+	}
+}
+
+// flushWriter flushes every write to the response stream.
+type flushWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, f.rc.Flush()
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/ayushanand18/crazyhttp/internal/config"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
+	"github.com/quic-go/quic-go/qlog"
+)
+
+const defaultQlogDir = "qlog"
+
+// quicOptionsFromConfig reads the QUIC transport options under
+// service.http.h3.quic. 0-RTT and qlog are off unless enabled.
+func quicOptionsFromConfig(ctx context.Context) types.QUICOptions {
+	prefix := "service.http.h3.quic"
+	return types.QUICOptions{
+		HandshakeIdleTimeout:           config.GetDuration(ctx, prefix+".handshake_idle_timeout", 0),
+		MaxIdleTimeout:                 config.GetDuration(ctx, prefix+".max_idle_timeout", 0),
+		KeepAlivePeriod:                config.GetDuration(ctx, prefix+".keep_alive_period", 0),
+		MaxIncomingStreams:             int64(config.GetInt(ctx, prefix+".max_incoming_streams", 0)),
+		MaxIncomingUniStreams:          int64(config.GetInt(ctx, prefix+".max_incoming_uni_streams", 0)),
+		InitialStreamReceiveWindow:     uint64(config.GetInt(ctx, prefix+".initial_stream_receive_window", 0)),
+		MaxStreamReceiveWindow:         uint64(config.GetInt(ctx, prefix+".max_stream_receive_window", 0)),
+		InitialConnectionReceiveWindow: uint64(config.GetInt(ctx, prefix+".initial_connection_receive_window", 0)),
+		MaxConnectionReceiveWindow:     uint64(config.GetInt(ctx, prefix+".max_connection_receive_window", 0)),
+		InitialPacketSize:              uint16(config.GetInt(ctx, prefix+".initial_packet_size", 0)),
+		DisablePathMTUDiscovery:        config.GetBool(ctx, prefix+".disable_path_mtu_discovery", false),
+		Allow0RTT:                      config.GetBool(ctx, prefix+".allow_0rtt", false),
+		Qlog:                           config.GetBool(ctx, prefix+".qlog.enabled", false),
+		QlogDir:                        config.GetString(ctx, prefix+".qlog.dir", ""),
+		DisableGSO:                     !config.GetBool(ctx, prefix+".gso", true),
+		DisableECN:                     !config.GetBool(ctx, prefix+".ecn", true),
+	}
+}
+
+// newQUICConfig builds the QUIC config of the HTTP/3 server. Datagrams are
+// always enabled, as HTTP Datagrams and WebTransport rely on them.
+func newQUICConfig(options types.QUICOptions) *quic.Config {
+	quicConfig := &quic.Config{
+		HandshakeIdleTimeout:           options.HandshakeIdleTimeout,
+		MaxIdleTimeout:                 options.MaxIdleTimeout,
+		KeepAlivePeriod:                options.KeepAlivePeriod,
+		MaxIncomingStreams:             options.MaxIncomingStreams,
+		MaxIncomingUniStreams:          options.MaxIncomingUniStreams,
+		InitialStreamReceiveWindow:     options.InitialStreamReceiveWindow,
+		MaxStreamReceiveWindow:         options.MaxStreamReceiveWindow,
+		InitialConnectionReceiveWindow: options.InitialConnectionReceiveWindow,
+		MaxConnectionReceiveWindow:     options.MaxConnectionReceiveWindow,
+		InitialPacketSize:              options.InitialPacketSize,
+		DisablePathMTUDiscovery:        options.DisablePathMTUDiscovery,
+		Allow0RTT:                      options.Allow0RTT,
+		EnableDatagrams:                true,
+	}
+
+	if options.Qlog {
+		dir := options.QlogDir
+		if dir == "" {
+			dir = os.Getenv("QLOGDIR")
+		}
+		if dir == "" {
+			dir = defaultQlogDir
+		}
+		quicConfig.Tracer = qlogTracer(dir)
+	}
+
+	return quicConfig
+}
+
+// applyQUICEnvironment sets the options quic-go only reads from the
+// environment, before the HTTP/3 server listens. The variables are left set:
+// quic-go reads them once for the whole process, so restoring them would not
+// undo them, and the new process of an upgrade is configured the same way.
+func applyQUICEnvironment(options types.QUICOptions) {
+	if options.DisableGSO {
+		_ = os.Setenv("QUIC_GO_DISABLE_GSO", "true")
+	}
+	if options.DisableECN {
+		_ = os.Setenv("QUIC_GO_DISABLE_ECN", "true")
+	}
+}
+
+// qlogTracer writes a qlog trace of each connection to dir, named after its
+// original destination connection ID.
+func qlogTracer(dir string) func(context.Context, logging.Perspective, quic.ConnectionID) *logging.ConnectionTracer {
+	return func(ctx context.Context, p logging.Perspective, connID quic.ConnectionID) *logging.ConnectionTracer {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			slog.ErrorContext(ctx, "could not create qlog directory", "err:=", err, "dir", dir)
+			return nil
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("%s_server.sqlog", connID))
+		f, err := os.Create(path)
+		if err != nil {
+			slog.ErrorContext(ctx, "could not create qlog file", "err:=", err, "path", path)
+			return nil
+		}
+		return qlog.NewConnectionTracer(&bufferedWriteCloser{Writer: bufio.NewWriter(f), f: f}, p, connID)
+	}
+}
+
+// bufferedWriteCloser flushes its buffer before closing the file.
+type bufferedWriteCloser struct {
+	*bufio.Writer
+	f *os.File
+}
+
+func (b *bufferedWriteCloser) Close() error {
+	if err := b.Writer.Flush(); err != nil {
+		_ = b.f.Close()
+		return err
+	}
+	return b.f.Close()
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sync"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+const defaultRequestChunkSize = 32 * 1024
+
+// requestStream implements types.RequestStream over the body of a request.
+type requestStream struct {
+	mu   sync.Mutex
+	ctx  context.Context
+	body io.Reader
+
+	mode      types.RequestStreamingMode
+	chunkSize int
+
+	decoder   *json.Decoder
+	multipart *multipart.Reader
+	err       error
+}
+
+func newRequestStream(ctx context.Context, r *http.Request, options types.RequestStreamOptions) (*requestStream, error) {
+	s := &requestStream{
+		ctx:       ctx,
+		body:      r.Body,
+		mode:      options.Mode,
+		chunkSize: options.ChunkSize,
+	}
+	if s.body == nil {
+		s.body = http.NoBody
+	}
+	if s.chunkSize <= 0 {
+		s.chunkSize = defaultRequestChunkSize
+	}
+
+	switch options.Mode {
+	case types.RequestStreamingModeNDJSON:
+		s.decoder = json.NewDecoder(bufio.NewReader(s.body))
+	case types.RequestStreamingModeMultipart:
+		reader, err := r.MultipartReader()
+		if err != nil {
+			return nil, errors.UnsupportedMediaType.Wrap(err, "request body is not multipart")
+		}
+		s.multipart = reader
+	}
+
+	return s, nil
+}
+
+func (s *requestStream) Recv() (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	item, err := s.next()
+	if err != nil {
+		s.err = s.streamError(err)
+		return nil, s.err
+	}
+
+	return item, nil
+}
+
+func (s *requestStream) next() (interface{}, error) {
+	switch s.mode {
+	case types.RequestStreamingModeNDJSON:
+		var item interface{}
+		if err := s.decoder.Decode(&item); err != nil {
+			return nil, err
+		}
+		return item, nil
+	case types.RequestStreamingModeMultipart:
+		return s.multipart.NextPart()
+	default:
+		chunk := make([]byte, s.chunkSize)
+		n, err := s.body.Read(chunk)
+		for n == 0 && err == nil {
+			n, err = s.body.Read(chunk)
+		}
+		if n > 0 {
+			// the error is returned with the next read
+			return chunk[:n], nil
+		}
+		return nil, err
+	}
+}
+
+// streamError maps read errors to the errors returned to handlers: io.EOF at
+// the end of the body, ClientClosedRequest on disconnects and BadRequest on
+// malformed bodies.
+func (s *requestStream) streamError(err error) error {
+	switch {
+	case err == io.EOF:
+		return io.EOF
+	case s.ctx.Err() != nil:
+		return errors.ClientClosedRequest.Wrap(err, "client disconnected")
+	case err == io.ErrUnexpectedEOF:
+		return errors.BadRequest.Wrap(err, "request body ended unexpectedly")
+	}
+
+	switch s.mode {
+	case types.RequestStreamingModeNDJSON:
+		if _, ok := err.(*json.SyntaxError); ok {
+			return errors.BadRequest.Wrap(err, "request body is not valid JSON")
+		}
+	case types.RequestStreamingModeMultipart:
+		return errors.BadRequest.Wrap(err, "request body is not valid multipart")
+	}
+
+	return err
+}
+
+// requestStreamReader reads the []byte chunks of a RequestStreamingModeRaw
+// stream as an io.Reader.
+type requestStreamReader struct {
+	stream types.RequestStream
+	buf    []byte
+}
+
+func (r *requestStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		item, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf, _ = item.([]byte)
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// responseState holds the status, headers and cookies set by a handler through
+// the context, to be applied before the response body is written.
+type responseState struct {
+	mu      sync.Mutex
+	status  int
+	headers http.Header
+	cookies []*http.Cookie
+}
+
+func newResponseState() *responseState {
+	return &responseState{headers: make(http.Header)}
+}
+
+// clone returns a copy of state, for a handler which may outlive the request.
+func (state *responseState) clone() *responseState {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	return &responseState{
+		status:  state.status,
+		headers: state.headers.Clone(),
+		cookies: append([]*http.Cookie(nil), state.cookies...),
+	}
+}
+
+// replace sets state to other, once the handler it was cloned for returned.
+func (state *responseState) replace(other *responseState) {
+	other.mu.Lock()
+	status, headers, cookies := other.status, other.headers, other.cookies
+	other.mu.Unlock()
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.status, state.headers, state.cookies = status, headers, cookies
+}
+
+func getResponseState(ctx context.Context) *responseState {
+	state, _ := ctx.Value(constants.HttpResponseState).(*responseState)
+	return state
+}
+
+// ResponseHeaders returns the headers that will be sent with the response of the
+// current request. Handlers and middlewares can modify them directly, until they
+// return. It returns an empty, detached header map if ctx does not belong to a
+// request.
+func ResponseHeaders(ctx context.Context) http.Header {
+	state := getResponseState(ctx)
+	if state == nil {
+		return make(http.Header)
+	}
+
+	return state.headers
+}
+
+// SetStatusCode sets the status code to be sent for a successful response of the
+// current request.
+func SetStatusCode(ctx context.Context, code int) {
+	state := getResponseState(ctx)
+	if state == nil {
+		return
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.status = code
+}
+
+// SetCookie adds a Set-Cookie header to the response of the current request.
+func SetCookie(ctx context.Context, cookie *http.Cookie) {
+	state := getResponseState(ctx)
+	if state == nil || cookie == nil {
+		return
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.cookies = append(state.cookies, cookie)
+}
+
+// Redirect returns a response redirecting the client to url with the given
+// status code, which should be one of 301, 302, 303, 307 or 308.
+func Redirect(url string, code int) *types.Response {
+	return &types.Response{
+		StatusCode: code,
+		Headers:    http.Header{"Location": {url}},
+	}
+}
+
+// MovedPermanently returns a 301 redirect to url.
+func MovedPermanently(url string) *types.Response {
+	return Redirect(url, http.StatusMovedPermanently)
+}
+
+// Found returns a 302 redirect to url.
+func Found(url string) *types.Response {
+	return Redirect(url, http.StatusFound)
+}
+
+// TemporaryRedirect returns a 307 redirect to url, preserving the request method.
+func TemporaryRedirect(url string) *types.Response {
+	return Redirect(url, http.StatusTemporaryRedirect)
+}
+
+// PermanentRedirect returns a 308 redirect to url, preserving the request method.
+func PermanentRedirect(url string) *types.Response {
+	return Redirect(url, http.StatusPermanentRedirect)
+}
+
+// unwrapResponse splits a handler response into the body to be encoded and the
+// envelope controlling status, headers and cookies, if one was returned.
+func unwrapResponse(response interface{}) (body interface{}, envelope *types.Response) {
+	switch v := response.(type) {
+	case types.Response:
+		return v.Body, &v
+	case *types.Response:
+		if v == nil {
+			return nil, nil
+		}
+		return v.Body, v
+	default:
+		return response, nil
+	}
+}
+
+// applyResponseState copies the headers and cookies set through the request
+// context onto w, and returns the status code set by the handler, if any.
+func applyResponseState(ctx context.Context, w http.ResponseWriter) int {
+	state := getResponseState(ctx)
+	if state == nil {
+		return 0
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	populateHeaders(state.headers, w)
+	for _, cookie := range state.cookies {
+		http.SetCookie(w, cookie)
+	}
+
+	return state.status
+}
+
+// applyResponseEnvelope copies the headers and cookies of a response envelope
+// onto w, and returns its status code, if any.
+func applyResponseEnvelope(w http.ResponseWriter, envelope *types.Response) int {
+	if envelope == nil {
+		return 0
+	}
+
+	populateHeaders(envelope.Headers, w)
+	for _, cookie := range envelope.Cookies {
+		http.SetCookie(w, cookie)
+	}
+
+	return envelope.StatusCode
+}
+
+// bodyAllowedForStatus reports whether a response with the given status may
+// carry a body, as per RFC 9110.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
@@ -2,16 +2,20 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"runtime/debug"
-	"strings"
+	"sync"
 
 	"github.com/ayushanand18/crazyhttp/internal/config"
+	"github.com/ayushanand18/crazyhttp/internal/handoff"
 	"github.com/ayushanand18/crazyhttp/internal/tls"
 	"github.com/ayushanand18/crazyhttp/internal/utils"
 	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	gws "github.com/gorilla/websocket"
 )
 
 func (s *server) Initialize(ctx context.Context) error {
@@ -22,21 +26,12 @@ func (s *server) Initialize(ctx context.Context) error {
 	}
 
 	tlsConfig := tls.GenerateTLSConfig(ctx)
-	root := &rootHandler{mux: s.mux, s: s}
 
-	s.h3server.Handler = root
+	// the HTTPS server negotiates h2 and http/1.1 with its own copy
+	s.http1ServerTLS.TLSConfig = tlsConfig.Clone()
 	s.h3server.TLSConfig = tlsConfig
 	s.h3server.TLSConfig.NextProtos = []string{"h3"}
 
-	h1Handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// if on H/1 advertise H/3
-		w.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%s"; ma=2592000,h3-29=":%s"; ma=2592000`, s.h3server.Addr[strings.LastIndex(s.h3server.Addr, ":")+1:], s.h3server.Addr[strings.LastIndex(s.h3server.Addr, ":")+1:]))
-		root.ServeHTTP(w, r)
-	})
-	s.http1Server.Handler = h1Handler
-	s.http1ServerTLS.Handler = h1Handler
-	s.http1ServerTLS.TLSConfig = tlsConfig
-
 	return nil
 }
 
@@ -47,45 +42,161 @@ func (s *server) ListenAndServe(ctx context.Context) error {
 	for pattern, methods := range s.routeMatchMap {
 		for httpMethod, m := range methods {
 			s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+				if err := rejectEarlyData(r, m.options.AllowEarlyData); err != nil {
+					s.writeError(r.Context(), w, r, m.errorEncoder, nil, err)
+					return
+				}
+
+				release, err := s.admitMethod(r.Context(), w, m)
+				if err != nil {
+					s.writeError(r.Context(), w, r, m.errorEncoder, nil, err)
+					return
+				}
+				defer release()
+
 				// call the right handler (streaming or normal)
-				if m.options.IsStreamingResponse {
+				switch {
+				case m.datagramHandler != nil:
+					datagramHandler(r.Context(), w, m.datagramHandler, r, m)
+				case m.streamHandler != nil:
+					streamingHandler(r.Context(), w, m.streamHandler, m.decoder, m.encoder, r, m)
+				case m.options.IsStreamingResponse:
 					streamingDefaultHandler(r.Context(), w, m.handler, m.decoder, m.encoder, r, m)
-				} else {
+				default:
 					httpDefaultHandler(r.Context(), w, m.handler, m.decoder, m.encoder, r, m)
 				}
 			}).Methods(string(httpMethod))
 		}
 	}
 
-	// wire mux as the Handler for all servers
-	s.h3server.Handler = s.mux
-	s.http1Server.Handler = s.mux
-	s.http1ServerTLS.Handler = s.mux
+	// wire the root handler for all servers, HTTP/1.1 and HTTP/2 responses
+	// advertising HTTP/3
+	var handler http.Handler = s.mux
+	if s.proxy != nil {
+		handler = s.proxy.intercept(handler)
+	}
+	root := &rootHandler{next: handler, s: s}
+	s.h3server.Handler = root
+	s.http1Server.Handler = s.altSvc.advertise(root)
+	s.http1ServerTLS.Handler = s.altSvc.advertise(root)
+	if err := s.configureHTTP2(); err != nil {
+		return err
+	}
+
+	if config.GetBool(ctx, "service.http.h3.enabled", false) || s.listeners.H3 != nil {
+		applyQUICEnvironment(s.quicOptions)
+	}
+
+	// listen before serving, so the bound ports are known to Alt-Svc and
+	// Addrs, even if configured as 0
+	if err := s.listen(ctx); err != nil {
+		return err
+	}
+	bound := s.bound
 
 	errChan := make(chan error, 3)
 
-	if config.GetBool(ctx, "service.http.h3.enabled", false) {
+	if bound.H3 != nil {
+		tlsPort := 0
+		if bound.H1TLS != nil {
+			tlsPort = addrPort(bound.H1TLS.Addr())
+		}
+		s.altSvc.listening(ctx, addrPort(bound.H3.LocalAddr()), tlsPort)
+
 		go func() {
-			slog.InfoContext(ctx, "Starting HTTP/3 server", "port", s.h3server.Addr)
-			errChan <- s.h3server.ListenAndServe()
+			slog.InfoContext(ctx, "Starting HTTP/3 server", "port", bound.H3.LocalAddr().String())
+			if s.webtransportEnabled {
+				errChan <- s.webtransport.Serve(bound.H3)
+				return
+			}
+			errChan <- s.h3server.Serve(bound.H3)
 		}()
 	}
 
-	if config.GetBool(ctx, "service.http.h1.enabled", false) {
+	if bound.H1 != nil {
 		go func() {
-			slog.InfoContext(ctx, "Starting HTTP/1.1 + Alt-Svc server", "port", s.http1Server.Addr)
-			errChan <- s.http1Server.ListenAndServe()
+			slog.InfoContext(ctx, "Starting HTTP/1.1 + Alt-Svc server", "port", bound.H1.Addr().String())
+			errChan <- s.http1Server.Serve(bound.H1)
 		}()
 	}
 
-	if config.GetBool(ctx, "service.http.h1_ssl.enabled", false) {
+	if bound.H1TLS != nil {
 		go func() {
-			slog.InfoContext(ctx, "Starting HTTPS server", "port", s.http1ServerTLS.Addr)
-			errChan <- s.http1ServerTLS.ListenAndServeTLS("", "")
+			slog.InfoContext(ctx, "Starting HTTPS server", "port", bound.H1TLS.Addr().String())
+			errChan <- s.http1ServerTLS.ServeTLS(bound.H1TLS, "", "")
 		}()
 	}
 
-	return <-errChan
+	// a previous process handing off its sockets stops serving them
+	if err := handoff.Ready(); err != nil {
+		slog.ErrorContext(ctx, "could not notify the previous process", "err:=", err)
+	}
+	s.upgradeOnSignals(ctx)
+
+	err := <-errChan
+	// the servers stop as soon as an upgrade drains them, but the process
+	// must not exit before requests in flight are done
+	select {
+	case <-s.done:
+		if s.upgrading.Load() {
+			<-s.upgraded
+			return nil
+		}
+	default:
+	}
+	return err
+}
+
+func (s *server) Shutdown(ctx context.Context) error {
+	s.doneOnce.Do(func() { close(s.done) })
+
+	// clients still on HTTP/1.1 and HTTP/2 should not switch to HTTP/3, unless
+	// the new process of an upgrade serves it
+	if !s.upgrading.Load() {
+		s.altSvc.clear()
+	}
+
+	// hijacked connections are not tracked by the servers
+	s.hub.closeAll(gws.CloseGoingAway, "server shutting down")
+	s.closeWebTransportSessions(0, "server shutting down")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i, shutdown := range []func(context.Context) error{
+		s.shutdownH3,
+		s.http1Server.Shutdown,
+		s.http1ServerTLS.Shutdown,
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = shutdown(ctx)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// shutdownH3 shuts the HTTP/3 server down, and closes its socket as soon as
+// its connections are gone, without waiting for the other servers. On
+// upgrades the socket is shared with the new process, whose packets are
+// dropped when read by this one.
+func (s *server) shutdownH3(ctx context.Context) error {
+	errs := []error{s.h3server.Shutdown(ctx)}
+	if s.webtransportEnabled {
+		errs = append(errs, s.webtransport.Close())
+	}
+
+	// the HTTP/3 server does not close connections it did not create
+	s.boundMu.Lock()
+	h3conn := s.bound.H3
+	s.boundMu.Unlock()
+	if h3conn != nil {
+		errs = append(errs, h3conn.Close())
+	}
+
+	return errors.Join(errs...)
 }
 
 func (s *server) GET(url string) Method {
@@ -128,18 +239,72 @@ func (s *server) WebSocket(url string) WebSocket {
 	return NewWebsocket(url, s)
 }
 
+func (s *server) WebTransport(path string) WebTransport {
+	return NewWebTransport(path, s)
+}
+
+func (s *server) Proxy() Proxy {
+	return NewProxy(s)
+}
+
+func (s *server) WithErrorEncoder(encoder types.HttpEncoder) HttpServer {
+	s.errorEncoder = encoder
+	return s
+}
+
+func (s *server) WithConcurrencyLimit(options types.ConcurrencyLimitOptions) HttpServer {
+	s.requestAdmission = newAdmissionController(options)
+	return s
+}
+
+func (s *server) WithStreamConcurrencyLimit(options types.ConcurrencyLimitOptions) HttpServer {
+	s.streamAdmission = newAdmissionController(options)
+	return s
+}
+
+func (s *server) WithAltSvc(options types.AltSvcOptions) HttpServer {
+	s.altSvc.configure(options)
+	return s
+}
+
+func (s *server) WithHTTP2Options(options types.HTTP2Options) HttpServer {
+	s.http2Options = options
+	return s
+}
+
+func (s *server) WithListeners(listeners types.Listeners) HttpServer {
+	s.listeners = listeners
+	return s
+}
+
+func (s *server) WithUpgrade(options types.UpgradeOptions) HttpServer {
+	s.upgradeOptions = options
+	return s
+}
+
+func (s *server) WithQUICOptions(options types.QUICOptions) HttpServer {
+	s.quicOptions = options
+	s.h3server.QUICConfig = newQUICConfig(options)
+	return s
+}
+
 // serve the HTTP request, and provide a response
 func (h *rootHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err := recover(); err != nil {
+			// handlers abort their response on purpose
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			slog.ErrorContext(r.Context(), "panic recovered: %v\n%s", err, debug.Stack())
+			slog.ErrorContext(r.Context(), "panic recovered", "panic:=", err, "stack", string(debug.Stack()))
 		}
 	}()
 	for k, v := range injectConstantHeaders() {
 		w.Header().Set(k, v)
 	}
-	DumpRequest(r)
+	// bodies are not dumped, as streaming requests and tunnels never end
+	slog.DebugContext(r.Context(), "HTTP request", "method", r.Method, "url", r.URL.String(), "proto", r.Proto)
 
-	h.mux.ServeHTTP(w, r)
+	h.next.ServeHTTP(w, r)
 }
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// LastEventId returns the id of the last Server-Sent Event received by a client
+// reconnecting to a stream, as sent in the Last-Event-ID header.
+func LastEventId(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(constants.LastEventId).(string)
+	return id, ok && id != ""
+}
+
+const (
+	defaultSSEReplayMaxStreams = 1024
+	defaultSSEReplayTTL        = 5 * time.Minute
+)
+
+type sseEvent struct {
+	id    uint32
+	frame []byte
+}
+
+type sseStream struct {
+	events  []sseEvent
+	written time.Time
+}
+
+// sseReplayBuffer keeps the last events sent on each stream, so they can be
+// replayed to clients reconnecting with a Last-Event-ID. As replay keys may be
+// chosen by clients, streams are dropped once not written to for ttl, and the
+// least recently written ones are dropped beyond maxStreams.
+type sseReplayBuffer struct {
+	mu         sync.Mutex
+	size       int
+	maxStreams int
+	ttl        time.Duration
+	streams    map[string]*sseStream
+}
+
+func newSSEReplayBuffer(options types.SSEOptions) *sseReplayBuffer {
+	b := &sseReplayBuffer{
+		size:       options.ReplayBufferSize,
+		maxStreams: options.ReplayMaxStreams,
+		ttl:        options.ReplayTTL,
+		streams:    make(map[string]*sseStream),
+	}
+	if b.maxStreams <= 0 {
+		b.maxStreams = defaultSSEReplayMaxStreams
+	}
+	if b.ttl <= 0 {
+		b.ttl = defaultSSEReplayTTL
+	}
+	return b
+}
+
+func (b *sseReplayBuffer) add(key string, id uint32, frame []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	stream, ok := b.streams[key]
+	if !ok {
+		b.evict(now)
+		stream = &sseStream{}
+		b.streams[key] = stream
+	}
+
+	events := append(stream.events, sseEvent{id: id, frame: frame})
+	if len(events) > b.size {
+		events = events[len(events)-b.size:]
+	}
+	stream.events = events
+	stream.written = now
+}
+
+// evict drops the expired streams, and the least recently written one if no
+// room is left for a new stream.
+func (b *sseReplayBuffer) evict(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, stream := range b.streams {
+		if now.Sub(stream.written) > b.ttl {
+			delete(b.streams, key)
+			continue
+		}
+		if oldestKey == "" || stream.written.Before(oldest) {
+			oldestKey, oldest = key, stream.written
+		}
+	}
+
+	if len(b.streams) >= b.maxStreams {
+		delete(b.streams, oldestKey)
+	}
+}
+
+// since returns the frames of the events sent on the stream after the one with
+// lastEventId. If that event is no longer buffered, all buffered events are
+// returned.
+func (b *sseReplayBuffer) since(key string, lastEventId string) [][]byte {
+	if lastEventId == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stream, ok := b.streams[key]
+	if !ok {
+		return nil
+	}
+	if time.Since(stream.written) > b.ttl {
+		delete(b.streams, key)
+		return nil
+	}
+
+	events := stream.events
+	start := 0
+	if id, err := strconv.ParseUint(lastEventId, 10, 32); err == nil {
+		for i, event := range events {
+			if event.id == uint32(id) {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	frames := make([][]byte, 0, len(events)-start)
+	for _, event := range events[start:] {
+		frames = append(frames, event.frame)
+	}
+	return frames
+}
+
+func (m *method) sseReplayKey(r *http.Request) string {
+	if m.options.SSE.ReplayKey != nil {
+		return m.options.SSE.ReplayKey(r)
+	}
+	return r.URL.Path
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	ashttp "github.com/ayushanand18/crazyhttp/internal/http"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// streamWriter implements types.StreamWriter over an http.ResponseWriter. The
+// status code and headers are written lazily with the first chunk.
+type streamWriter struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	w       http.ResponseWriter
+	flusher http.Flusher
+	r       *http.Request
+	m       *method
+	encoder types.HttpEncoder
+
+	started bool
+	closed  bool
+	// number of chunks sent
+	sent int
+}
+
+func newStreamWriter(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, r *http.Request, m *method, encoder types.HttpEncoder) *streamWriter {
+	return &streamWriter{
+		ctx:     ctx,
+		w:       w,
+		flusher: flusher,
+		r:       r,
+		m:       m,
+		encoder: encoder,
+	}
+}
+
+func (s *streamWriter) Send(data interface{}) error {
+	encoded, err := s.encode(data)
+	if err != nil {
+		return err
+	}
+
+	return s.write(types.StreamChunk{Data: encoded})
+}
+
+func (s *streamWriter) SendEvent(chunk types.StreamChunk) error {
+	if chunk.Data != nil {
+		encoded, err := s.encode(chunk.Data)
+		if err != nil {
+			return err
+		}
+		chunk.Data = encoded
+	}
+
+	return s.write(chunk)
+}
+
+func (s *streamWriter) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	if err := s.start(); err != nil {
+		return err
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *streamWriter) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+func (s *streamWriter) SetHeader(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return errors.InternalServerError.New("headers already sent")
+	}
+
+	s.w.Header().Set(key, value)
+	return nil
+}
+
+// isStarted reports whether the status code and headers were sent.
+func (s *streamWriter) isStarted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.started
+}
+
+// close ends the stream, making further writes fail.
+func (s *streamWriter) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+}
+
+// replay starts the stream with frames buffered for a reconnecting client.
+func (s *streamWriter) replay(frames [][]byte) error {
+	if len(frames) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.start(); err != nil {
+		return err
+	}
+
+	for _, frame := range frames {
+		if _, err := s.w.Write(frame); err != nil {
+			return errors.ClientClosedRequest.Wrap(err, "could not write to stream")
+		}
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+// comment sends an SSE comment if the stream has started, e.g. as a heartbeat.
+func (s *streamWriter) comment(comment string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.writable(); err != nil || !s.started {
+		return err
+	}
+
+	if _, err := s.w.Write(ashttp.FormatServerSentComment(comment)); err != nil {
+		return err
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *streamWriter) encode(data interface{}) ([]byte, error) {
+	if s.encoder == nil && s.jsonMode() {
+		return ashttp.EncodeJSONValue(data)
+	}
+	if s.encoder == nil {
+		_, encoded, err := ashttp.DefaultHttpEncode(s.ctx, data)
+		return encoded, err
+	}
+
+	headers, encoded, err := s.encoder(s.ctx, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// headers from custom encoders apply until the stream starts
+	s.mu.Lock()
+	if !s.started {
+		populateHeaders(headers, s.w)
+	}
+	s.mu.Unlock()
+
+	return encoded, nil
+}
+
+// jsonMode reports whether every chunk must be a JSON value.
+func (s *streamWriter) jsonMode() bool {
+	switch s.m.options.StreamingMode {
+	case types.StreamingModeNDJSON, types.StreamingModeJSONLines, types.StreamingModeJSONArray:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *streamWriter) write(chunk types.StreamChunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	if err := s.start(); err != nil {
+		return err
+	}
+
+	frame := s.frame(chunk)
+	if _, err := s.w.Write(frame); err != nil {
+		return errors.ClientClosedRequest.Wrap(err, "could not write to stream")
+	}
+
+	if s.m.sseReplay != nil && chunk.Id != 0 {
+		s.m.sseReplay.add(s.m.sseReplayKey(s.r), chunk.Id, frame)
+	}
+
+	s.sent++
+	s.flusher.Flush()
+	return nil
+}
+
+// finish ends a stream whose handler returned without error, sending the
+// headers if nothing was sent and closing JSON arrays.
+func (s *streamWriter) finish() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.writable(); err != nil {
+		return err
+	}
+
+	if err := s.start(); err != nil {
+		return err
+	}
+
+	if s.m.options.StreamingMode == types.StreamingModeJSONArray {
+		end := []byte("]")
+		if s.sent == 0 {
+			end = []byte("[]")
+		}
+		if _, err := s.w.Write(end); err != nil {
+			return errors.ClientClosedRequest.Wrap(err, "could not write to stream")
+		}
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+// frame formats an encoded chunk for the streaming mode of the method.
+func (s *streamWriter) frame(chunk types.StreamChunk) []byte {
+	switch s.m.options.StreamingMode {
+	case types.StreamingModeSSE:
+		return ashttp.FormatServerSentEvent(chunk, chunk.Data)
+	case types.StreamingModeNDJSON, types.StreamingModeJSONLines:
+		return ashttp.FormatJSONLine(chunk.Data)
+	case types.StreamingModeJSONArray:
+		return ashttp.FormatJSONArrayElement(s.sent, chunk.Data)
+	default:
+		return chunk.Data
+	}
+}
+
+// start sends the status code and headers, honouring the ones set by the
+// handler, followed by the SSE retry hint if configured.
+func (s *streamWriter) start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+
+	header := s.w.Header()
+	if header.Get("Content-Type") == "" {
+		contentType := s.m.options.StreamingContentType
+		if contentType == "" {
+			contentType = ashttp.StreamContentType(s.m.options.StreamingMode)
+		}
+		header.Set("Content-Type", contentType)
+	}
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+
+	populateHeaders(ashttp.PopulateDefaultServerHeaders(s.ctx, s.r, nil), s.w)
+
+	status := http.StatusOK
+	if code := applyResponseState(s.ctx, s.w); code != 0 {
+		status = code
+	}
+	s.w.WriteHeader(status)
+
+	if s.m.options.StreamingMode == types.StreamingModeSSE && s.m.options.SSE.Retry > 0 {
+		if _, err := s.w.Write(ashttp.FormatServerSentEvent(types.StreamChunk{Retry: s.m.options.SSE.Retry}, nil)); err != nil {
+			return errors.ClientClosedRequest.Wrap(err, "could not write to stream")
+		}
+	}
+
+	return nil
+}
+
+// writable returns an error if the stream ended or the client disconnected.
+func (s *streamWriter) writable() error {
+	if s.closed {
+		return errors.InternalServerError.New("stream already closed")
+	}
+
+	if err := s.ctx.Err(); err != nil {
+		return errors.ClientClosedRequest.Wrap(err, "client disconnected")
+	}
+
+	return nil
+}
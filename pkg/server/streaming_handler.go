@@ -4,8 +4,9 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"runtime/debug"
 	"strings"
-	"sync"
+	"time"
 
 	ashttp "github.com/ayushanand18/crazyhttp/internal/http"
 	"github.com/ayushanand18/crazyhttp/pkg/constants"
@@ -13,127 +14,186 @@ import (
 	"github.com/ayushanand18/crazyhttp/pkg/types"
 )
 
-func streamingDefaultHandler(
+func streamingHandler(
 	ctx context.Context,
 	w http.ResponseWriter,
-	handler types.HandlerFunc,
+	handler types.StreamingHandlerFunc,
 	decoder types.HttpDecoder,
 	encoder types.HttpEncoder,
 	r *http.Request,
 	m *method,
 ) {
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("X-Accel-Buffering", "no")
-	w.WriteHeader(http.StatusOK)
-
-	if len(m.options.AllowedOrigins) > 0 &&
-		!ashttp.IsOriginAllowed(r.Header.Get("Origin"), m.options.AllowedOrigins) {
-		w.WriteHeader(http.StatusForbidden)
+	var stream *streamWriter
+	var request interface{}
+	var err error
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			slog.ErrorContext(ctx, "panic recovered in streaming handler", "panic:=", rec, "stack", string(debug.Stack()))
+			err = errors.InternalServerError.New("panic in handler")
+		}
+
+		if stream != nil {
+			stream.close()
+		}
+
+		if err == nil {
+			return
+		}
+
+		// once the status is sent, errors can only be logged
+		if stream != nil && stream.isStarted() {
+			slog.ErrorContext(ctx, "error in streaming response", append([]interface{}{"err:=", err}, errors.KeyValues(err)...)...)
+			return
+		}
+
+		m.s.writeError(ctx, w, r, m.errorEncoder, nil, err)
+	}()
+
+	ctx, err = defaultMiddleware(ctx, r)
+	if err != nil {
+		slog.ErrorContext(ctx, "error in default middlewares", "err:=", err)
+		return
+	}
+
+	if len(m.options.AllowedOrigins) > 0 && !ashttp.IsOriginAllowed(r.Header.Get("Origin"), m.options.AllowedOrigins) {
 		slog.ErrorContext(ctx, "origin not allowed", "origin", r.Header.Get("Origin"))
+		err = errors.Forbidden.New("origin not allowed")
 		return
 	}
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "Streaming unsupported by this server!", http.StatusInternalServerError)
+		err = errors.InternalServerError.New("streaming unsupported by this server")
 		return
 	}
 
-	// master cancel context
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	ch := make(chan types.StreamChunk)
+	if m.requestStreaming {
+		// HTTP/1.1 servers stop reading the request body once the response
+		// starts, unless full duplex; HTTP/2 and HTTP/3 always are
+		_ = http.NewResponseController(w).EnableFullDuplex()
+	}
 
-	// safe channel close
-	var once sync.Once
-	closeAll := func() {
-		once.Do(func() { close(ch) })
+	ctx, request, err = m.decodeRequest(ctx, r, decoder)
+	if err != nil {
+		return
+	}
+	if form, ok := request.(*types.Form); ok {
+		defer removeForm(ctx, form)
 	}
 
-	// expose channel in ctx
-	ctx = context.WithValue(ctx, constants.StreamingResponseChannelContextKey, ch)
+	if m.rateLimiter != nil {
+		key := ctx.Value(constants.RateLimitCustomKey)
+		if key == nil || key == "" {
+			key = strings.Split(r.RemoteAddr, ":")[0]
+		}
+		k, ok := key.(string)
+		if !ok {
+			slog.ErrorContext(ctx, "rate limit key is not a string", "key:=", key)
+			err = errors.InternalServerError.New("rate limit key is not a string")
+			return
+		}
+		m.rateLimiter.Allow(k)
+	}
 
-	// worker goroutine: decode, rate-limit, call handler
-	go func() {
-		defer func() {
-			cancel()
-			closeAll()
-		}()
+	for _, mw := range m.beforeServeMiddlewares {
+		ctx, request, err = mw(ctx, request)
+		if err != nil {
+			return
+		}
+	}
 
-		var request interface{}
-		var err error
+	lastEventId := r.Header.Get("Last-Event-ID")
+	ctx = context.WithValue(ctx, constants.LastEventId, lastEventId)
 
-		if decoder != nil {
-			ctx, request, err = decoder(ctx, r)
-			if err != nil {
-				w.WriteHeader(errors.DecodeErrorToHttpErrorStatus(err))
-				return
-			}
-		}
+	var cancel context.CancelFunc
+	if timeout := m.handlerTimeout(r); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
 
-		if m.rateLimiter != nil {
-			key := ctx.Value(constants.RateLimitCustomKey)
-			if key == nil || key == "" {
-				key = strings.Split(r.RemoteAddr, ":")[0]
-			}
-			k, ok := key.(string)
-			if !ok {
-				w.WriteHeader(http.StatusInternalServerError)
-				slog.ErrorContext(ctx, "rate limit key is not a string", "key:=", key)
-				return
-			}
-			m.rateLimiter.Allow(k)
-		}
+	stream = newStreamWriter(ctx, w, flusher, r, m, encoder)
 
-		_, err = handler(ctx, request)
-		if err != nil {
-			w.WriteHeader(errors.DecodeErrorToHttpErrorStatus(err))
+	if m.sseReplay != nil {
+		if err = stream.replay(m.sseReplay.since(m.sseReplayKey(r), lastEventId)); err != nil {
 			return
 		}
-	}()
+	}
 
-	// writer loop (main goroutine)
-	for {
-		select {
-		case chunk, ok := <-ch:
-			if !ok {
-				return
+	if m.options.StreamingMode == types.StreamingModeSSE && m.options.SSE.HeartbeatInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(m.options.SSE.HeartbeatInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					if stream.comment("heartbeat") != nil {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
 			}
+		}()
+	}
 
-			var headers map[string][]string
-			var encoded []byte
-			var err error
+	err = handler(ctx, request, stream)
+	if err != nil {
+		return
+	}
 
-			if encoder != nil {
-				headers, encoded, err = encoder(ctx, chunk.Data, nil)
-			} else {
-				headers, encoded, err = ashttp.DefaultHttpEncode(ctx, chunk.Data)
-			}
-			if err != nil {
-				w.WriteHeader(errors.DecodeErrorToHttpErrorStatus(err))
-				cancel()
-				return
-			}
+	if finishErr := stream.finish(); finishErr != nil {
+		slog.DebugContext(ctx, "could not finish stream", "err:=", finishErr)
+	}
+}
 
-			for key, value := range headers {
-				w.Header().Del(key)
-				for _, v := range value {
-					w.Header().Add(key, v)
+// streamingDefaultHandler serves a HandlerFunc streaming chunks through the
+// channel in its context, see constants.StreamingResponseChannelContextKey.
+func streamingDefaultHandler(
+	ctx context.Context,
+	w http.ResponseWriter,
+	handler types.HandlerFunc,
+	decoder types.HttpDecoder,
+	encoder types.HttpEncoder,
+	r *http.Request,
+	m *method,
+) {
+	streamingHandler(ctx, w, func(ctx context.Context, request interface{}, stream types.StreamWriter) error {
+		// the channel is never closed, so handlers sending after the client
+		// disconnects do not panic; chunks are drained until they return
+		ch := make(chan types.StreamChunk)
+		ctx = context.WithValue(ctx, constants.StreamingResponseChannelContextKey, ch)
+
+		done := make(chan error, 1)
+		go func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					slog.ErrorContext(ctx, "panic recovered in streaming handler", "panic:=", rec, "stack", string(debug.Stack()))
+					done <- errors.InternalServerError.New("panic in handler")
 				}
-			}
+			}()
 
-			if _, err := w.Write(encoded); err != nil {
-				cancel()
-				return
-			}
+			_, err := handler(ctx, request)
+			done <- err
+		}()
 
-			flusher.Flush()
+		// legacy handlers expect the headers to be sent right away
+		if err := stream.Flush(); err != nil {
+			slog.DebugContext(ctx, "could not flush stream", "err:=", err)
+		}
 
-		case <-ctx.Done():
-			closeAll()
-			return
+		for {
+			select {
+			case chunk := <-ch:
+				if err := stream.SendEvent(chunk); err != nil {
+					slog.DebugContext(ctx, "could not send chunk", "err:=", err)
+				}
+			case err := <-done:
+				return err
+			}
 		}
-	}
+	}, decoder, encoder, r, m)
 }
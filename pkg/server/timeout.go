@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	ashttp "github.com/ayushanand18/crazyhttp/internal/http"
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+type handlerResult struct {
+	response interface{}
+	err      error
+}
+
+// handlerTimeout returns the timeout for serving r: the route timeout, lowered to
+// the one requested by the client through request headers if that is shorter.
+// Timeouts requested by clients are capped by the server's max request timeout.
+func (m *method) handlerTimeout(r *http.Request) time.Duration {
+	timeout := m.timeout
+
+	if requested, ok := ashttp.GetRequestTimeout(r.Header); ok {
+		if m.s.maxRequestTimeout > 0 && requested > m.s.maxRequestTimeout {
+			requested = m.s.maxRequestTimeout
+		}
+		if timeout <= 0 || requested < timeout {
+			timeout = requested
+		}
+	}
+
+	return timeout
+}
+
+// serveWithTimeout calls handler with a context deadline of timeout. Once the
+// deadline passes it returns a GatewayTimeout error, even if the handler ignores
+// the context and keeps running; its result is then discarded. The handler sets
+// the status, headers and cookies of a copy of the response state, applied only
+// if it returns in time. onReturn, if not nil, is called once the handler
+// returns, which may be after the response was sent.
+func serveWithTimeout(ctx context.Context, handler types.HandlerFunc, request interface{}, timeout time.Duration, onReturn func()) (interface{}, error) {
+	if onReturn == nil {
+		onReturn = func() {}
+	}
+	if timeout <= 0 {
+		defer onReturn()
+		return handler(ctx, request)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// an abandoned handler must not race with the error response
+	state := getResponseState(ctx)
+	handlerCtx := ctx
+	var handlerState *responseState
+	if state != nil {
+		handlerState = state.clone()
+		handlerCtx = context.WithValue(ctx, constants.HttpResponseState, handlerState)
+	}
+
+	done := make(chan handlerResult, 1)
+	go func() {
+		defer onReturn()
+		defer func() {
+			if r := recover(); r != nil {
+				slog.ErrorContext(ctx, "panic recovered in http handler", "panic:=", r, "stack", string(debug.Stack()))
+				done <- handlerResult{err: errors.InternalServerError.New("panic in handler")}
+			}
+		}()
+
+		response, err := handler(handlerCtx, request)
+		done <- handlerResult{response: response, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		if state != nil {
+			state.replace(handlerState)
+		}
+		return result.response, result.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, errors.GatewayTimeout.Wrap(ctx.Err(), "request timed out")
+		}
+		return nil, errors.ClientClosedRequest.Wrap(ctx.Err(), "request canceled")
+	}
+}
@@ -0,0 +1,480 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/internal/ratelimiter"
+	"github.com/ayushanand18/crazyhttp/internal/utils"
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+const (
+	tusVersion             = "1.0.0"
+	tusExtensions          = "creation,expiration,checksum,termination"
+	tusChecksumAlgorithms  = "md5,sha1,sha256"
+	tusOffsetContentType   = "application/offset+octet-stream"
+	statusChecksumMismatch = 460
+)
+
+// Tus is a tus 1.0 resumable upload endpoint, see https://tus.io/protocols/resumable-upload.
+// Its settings apply to all the requests of the protocol.
+type Tus interface {
+	// WithBeforeServe adds a middleware run before every request, e.g. to
+	// authenticate clients
+	WithBeforeServe(middleware types.HttpRequestMiddleware) Tus
+	// WithRateLimit limits the rate of requests, shared by all the requests
+	WithRateLimit(options types.RateLimitOptions) Tus
+	// WithConcurrencyLimit limits the number of requests served concurrently,
+	// shared by all the requests
+	WithConcurrencyLimit(options types.ConcurrencyLimitOptions) Tus
+}
+
+type tus struct {
+	path    string
+	store   types.TusStore
+	options types.TusOptions
+	methods []*method
+
+	// uploads being written to or terminated, by id, only while they are
+	locks sync.Map
+}
+
+func (s *server) Tus(path string, store types.TusStore, options types.TusOptions) Tus {
+	path = strings.TrimSuffix(path, "/")
+	uploadPath := path + "/{id}"
+
+	t := &tus{
+		path:    path,
+		store:   store,
+		options: options,
+	}
+
+	for _, m := range []Method{
+		s.OPTIONS(path).Serve(t.serveOptions),
+		s.POST(path).Serve(t.create),
+		s.HEAD(uploadPath).Serve(t.head),
+		s.PATCH(uploadPath).ServeClientStream(t.patch),
+		s.DELETE(uploadPath).Serve(t.terminate),
+	} {
+		t.methods = append(t.methods, m.(*method))
+	}
+
+	if options.Expiration > 0 {
+		go t.sweep(s.done)
+	}
+
+	return t
+}
+
+func (t *tus) WithBeforeServe(middleware types.HttpRequestMiddleware) Tus {
+	for _, m := range t.methods {
+		m.WithBeforeServe(middleware)
+	}
+	return t
+}
+
+func (t *tus) WithRateLimit(options types.RateLimitOptions) Tus {
+	rateLimiter := ratelimiter.NewRateLimiter(options.Limit, time.Duration(options.BucketDurationInSeconds)*time.Second)
+	for _, m := range t.methods {
+		m.rateLimiter = rateLimiter
+	}
+	return t
+}
+
+func (t *tus) WithConcurrencyLimit(options types.ConcurrencyLimitOptions) Tus {
+	admission := newAdmissionController(options)
+	for _, m := range t.methods {
+		m.admission = admission
+	}
+	return t
+}
+
+func (t *tus) serveOptions(ctx context.Context, request interface{}) (interface{}, error) {
+	headers := http.Header{}
+	headers.Set("Tus-Resumable", tusVersion)
+	headers.Set("Tus-Version", tusVersion)
+	headers.Set("Tus-Extension", tusExtensions)
+	headers.Set("Tus-Checksum-Algorithm", tusChecksumAlgorithms)
+	if t.options.MaxSize > 0 {
+		headers.Set("Tus-Max-Size", strconv.FormatInt(t.options.MaxSize, 10))
+	}
+
+	return &types.Response{StatusCode: http.StatusNoContent, Headers: headers}, nil
+}
+
+func (t *tus) create(ctx context.Context, request interface{}) (interface{}, error) {
+	header, err := t.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Get("Upload-Defer-Length") != "" {
+		return nil, errors.BadRequest.New("deferring the upload length is not supported")
+	}
+
+	size, err := strconv.ParseInt(header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		return nil, errors.BadRequest.New("invalid Upload-Length header")
+	}
+	if t.options.MaxSize > 0 && size > t.options.MaxSize {
+		ResponseHeaders(ctx).Set("Tus-Max-Size", strconv.FormatInt(t.options.MaxSize, 10))
+		return nil, errors.ContentTooLarge.Newf("upload is larger than %d bytes", t.options.MaxSize)
+	}
+
+	metadata, err := parseTusMetadata(header.Get("Upload-Metadata"))
+	if err != nil {
+		return nil, err
+	}
+
+	upload := types.TusUpload{
+		Id:       utils.GenerateRequestId(),
+		Size:     size,
+		Metadata: metadata,
+	}
+	if t.options.Expiration > 0 {
+		upload.ExpiresAt = time.Now().Add(t.options.Expiration)
+	}
+
+	if t.options.PreCreate != nil {
+		if err := t.options.PreCreate(ctx, upload); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := t.store.Create(ctx, upload); err != nil {
+		return nil, err
+	}
+
+	// an empty upload has no PATCH request to complete it
+	if upload.IsComplete() && t.options.OnComplete != nil {
+		if err := t.options.OnComplete(ctx, upload); err != nil {
+			return nil, err
+		}
+	}
+
+	headers := t.uploadHeaders(upload)
+	headers.Set("Location", t.path+"/"+upload.Id)
+
+	return &types.Response{StatusCode: http.StatusCreated, Headers: headers}, nil
+}
+
+func (t *tus) head(ctx context.Context, request interface{}) (interface{}, error) {
+	if _, err := t.begin(ctx); err != nil {
+		return nil, err
+	}
+
+	upload, err := t.get(ctx, tusUploadId(ctx), false)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := t.uploadHeaders(upload)
+	headers.Set("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	if len(upload.Metadata) > 0 {
+		headers.Set("Upload-Metadata", formatTusMetadata(upload.Metadata))
+	}
+	headers.Set("Cache-Control", "no-store")
+
+	return &types.Response{StatusCode: http.StatusOK, Headers: headers}, nil
+}
+
+func (t *tus) patch(ctx context.Context, request types.RequestStream) (interface{}, error) {
+	header, err := t.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Get("Content-Type") != tusOffsetContentType {
+		return nil, errors.UnsupportedMediaType.Newf("content type must be %s", tusOffsetContentType)
+	}
+
+	offset, err := strconv.ParseInt(header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return nil, errors.BadRequest.New("invalid Upload-Offset header")
+	}
+
+	id := tusUploadId(ctx)
+	unlock, err := t.lock(id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	upload, err := t.get(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset {
+		return nil, errors.Conflict.Newf("upload is at offset %d", upload.Offset)
+	}
+
+	remaining := upload.Size - upload.Offset
+	if length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && length > remaining {
+		return nil, errors.ContentTooLarge.Newf("upload has %d bytes remaining", remaining)
+	}
+	var body io.Reader = io.LimitReader(&requestStreamReader{stream: request}, remaining)
+
+	if checksum := header.Get("Upload-Checksum"); checksum != "" {
+		verified, ok, err := verifyTusChecksum(checksum, body)
+		if err != nil {
+			return nil, err
+		}
+		defer verified.Close()
+
+		if !ok {
+			return &types.Response{StatusCode: statusChecksumMismatch, Headers: http.Header{"Tus-Resumable": {tusVersion}}}, nil
+		}
+		body = verified
+	}
+
+	n, err := t.store.Append(ctx, id, offset, body)
+	upload.Offset = offset + n
+	ResponseHeaders(ctx).Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	// only the request completing the upload calls the hook, not the ones
+	// retried after it
+	if n > 0 && upload.IsComplete() && t.options.OnComplete != nil {
+		if err := t.options.OnComplete(ctx, upload); err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.Response{StatusCode: http.StatusNoContent, Headers: t.uploadHeaders(upload)}, nil
+}
+
+func (t *tus) terminate(ctx context.Context, request interface{}) (interface{}, error) {
+	if _, err := t.begin(ctx); err != nil {
+		return nil, err
+	}
+
+	id := tusUploadId(ctx)
+	unlock, err := t.lock(id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if _, err := t.get(ctx, id, true); err != nil {
+		return nil, err
+	}
+	if err := t.store.Delete(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return &types.Response{StatusCode: http.StatusNoContent, Headers: http.Header{"Tus-Resumable": {tusVersion}}}, nil
+}
+
+// begin checks the protocol version requested by the client, and returns the
+// request headers.
+func (t *tus) begin(ctx context.Context) (http.Header, error) {
+	ResponseHeaders(ctx).Set("Tus-Resumable", tusVersion)
+
+	header, _ := ctx.Value(constants.HttpRequestHeaders).(http.Header)
+	if header.Get("Tus-Resumable") != tusVersion {
+		ResponseHeaders(ctx).Set("Tus-Version", tusVersion)
+		return header, errors.PreconditionFailed.Newf("unsupported tus version %q", header.Get("Tus-Resumable"))
+	}
+
+	return header, nil
+}
+
+// get returns an upload, discarding it if it expired. locked reports whether
+// the caller holds the lock of the upload; if not, an expired upload being
+// written to is left to the request writing it.
+func (t *tus) get(ctx context.Context, id string, locked bool) (types.TusUpload, error) {
+	upload, err := t.store.Get(ctx, id)
+	if err != nil {
+		return upload, err
+	}
+
+	if !upload.ExpiresAt.IsZero() && !upload.IsComplete() && time.Now().After(upload.ExpiresAt) {
+		if !locked {
+			unlock, err := t.lock(id)
+			if err != nil {
+				return upload, errors.NotFound.New("upload expired")
+			}
+			defer unlock()
+		}
+
+		if err := t.store.Delete(ctx, id); err != nil {
+			return upload, err
+		}
+		return upload, errors.NotFound.New("upload expired")
+	}
+
+	return upload, nil
+}
+
+// lock prevents concurrent writes to an upload, e.g. by a client resuming while
+// its previous request is still being served. The lock is forgotten once
+// released, so that completed, expired and unknown uploads hold no memory.
+func (t *tus) lock(id string) (func(), error) {
+	for {
+		value, _ := t.locks.LoadOrStore(id, &sync.Mutex{})
+		mu := value.(*sync.Mutex)
+		if !mu.TryLock() {
+			return nil, errors.Conflict.New("upload is locked by another request")
+		}
+
+		// the lock was forgotten by its previous holder, after it was loaded
+		if current, ok := t.locks.Load(id); !ok || current != mu {
+			mu.Unlock()
+			continue
+		}
+
+		return func() {
+			t.locks.Delete(id)
+			mu.Unlock()
+		}, nil
+	}
+}
+
+// sweep periodically discards the expired uploads until done is closed, so
+// that uploads abandoned by their clients do not pile up in the store.
+func (t *tus) sweep(done <-chan struct{}) {
+	interval := t.options.SweepInterval
+	if interval <= 0 {
+		interval = t.options.Expiration
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx := context.Background()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		ids, err := t.store.List(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "could not list tus uploads", "err:=", err)
+			continue
+		}
+		for _, id := range ids {
+			_, _ = t.get(ctx, id, false)
+		}
+	}
+}
+
+func (t *tus) uploadHeaders(upload types.TusUpload) http.Header {
+	headers := http.Header{}
+	headers.Set("Tus-Resumable", tusVersion)
+	headers.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if !upload.ExpiresAt.IsZero() && !upload.IsComplete() {
+		headers.Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	return headers
+}
+
+func tusUploadId(ctx context.Context) string {
+	values, _ := ctx.Value(constants.HttpRequestPathValues).(map[string]string)
+	return values["id"]
+}
+
+// parseTusMetadata parses an Upload-Metadata header: comma separated keys,
+// each followed by a space and its base64 encoded value, if any.
+func parseTusMetadata(header string) (map[string]string, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, nil
+	}
+
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.BadRequest.New("invalid Upload-Metadata header")
+		}
+
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.BadRequest.Wrapf(err, "invalid Upload-Metadata value for %q", key)
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
+
+func formatTusMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// verifyTusChecksum reads body to a temporary file while hashing it, as per an
+// Upload-Checksum header. The returned reader replays the body, and removes
+// the file once closed.
+func verifyTusChecksum(checksum string, body io.Reader) (io.ReadCloser, bool, error) {
+	algorithm, encoded, _ := strings.Cut(checksum, " ")
+
+	var h hash.Hash
+	switch algorithm {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return nil, false, errors.BadRequest.Newf("unsupported checksum algorithm %q", algorithm)
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false, errors.BadRequest.Wrap(err, "invalid Upload-Checksum header")
+	}
+
+	tmp, err := os.CreateTemp("", "crazyhttp-tus-*")
+	if err != nil {
+		return nil, false, errors.InternalServerError.Wrap(err, "could not buffer upload")
+	}
+	verified := &tempFile{File: tmp}
+
+	if _, err := io.Copy(io.MultiWriter(tmp, h), body); err != nil {
+		verified.Close()
+		return nil, false, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		verified.Close()
+		return nil, false, errors.InternalServerError.Wrap(err, "could not buffer upload")
+	}
+
+	return verified, bytes.Equal(h.Sum(nil), expected), nil
+}
+
+// tempFile is a temporary file removed once closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	_ = os.Remove(f.Name())
+	return err
+}
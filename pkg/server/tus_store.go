@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+var tusUploadIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tusFileStore stores each upload in dir as a data file, named after its id,
+// and a JSON info file. The offset of an upload is the size of its data file.
+type tusFileStore struct {
+	dir string
+}
+
+// NewTusFileStore returns a TusStore keeping uploads in dir on the local
+// filesystem, creating it if missing.
+func NewTusFileStore(dir string) (types.TusStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	return &tusFileStore{dir: dir}, nil
+}
+
+func (s *tusFileStore) Create(ctx context.Context, upload types.TusUpload) error {
+	if !tusUploadIdPattern.MatchString(upload.Id) {
+		return errors.BadRequest.Newf("invalid upload id %q", upload.Id)
+	}
+
+	info, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.OpenFile(s.dataPath(upload.Id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return errors.InternalServerError.Wrap(err, "could not create upload")
+	}
+	data.Close()
+
+	if err := os.WriteFile(s.infoPath(upload.Id), info, 0o640); err != nil {
+		_ = os.Remove(s.dataPath(upload.Id))
+		return errors.InternalServerError.Wrap(err, "could not create upload")
+	}
+
+	return nil
+}
+
+func (s *tusFileStore) Get(ctx context.Context, id string) (types.TusUpload, error) {
+	var upload types.TusUpload
+	if !tusUploadIdPattern.MatchString(id) {
+		return upload, errors.NotFound.New("upload not found")
+	}
+
+	info, err := os.ReadFile(s.infoPath(id))
+	if os.IsNotExist(err) {
+		return upload, errors.NotFound.New("upload not found")
+	}
+	if err != nil {
+		return upload, err
+	}
+	if err := json.Unmarshal(info, &upload); err != nil {
+		return upload, err
+	}
+
+	stat, err := os.Stat(s.dataPath(id))
+	if err != nil {
+		return upload, err
+	}
+	upload.Offset = stat.Size()
+
+	return upload, nil
+}
+
+func (s *tusFileStore) Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	if !tusUploadIdPattern.MatchString(id) {
+		return 0, errors.NotFound.New("upload not found")
+	}
+
+	data, err := os.OpenFile(s.dataPath(id), os.O_WRONLY, 0)
+	if os.IsNotExist(err) {
+		return 0, errors.NotFound.New("upload not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer data.Close()
+
+	stat, err := data.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if stat.Size() != offset {
+		return 0, errors.Conflict.Newf("upload is at offset %d", stat.Size())
+	}
+
+	if _, err := data.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return io.Copy(data, r)
+}
+
+func (s *tusFileStore) Delete(ctx context.Context, id string) error {
+	if !tusUploadIdPattern.MatchString(id) {
+		return errors.NotFound.New("upload not found")
+	}
+
+	err := os.Remove(s.infoPath(id))
+	if os.IsNotExist(err) {
+		return errors.NotFound.New("upload not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(s.dataPath(id))
+}
+
+func (s *tusFileStore) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".info"); ok && tusUploadIdPattern.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *tusFileStore) dataPath(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+func (s *tusFileStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
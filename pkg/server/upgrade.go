@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/ayushanand18/crazyhttp/internal/config"
+	"github.com/ayushanand18/crazyhttp/internal/handoff"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+const (
+	defaultUpgradeReadyTimeout = 30 * time.Second
+	defaultUpgradeDrainTimeout = 30 * time.Second
+)
+
+// upgradeOptionsFromConfig reads the upgrade options under service.upgrade.
+// Upgrades on signals are off unless enabled.
+func upgradeOptionsFromConfig(ctx context.Context) types.UpgradeOptions {
+	prefix := "service.upgrade"
+	return types.UpgradeOptions{
+		Enabled:      config.GetBool(ctx, prefix+".enabled", false),
+		ReadyTimeout: config.GetDuration(ctx, prefix+".ready_timeout", defaultUpgradeReadyTimeout),
+		DrainTimeout: config.GetDuration(ctx, prefix+".drain_timeout", defaultUpgradeDrainTimeout),
+	}
+}
+
+// upgradeOnSignals upgrades the server on SIGHUP and SIGUSR2, until it shuts
+// down. Failed upgrades are logged, and the server keeps serving.
+func (s *server) upgradeOnSignals(ctx context.Context) {
+	if !s.upgradeOptions.Enabled || len(upgradeSignals) == 0 {
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, upgradeSignals...)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case sig := <-signals:
+				slog.InfoContext(ctx, "Upgrading", "signal", sig.String())
+				if err := s.Upgrade(ctx); err != nil {
+					slog.ErrorContext(ctx, "upgrade failed", "err:=", err)
+				}
+			case <-s.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (s *server) Upgrade(ctx context.Context) error {
+	select {
+	case <-s.done:
+		return errors.New("server is shut down")
+	default:
+	}
+	if !s.upgrading.CompareAndSwap(false, true) {
+		return errors.New("an upgrade is in progress")
+	}
+
+	sockets, err := s.handoffSockets()
+	defer func() {
+		for _, socket := range sockets {
+			_ = socket.File.Close()
+		}
+	}()
+	if err != nil {
+		s.upgrading.Store(false)
+		return err
+	}
+
+	readyCtx, cancel := context.WithTimeout(ctx, s.upgradeOptions.ReadyTimeout)
+	defer cancel()
+	process, err := handoff.Start(readyCtx, sockets)
+	if err != nil {
+		s.upgrading.Store(false)
+		return err
+	}
+	slog.InfoContext(ctx, "New process is serving, draining requests in flight", "pid", process.Pid)
+
+	// Unix sockets stay bound for the new process
+	s.boundMu.Lock()
+	for _, l := range []net.Listener{s.bound.H1, s.bound.H1TLS} {
+		if l, ok := l.(*net.UnixListener); ok {
+			l.SetUnlinkOnClose(false)
+		}
+	}
+	s.boundMu.Unlock()
+
+	drainCtx, cancel := context.WithTimeout(ctx, s.upgradeOptions.DrainTimeout)
+	defer cancel()
+	err = s.Shutdown(drainCtx)
+	close(s.upgraded)
+	return err
+}
+
+// handoffSockets returns copies of the bound sockets, named after their
+// server.
+func (s *server) handoffSockets() ([]handoff.Socket, error) {
+	s.boundMu.Lock()
+	bound := s.bound
+	s.boundMu.Unlock()
+
+	var sockets []handoff.Socket
+	for _, socket := range []struct {
+		name     string
+		listener any
+	}{
+		{"h3", bound.H3},
+		{"h1", bound.H1},
+		{"h1_ssl", bound.H1TLS},
+	} {
+		if socket.listener == nil {
+			continue
+		}
+		l, ok := socket.listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return sockets, fmt.Errorf("cannot hand off the %s socket of type %T", socket.name, socket.listener)
+		}
+		f, err := l.File()
+		if err != nil {
+			return sockets, fmt.Errorf("cannot hand off the %s socket: %v", socket.name, err)
+		}
+		sockets = append(sockets, handoff.Socket{Name: socket.name, File: f})
+	}
+	return sockets, nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package server
+
+import "os"
+
+// upgradeSignals start an upgrade once enabled, but sockets cannot be
+// inherited on this platform.
+var upgradeSignals []os.Signal
@@ -0,0 +1,11 @@
+//go:build unix
+
+package server
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignals start an upgrade once enabled.
+var upgradeSignals = []os.Signal{syscall.SIGHUP, syscall.SIGUSR2}
@@ -83,8 +83,48 @@ func DumpRequest(req *http.Request) {
 }
 
 // GetWebSocketHandlerFunc wraps a method onto websocket handler func
-func (ws *websocket) GetWebSocketHandlerFunc(handler types.WebsocketHandlerFunc) func(w http.ResponseWriter, r *http.Request) {
+func (ws *websocket) GetWebSocketHandlerFunc(handler func(ctx context.Context, conn *websocketConn) error) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// a replayed upgrade would replay the messages sent in the session
+		if err := rejectEarlyData(r, false); err != nil {
+			ws.s.writeError(r.Context(), w, r, nil, nil, err)
+			return
+		}
+
+		release, err := admit(r.Context(), w, ws.admission, ws.s.streamAdmission)
+		if err != nil {
+			ws.s.writeError(r.Context(), w, r, nil, nil, err)
+			return
+		}
+		defer release()
+
+		ctx, err := defaultMiddleware(r.Context(), r)
+		if err != nil {
+			slog.ErrorContext(ctx, "error in default middlewares", "err:=", err)
+			return
+		}
+
+		if ws.handshake != nil {
+			ws.handshake()
+		}
+
+		var handshake types.WebSocketHandshake
+		if ws.upgrade != nil {
+			ctx, handshake, err = ws.upgrade(ctx, r)
+			if err != nil {
+				ws.s.writeError(ctx, w, r, nil, nil, err)
+				return
+			}
+		}
+
+		responseHeader := handshake.Headers.Clone()
+		if handshake.Subprotocol != "" {
+			if responseHeader == nil {
+				responseHeader = make(http.Header)
+			}
+			responseHeader.Set("Sec-WebSocket-Protocol", handshake.Subprotocol)
+		}
+
 		upgrader := gws.Upgrader{
 			CheckOrigin: func(req *http.Request) bool {
 				if len(ws.options.AllowedOrigins) > 0 &&
@@ -93,16 +133,40 @@ func (ws *websocket) GetWebSocketHandlerFunc(handler types.WebsocketHandlerFunc)
 				}
 				return true
 			},
+			Subprotocols:      ws.options.Subprotocols,
+			ReadBufferSize:    ws.options.ReadBufferSize,
+			WriteBufferSize:   ws.options.WriteBufferSize,
+			EnableCompression: ws.options.EnableCompression,
 		}
 
-		c, err := upgrader.Upgrade(w, r, nil)
+		// over HTTP/2 and HTTP/3, the session runs on the request stream
+		upgradeW, upgradeR := w, r
+		if isExtendedConnect(r) {
+			upgradeW, upgradeR = extendedConnectUpgrade(w, r)
+		}
+
+		c, err := upgrader.Upgrade(upgradeW, upgradeR, responseHeader)
 		if err != nil {
 			slog.Error("Error handling Upgrading websocket", "error", err)
 			return
 		}
 		defer c.Close()
 
-		websocketHandler(r.Context(), c, w, r, ws, handler)
+		if ws.options.MaxMessageSize > 0 {
+			c.SetReadLimit(ws.options.MaxMessageSize)
+		}
+		if ws.options.EnableCompression && ws.options.CompressionLevel != 0 {
+			if err := c.SetCompressionLevel(ws.options.CompressionLevel); err != nil {
+				slog.WarnContext(ctx, "invalid websocket compression level", "err:=", err)
+			}
+		}
+
+		conn := newWebsocketConn(ctx, c, r, ws)
+		if err := handler(conn.ctx, conn); err != nil {
+			slog.DebugContext(ctx, "websocket session ended", "err:=", err)
+		}
+		// flushes the queued messages, unless the handler closed the session
+		_ = conn.Close(gws.CloseNormalClosure, "")
 	}
 }
 
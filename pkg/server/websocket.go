@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -13,11 +14,15 @@ type websocket struct {
 	s   *server
 
 	rateLimiter *ratelimiter.RateLimiter
+	admission   *admissionController
 
-	decoder               types.HttpDecoder
-	encoder               types.HttpEncoder
-	beforeServeMiddleware types.HttpRequestMiddleware
-	afterServeMiddleware  types.HttpResponseMiddleware
+	decoder                types.HttpDecoder
+	messageDecoder         types.WebsocketDecoder
+	encoder                types.HttpEncoder
+	handshake              types.WebSocketHandshakeFunc
+	upgrade                types.WebSocketUpgradeFunc
+	beforeServeMiddlewares []types.HttpRequestMiddleware
+	afterServeMiddlewares  []types.HttpResponseMiddleware
 
 	options types.WebSocketOption
 
@@ -25,11 +30,27 @@ type websocket struct {
 	name        string
 }
 
+// WebSocket is an endpoint for WebSocket sessions, bootstrapped with an
+// HTTP/1.1 upgrade, or with an extended CONNECT request over HTTP/3 (RFC 9220)
+// and HTTP/2 (RFC 8441). net/http only accepts extended CONNECT over HTTP/2
+// when run with GODEBUG=http2xconnect=1.
 type WebSocket interface {
+	// Serve exchanges messages through the channels in the context, see
+	// constants.WebsocketRequestChannel. Chunks received carry the raw and the
+	// decoded message; chunks sent are encoded as is
 	Serve(types.WebsocketHandlerFunc)
-
-	// Decoder for every message received
+	// ServeConn serves the session with a WebsocketConn, receiving decoded
+	// messages
+	ServeConn(types.WebsocketConnHandlerFunc)
+	// ServeMessage serves each decoded message, sending back the response
+	ServeMessage(types.HandlerFunc)
+
+	// Decoder for every message received, reading it as the body of the
+	// upgrade request
 	WithDecoder(decoder types.HttpDecoder) WebSocket
+	// WithMessageDecoder to decode every message received from its type and
+	// payload, in place of the decoder
+	WithMessageDecoder(decoder types.WebsocketDecoder) WebSocket
 	// Encoder for every message sent
 	WithEncoder(encoder types.HttpEncoder) WebSocket
 	// Middleware to run before every message is served
@@ -46,8 +67,16 @@ type WebSocket interface {
 	// rate limit will be applied on each message received
 	// key in context with which rate limiting will be done can be set using RateLimitOptions.ContextKey
 	WithRateLimit(options types.RateLimitOptions) WebSocket
-	// HandleHandshake to handle custom handshake
+	// HandleHandshake to run a function before every upgrade
+	//
+	// Deprecated: use HandleUpgrade, which can inspect and reject the request
 	HandleHandshake(types.WebSocketHandshakeFunc) WebSocket
+	// HandleUpgrade to inspect upgrade requests, select a subprotocol and set
+	// response headers, or reject them with an error
+	HandleUpgrade(types.WebSocketUpgradeFunc) WebSocket
+	// WithConcurrencyLimit limits the number of sessions open concurrently on
+	// this endpoint, in addition to the server-wide stream limit
+	WithConcurrencyLimit(options types.ConcurrencyLimitOptions) WebSocket
 }
 
 func NewWebsocket(url string, s *server) WebSocket {
@@ -55,6 +84,24 @@ func NewWebsocket(url string, s *server) WebSocket {
 }
 
 func (ws *websocket) Serve(handler types.WebsocketHandlerFunc) {
+	ws.serve(func(ctx context.Context, conn *websocketConn) error {
+		return websocketHandler(ctx, conn, handler)
+	})
+}
+
+func (ws *websocket) ServeConn(handler types.WebsocketConnHandlerFunc) {
+	ws.serve(func(ctx context.Context, conn *websocketConn) error {
+		return websocketConnHandler(ctx, conn, handler)
+	})
+}
+
+func (ws *websocket) ServeMessage(handler types.HandlerFunc) {
+	ws.serve(func(ctx context.Context, conn *websocketConn) error {
+		return websocketMessageHandler(ctx, conn, handler)
+	})
+}
+
+func (ws *websocket) serve(handler func(ctx context.Context, conn *websocketConn) error) {
 	fun := ws.GetWebSocketHandlerFunc(handler)
 	ws.s.mux.HandleFunc(ws.Url, http.HandlerFunc(fun))
 }
@@ -64,18 +111,23 @@ func (ws *websocket) WithDecoder(decoder types.HttpDecoder) WebSocket {
 	return ws
 }
 
+func (ws *websocket) WithMessageDecoder(decoder types.WebsocketDecoder) WebSocket {
+	ws.messageDecoder = decoder
+	return ws
+}
+
 func (ws *websocket) WithEncoder(encoder types.HttpEncoder) WebSocket {
 	ws.encoder = encoder
 	return ws
 }
 
 func (ws *websocket) WithBeforeServe(middleware types.HttpRequestMiddleware) WebSocket {
-	ws.beforeServeMiddleware = middleware
+	ws.beforeServeMiddlewares = append(ws.beforeServeMiddlewares, middleware)
 	return ws
 }
 
 func (ws *websocket) WithAfterServe(middleware types.HttpResponseMiddleware) WebSocket {
-	ws.afterServeMiddleware = middleware
+	ws.afterServeMiddlewares = append(ws.afterServeMiddlewares, middleware)
 	return ws
 }
 
@@ -90,6 +142,12 @@ func (ws *websocket) WithDescription(desc string) WebSocket {
 }
 
 func (ws *websocket) HandleHandshake(fn types.WebSocketHandshakeFunc) WebSocket {
+	ws.handshake = fn
+	return ws
+}
+
+func (ws *websocket) HandleUpgrade(fn types.WebSocketUpgradeFunc) WebSocket {
+	ws.upgrade = fn
 	return ws
 }
 
@@ -103,3 +161,8 @@ func (ws *websocket) WithRateLimit(options types.RateLimitOptions) WebSocket {
 
 	return ws
 }
+
+func (ws *websocket) WithConcurrencyLimit(options types.ConcurrencyLimitOptions) WebSocket {
+	ws.admission = newAdmissionController(options)
+	return ws
+}
@@ -0,0 +1,478 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	ashttp "github.com/ayushanand18/crazyhttp/internal/http"
+	"github.com/ayushanand18/crazyhttp/internal/utils"
+	"github.com/ayushanand18/crazyhttp/pkg/constants"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	gws "github.com/gorilla/websocket"
+)
+
+const (
+	websocketCloseTimeout          = time.Second
+	defaultWebsocketSendBufferSize = 16
+	defaultWebsocketWriteTimeout   = 10 * time.Second
+)
+
+type websocketFrame struct {
+	messageType types.WebsocketMessageType
+	data        []byte
+}
+
+// websocketConn implements types.WebsocketConn, running messages through the
+// pipeline of its endpoint. Messages are sent from a queue by a single writer
+// goroutine, as gorilla connections support one concurrent writer, and read by
+// a single reader goroutine, which keeps processing control frames and the
+// idle timeout while the handler is not receiving.
+type websocketConn struct {
+	id          string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	conn        *gws.Conn
+	r           *http.Request
+	ws          *websocket
+	connectedAt time.Time
+
+	// reads fail once the client is idle for longer, zero if unlimited
+	idleTimeout  time.Duration
+	writeTimeout time.Duration
+
+	send chan websocketFrame
+	// closed once the session is closing, failing further sends
+	done      chan struct{}
+	closeOnce sync.Once
+	// closed once the writer goroutine exits
+	writerDone chan struct{}
+	closeFrame websocketCloseFrame
+
+	// messages read by the reader goroutine, closed with readErr set once
+	// reading fails
+	received chan websocketFrame
+	readErr  error
+
+	// guarded by the hub
+	joined   map[string]struct{}
+	metadata map[string]string
+}
+
+type websocketCloseFrame struct {
+	code   int
+	reason string
+	// skip the queued messages, e.g. for slow consumers
+	abort bool
+}
+
+func newWebsocketConn(ctx context.Context, conn *gws.Conn, r *http.Request, ws *websocket) *websocketConn {
+	sendBufferSize := ws.options.SendBufferSize
+	if sendBufferSize <= 0 {
+		sendBufferSize = defaultWebsocketSendBufferSize
+	}
+
+	c := &websocketConn{
+		id:          utils.GenerateRequestId(),
+		conn:        conn,
+		r:           r,
+		ws:          ws,
+		connectedAt: time.Now(),
+		send:        make(chan websocketFrame, sendBufferSize),
+		done:        make(chan struct{}),
+		writerDone:  make(chan struct{}),
+		received:    make(chan websocketFrame),
+		joined:      make(map[string]struct{}),
+		metadata:    make(map[string]string),
+	}
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.ctx = context.WithValue(ctx, constants.WebsocketConnection, types.WebsocketConn(c))
+
+	c.writeTimeout = ws.options.WriteTimeout
+	if c.writeTimeout <= 0 {
+		c.writeTimeout = defaultWebsocketWriteTimeout
+	}
+
+	c.idleTimeout = ws.options.ReadTimeout
+	if c.idleTimeout <= 0 && ws.options.PingInterval > 0 {
+		pongTimeout := ws.options.PongTimeout
+		if pongTimeout <= 0 {
+			pongTimeout = ws.options.PingInterval / 2
+		}
+		c.idleTimeout = ws.options.PingInterval + pongTimeout
+	}
+	if c.idleTimeout > 0 {
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+		})
+	}
+
+	go c.writeLoop()
+	go c.readLoop()
+	ws.s.hub.register(c)
+
+	return c
+}
+
+// WebsocketConnection returns the WebSocket session a message is served on, in
+// the context of handlers and middlewares.
+func WebsocketConnection(ctx context.Context) (types.WebsocketConn, bool) {
+	conn, ok := ctx.Value(constants.WebsocketConnection).(types.WebsocketConn)
+	return conn, ok
+}
+
+func (c *websocketConn) Recv() (types.WebsocketMessage, error) {
+	_, message, err := c.recv()
+	return message, err
+}
+
+// recv reads the next message and runs it through the decoder and before serve
+// middlewares, returning the context they produced for it.
+func (c *websocketConn) recv() (context.Context, types.WebsocketMessage, error) {
+	ctx := c.ctx
+
+	frame, ok := <-c.received
+	if !ok {
+		err := c.readErr
+		if gws.IsCloseError(err, gws.CloseNormalClosure, gws.CloseGoingAway) {
+			return ctx, types.WebsocketMessage{}, io.EOF
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return ctx, types.WebsocketMessage{}, errors.ClientClosedRequest.Wrap(err, "websocket connection idle for too long")
+		}
+		return ctx, types.WebsocketMessage{}, errors.ClientClosedRequest.Wrap(err, "websocket connection closed")
+	}
+
+	if c.ws.rateLimiter != nil {
+		key := ctx.Value(constants.RateLimitCustomKey)
+		if key == nil || key == "" {
+			key = strings.Split(c.r.RemoteAddr, ":")[0]
+		}
+		k, ok := key.(string)
+		if !ok {
+			return ctx, types.WebsocketMessage{}, errors.InternalServerError.New("rate limit key is not a string")
+		}
+		c.ws.rateLimiter.Allow(k)
+	}
+
+	message := types.WebsocketMessage{
+		MessageType: frame.messageType,
+		Raw:         frame.data,
+	}
+
+	decodedCtx, decoded, err := c.decode(ctx, message.MessageType, frame.data)
+	if err != nil {
+		return ctx, message, errors.BadRequest.Wrap(err, "could not decode message")
+	}
+	ctx, message.Data = decodedCtx, decoded
+
+	for _, mw := range c.ws.beforeServeMiddlewares {
+		ctx, message.Data, err = mw(ctx, message.Data)
+		if err != nil {
+			return ctx, message, err
+		}
+	}
+
+	return ctx, message, nil
+}
+
+// decode decodes a message with the message decoder of the endpoint, or with
+// its decoder, reading the message as the body of the upgrade request.
+func (c *websocketConn) decode(ctx context.Context, messageType types.WebsocketMessageType, data []byte) (context.Context, interface{}, error) {
+	switch {
+	case c.ws.messageDecoder != nil:
+		message, err := c.ws.messageDecoder(ctx, messageType, data)
+		return ctx, message, err
+	case c.ws.decoder != nil:
+		r := c.r.WithContext(ctx)
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		r.ContentLength = int64(len(data))
+		return c.ws.decoder(ctx, r)
+	default:
+		message, err := defaultWebsocketDecode(ctx, messageType, data)
+		return ctx, message, err
+	}
+}
+
+// readLoop reads messages until the connection fails, handing them to recv.
+// Reading goes on while the handler only sends, so that pongs and close frames
+// are processed and idle clients detected; it waits while a message is not
+// received yet.
+func (c *websocketConn) readLoop() {
+	defer close(c.received)
+
+	for {
+		if c.idleTimeout > 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+		}
+
+		mt, data, err := c.readMessage()
+		if err != nil {
+			c.readErr = err
+			// the close handshake is done, or the client is gone: there is no
+			// one left to send queued messages to
+			c.shutdown(websocketCloseFrame{abort: true})
+			return
+		}
+
+		select {
+		case c.received <- websocketFrame{messageType: types.WebsocketMessageType(mt), data: data}:
+		case <-c.done:
+			// the session is closing, messages are no longer served
+		}
+	}
+}
+
+// readMessage reads the next message, closing the session with code 1009 if
+// it is larger than the maximum message size once decompressed.
+func (c *websocketConn) readMessage() (int, []byte, error) {
+	mt, r, err := c.conn.NextReader()
+	if err != nil {
+		return mt, nil, err
+	}
+
+	limit := c.ws.options.MaxMessageSize
+	if limit <= 0 {
+		data, err := io.ReadAll(r)
+		return mt, data, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err == nil && int64(len(data)) > limit {
+		_ = c.conn.WriteControl(gws.CloseMessage, gws.FormatCloseMessage(gws.CloseMessageTooBig, ""), time.Now().Add(websocketCloseTimeout))
+		err = gws.ErrReadLimit
+	}
+	return mt, data, err
+}
+
+func (c *websocketConn) Send(data interface{}) error {
+	return c.SendMessage(types.WebsocketTextMessage, data)
+}
+
+// SendMessage queues a message, blocking while the send buffer is full.
+func (c *websocketConn) SendMessage(messageType types.WebsocketMessageType, data interface{}) error {
+	frame, err := c.encode(messageType, data)
+	if err != nil {
+		return err
+	}
+
+	return c.write(frame)
+}
+
+func (c *websocketConn) Subprotocol() string {
+	return c.conn.Subprotocol()
+}
+
+func (c *websocketConn) Close(code int, reason string) error {
+	c.close(websocketCloseFrame{code: code, reason: reason})
+	return nil
+}
+
+func (c *websocketConn) Id() string {
+	return c.id
+}
+
+func (c *websocketConn) Join(room string) {
+	c.ws.s.hub.join(c, room)
+}
+
+func (c *websocketConn) Leave(room string) {
+	c.ws.s.hub.leave(c, room)
+}
+
+func (c *websocketConn) Rooms() []string {
+	return c.ws.s.hub.session(c).Rooms
+}
+
+func (c *websocketConn) SetMetadata(key, value string) {
+	c.ws.s.hub.setMetadata(c, key, value)
+}
+
+// sendError sends err to the client as a message, encoded with the server's
+// error encoder.
+func (c *websocketConn) sendError(ctx context.Context, err error) error {
+	encoder := c.ws.s.errorEncoder
+	if encoder == nil {
+		encoder = ashttp.DefaultHttpErrorEncode
+	}
+
+	_, body, encodeErr := encoder(ctx, nil, err)
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	return c.write(websocketFrame{messageType: types.WebsocketTextMessage, data: body})
+}
+
+// encode runs data through the after serve middlewares and the encoder.
+func (c *websocketConn) encode(messageType types.WebsocketMessageType, data interface{}) (websocketFrame, error) {
+	if messageType == types.WebsocketUnspecifiedMessage {
+		messageType = types.WebsocketTextMessage
+	}
+
+	var err error
+	for _, mw := range c.ws.afterServeMiddlewares {
+		data, err = mw(c.ctx, data)
+		if err != nil {
+			return websocketFrame{}, err
+		}
+	}
+
+	var encoded []byte
+	if c.ws.encoder != nil {
+		_, encoded, err = c.ws.encoder(c.ctx, data, nil)
+	} else {
+		_, encoded, err = ashttp.DefaultHttpEncode(c.ctx, data)
+	}
+	if err != nil {
+		return websocketFrame{}, err
+	}
+
+	return websocketFrame{messageType: messageType, data: encoded}, nil
+}
+
+// write queues frame, blocking while the send buffer is full.
+func (c *websocketConn) write(frame websocketFrame) error {
+	select {
+	case <-c.done:
+		return errors.ClientClosedRequest.New("websocket connection closed")
+	default:
+	}
+
+	select {
+	case c.send <- frame:
+		return nil
+	case <-c.done:
+		return errors.ClientClosedRequest.New("websocket connection closed")
+	}
+}
+
+// offer queues frame without blocking, applying the slow consumer policy of
+// the endpoint if the send buffer is full.
+func (c *websocketConn) offer(frame websocketFrame) error {
+	select {
+	case <-c.done:
+		return errors.ClientClosedRequest.New("websocket connection closed")
+	default:
+	}
+
+	select {
+	case c.send <- frame:
+		return nil
+	case <-c.done:
+		return errors.ClientClosedRequest.New("websocket connection closed")
+	default:
+	}
+
+	if c.ws.options.SlowConsumerPolicy == types.WebsocketSlowConsumerDisconnect {
+		slog.WarnContext(c.ctx, "disconnecting slow websocket consumer", "session", c.id)
+		c.shutdown(websocketCloseFrame{code: gws.ClosePolicyViolation, reason: "slow consumer", abort: true})
+		return errors.ServiceUnavailable.New("websocket session disconnected for consuming too slowly")
+	}
+
+	slog.WarnContext(c.ctx, "dropping message to slow websocket consumer", "session", c.id)
+	return errors.ServiceUnavailable.New("websocket send buffer is full, message dropped")
+}
+
+// close stops accepting messages and has the writer flush the queue and send
+// the close frame, then waits for the connection to be closed.
+func (c *websocketConn) close(frame websocketCloseFrame) {
+	c.shutdown(frame)
+
+	select {
+	case <-c.writerDone:
+	case <-time.After(websocketCloseTimeout):
+		_ = c.conn.Close()
+		<-c.writerDone
+	}
+}
+
+// shutdown stops accepting messages, and unregisters the session from the hub.
+// Aborted sessions are closed right away, interrupting a write in progress, and
+// without a close frame unless it has a code.
+func (c *websocketConn) shutdown(frame websocketCloseFrame) {
+	c.closeOnce.Do(func() {
+		c.closeFrame = frame
+		close(c.done)
+		c.cancel()
+		c.ws.s.hub.unregister(c)
+
+		if frame.abort {
+			if frame.code != 0 {
+				_ = c.conn.WriteControl(gws.CloseMessage, gws.FormatCloseMessage(frame.code, frame.reason), time.Now().Add(websocketCloseTimeout))
+			}
+			_ = c.conn.Close()
+		}
+	})
+}
+
+func (c *websocketConn) writeLoop() {
+	defer close(c.writerDone)
+	defer c.conn.Close()
+
+	var ping <-chan time.Time
+	if c.ws.options.PingInterval > 0 {
+		ticker := time.NewTicker(c.ws.options.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	for {
+		select {
+		case frame := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+			if err := c.conn.WriteMessage(frame.messageType.ToInt(), frame.data); err != nil {
+				slog.DebugContext(c.ctx, "error sending websocket message", "err:=", err)
+				c.shutdown(websocketCloseFrame{abort: true})
+				return
+			}
+
+		case <-ping:
+			if err := c.conn.WriteControl(gws.PingMessage, nil, time.Now().Add(c.writeTimeout)); err != nil {
+				slog.DebugContext(c.ctx, "error pinging websocket client", "err:=", err)
+				c.shutdown(websocketCloseFrame{abort: true})
+				return
+			}
+
+		case <-c.done:
+			if c.closeFrame.abort {
+				return
+			}
+
+			// flush the queued messages first
+			deadline := time.Now().Add(websocketCloseTimeout)
+			_ = c.conn.SetWriteDeadline(deadline)
+			for len(c.send) > 0 {
+				frame := <-c.send
+				if err := c.conn.WriteMessage(frame.messageType.ToInt(), frame.data); err != nil {
+					return
+				}
+			}
+
+			_ = c.conn.WriteControl(gws.CloseMessage, gws.FormatCloseMessage(c.closeFrame.code, c.closeFrame.reason), deadline)
+			return
+		}
+	}
+}
+
+// defaultWebsocketDecode decodes text messages holding JSON, and passes other
+// text messages as strings and binary messages as []byte.
+func defaultWebsocketDecode(ctx context.Context, messageType types.WebsocketMessageType, data []byte) (interface{}, error) {
+	if messageType == types.WebsocketBinaryMessage {
+		return data, nil
+	}
+
+	var message interface{}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return string(data), nil
+	}
+	return message, nil
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// isExtendedConnect reports whether r bootstraps a WebSocket session on its
+// stream with an extended CONNECT request, over HTTP/2 (RFC 8441) or HTTP/3
+// (RFC 9220).
+func isExtendedConnect(r *http.Request) bool {
+	return strings.EqualFold(extendedConnectProtocol(r), "websocket")
+}
+
+// extendedConnectProtocol returns the :protocol of an extended CONNECT
+// request, or "" for other requests, including classic CONNECT ones.
+func extendedConnectProtocol(r *http.Request) string {
+	if r.Method != http.MethodConnect {
+		return ""
+	}
+	// HTTP/3 requests carry :protocol as the protocol, HTTP/2 ones as a header
+	if r.ProtoMajor == 3 {
+		if r.Proto == "HTTP/3.0" {
+			return ""
+		}
+		return r.Proto
+	}
+	return r.Header.Get(":protocol")
+}
+
+// extendedConnectUpgrade adapts an extended CONNECT request to the HTTP/1.1
+// upgrade the upgrader expects. The response writer is hijacked as a net.Conn
+// over the request stream, answering the handshake with a 200 response.
+func extendedConnectUpgrade(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+	upgrade := r.Clone(r.Context())
+	upgrade.Method = http.MethodGet
+	upgrade.Header.Del(":protocol")
+	upgrade.Header.Set("Connection", "Upgrade")
+	upgrade.Header.Set("Upgrade", "websocket")
+
+	// the stream is already dedicated to the session, the key only satisfies
+	// the upgrader
+	key := make([]byte, 16)
+	_, _ = rand.Read(key)
+	upgrade.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+
+	return &extendedConnectWriter{ResponseWriter: w, r: r}, upgrade
+}
+
+type extendedConnectWriter struct {
+	http.ResponseWriter
+	r *http.Request
+}
+
+func (w *extendedConnectWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn := &extendedConnectConn{
+		w:    w.ResponseWriter,
+		rc:   http.NewResponseController(w.ResponseWriter),
+		body: w.r.Body,
+		r:    w.r,
+	}
+	return conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), nil
+}
+
+// extendedConnectConn is a net.Conn over the stream of an extended CONNECT
+// request, reading from its body and writing to its response.
+type extendedConnectConn struct {
+	w    http.ResponseWriter
+	rc   *http.ResponseController
+	body io.ReadCloser
+	r    *http.Request
+
+	// writes are serialized by the websocket connection
+	handshakeDone bool
+	closed        atomic.Bool
+}
+
+func (c *extendedConnectConn) Read(p []byte) (int, error) {
+	if c.closed.Load() {
+		return 0, net.ErrClosed
+	}
+	return c.body.Read(p)
+}
+
+// Write sends the response to the handshake on the first call, and messages
+// after that.
+func (c *extendedConnectConn) Write(p []byte) (int, error) {
+	if c.closed.Load() {
+		return 0, net.ErrClosed
+	}
+
+	if !c.handshakeDone {
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(p)), nil)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			return 0, errors.New("websocket: unexpected handshake response " + resp.Status)
+		}
+
+		for key, values := range resp.Header {
+			switch key {
+			case "Upgrade", "Connection", "Sec-Websocket-Accept":
+				continue
+			}
+			c.w.Header()[key] = values
+		}
+		c.w.WriteHeader(http.StatusOK)
+		if err := c.rc.Flush(); err != nil {
+			return 0, err
+		}
+
+		c.handshakeDone = true
+		return len(p), nil
+	}
+
+	n, err := c.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, c.rc.Flush()
+}
+
+// Close ends reading from the stream. The stream itself is closed once the
+// handler returns.
+func (c *extendedConnectConn) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	return c.body.Close()
+}
+
+func (c *extendedConnectConn) LocalAddr() net.Addr {
+	if addr, ok := c.r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		return addr
+	}
+	return extendedConnectAddr("")
+}
+
+func (c *extendedConnectConn) RemoteAddr() net.Addr {
+	return extendedConnectAddr(c.r.RemoteAddr)
+}
+
+func (c *extendedConnectConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *extendedConnectConn) SetReadDeadline(t time.Time) error {
+	return ignoreNotSupported(c.rc.SetReadDeadline(t))
+}
+
+func (c *extendedConnectConn) SetWriteDeadline(t time.Time) error {
+	return ignoreNotSupported(c.rc.SetWriteDeadline(t))
+}
+
+type extendedConnectAddr string
+
+func (a extendedConnectAddr) Network() string { return "tcp" }
+func (a extendedConnectAddr) String() string  { return string(a) }
+
+func ignoreNotSupported(err error) error {
+	if errors.Is(err, http.ErrNotSupported) {
+		return nil
+	}
+	return err
+}
@@ -2,89 +2,103 @@ package server
 
 import (
 	"context"
+	"io"
 	"log/slog"
-	"net/http"
-	"strings"
-	"sync"
 
-	ashttp "github.com/ayushanand18/crazyhttp/internal/http"
 	"github.com/ayushanand18/crazyhttp/pkg/constants"
 	"github.com/ayushanand18/crazyhttp/pkg/errors"
 	"github.com/ayushanand18/crazyhttp/pkg/types"
 	gws "github.com/gorilla/websocket"
 )
 
-func websocketHandler(
-	ctx context.Context,
-	conn *gws.Conn,
-	w http.ResponseWriter,
-	r *http.Request,
-	ws *websocket,
-	handler types.WebsocketHandlerFunc,
-) {
+// websocketConnHandler serves a session with a WebsocketConnHandlerFunc,
+// closing it once the handler returns.
+func websocketConnHandler(ctx context.Context, conn *websocketConn, handler types.WebsocketConnHandlerFunc) error {
+	err := handler(ctx, conn)
+	if err != nil {
+		slog.ErrorContext(ctx, "error in serving websocket", append([]interface{}{"err:=", err}, errors.KeyValues(err)...)...)
+		_ = conn.Close(gws.CloseInternalServerErr, errors.PublicMessage(err))
+		return err
+	}
+
+	_ = conn.Close(gws.CloseNormalClosure, "")
+	return nil
+}
+
+// websocketMessageHandler serves each message of a session with a HandlerFunc,
+// sending back its response unless nil. Errors serving a message are sent to
+// the client as messages, and the session is kept open.
+func websocketMessageHandler(ctx context.Context, conn *websocketConn, handler types.HandlerFunc) error {
+	for {
+		msgCtx, message, err := conn.recv()
+		if err == io.EOF || errors.IsType(err, errors.ClientClosedRequest) {
+			return nil
+		}
+
+		var response interface{}
+		if err == nil {
+			response, err = handler(msgCtx, message.Data)
+		}
+		if err == nil && response != nil {
+			err = conn.Send(response)
+		}
+		if err != nil {
+			slog.ErrorContext(msgCtx, "error in serving websocket message", append([]interface{}{"err:=", err}, errors.KeyValues(err)...)...)
+			if sendErr := conn.sendError(msgCtx, err); sendErr != nil {
+				return sendErr
+			}
+		}
+	}
+}
+
+// websocketHandler serves a session with a WebsocketHandlerFunc, exchanging
+// messages through the channels in its context, see
+// constants.WebsocketRequestChannel. Messages received are passed raw and
+// decoded, after the before serve middlewares; errors serving one are sent to
+// the client as messages, and the session is kept open. The request channel is
+// closed once the client closes the session; the response channel, which the
+// handler sends on, is never closed, and the context is canceled instead.
+func websocketHandler(ctx context.Context, conn *websocketConn, handler types.WebsocketHandlerFunc) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	requestChannel := make(chan types.WebsocketStreamChunk)
 	responseChannel := make(chan types.WebsocketStreamChunk)
 
-	// helper to close all channels once
-	var once sync.Once
-	closeAll := func() {
-		once.Do(func() {
-			close(requestChannel)
-			close(responseChannel)
-		})
-	}
-
 	// attach to context
 	ctx = context.WithValue(ctx, constants.WebsocketRequestChannel, requestChannel)
 	ctx = context.WithValue(ctx, constants.WebsocketResponseChannel, responseChannel)
 
-	// Reader goroutine
+	// Reader goroutine, the only sender on the request channel
 	go func() {
 		defer func() {
 			cancel()
-			closeAll()
+			close(requestChannel)
 		}()
 
 		for {
-			mt, message, err := conn.ReadMessage()
-			if err != nil {
-				if gws.IsCloseError(err,
-					gws.CloseGoingAway,
-					gws.CloseNormalClosure) {
-					slog.Info("WebSocket connection closed by client")
-				} else {
-					slog.Error("Error receiving WebSocket message", "error", err)
-				}
+			msgCtx, message, err := conn.recv()
+			if err == io.EOF {
+				slog.Info("WebSocket connection closed by client")
 				return
 			}
-
-			if ws.rateLimiter != nil {
-				key := ctx.Value(constants.RateLimitCustomKey)
-				if key == nil || key == "" {
-					key = strings.Split(r.RemoteAddr, ":")[0]
-				}
-				k, ok := key.(string)
-				if !ok {
-					w.WriteHeader(http.StatusInternalServerError)
-					slog.ErrorContext(ctx, "rate limit key is not a string", "key:=", key)
-					return
-				}
-				ws.rateLimiter.Allow(k)
+			if errors.IsType(err, errors.ClientClosedRequest) {
+				slog.Error("Error receiving WebSocket message", "error", err)
+				return
 			}
-
-			msg, err := ashttp.GetDefaultSerialization(message)
 			if err != nil {
-				w.WriteHeader(errors.DecodeErrorToHttpErrorStatus(err))
-				return
+				slog.ErrorContext(msgCtx, "error in serving websocket message", append([]interface{}{"err:=", err}, errors.KeyValues(err)...)...)
+				if sendErr := conn.sendError(msgCtx, err); sendErr != nil {
+					return
+				}
+				continue
 			}
 
 			select {
 			case requestChannel <- types.WebsocketStreamChunk{
-				MessageType: types.WebsocketMessageType(mt),
-				Data:        msg,
+				MessageType: message.MessageType,
+				Data:        message.Raw,
+				Message:     message.Data,
 			}:
 			case <-ctx.Done():
 				return
@@ -92,54 +106,40 @@ func websocketHandler(
 		}
 	}()
 
-	// Handler goroutine
+	// Handler goroutine, the only sender on the response channel
+	handlerDone := make(chan struct{})
 	go func() {
+		defer close(handlerDone)
 		defer cancel()
 		handler(ctx)
 	}()
 
-	// Writer loop (main goroutine)
-	for {
-		select {
-		case chunk, ok := <-responseChannel:
-			if !ok {
-				return
-			}
-
-			if chunk.MessageType == types.WebsocketUnspecifiedMessage {
-				chunk.MessageType = types.WebsocketTextMessage
-			}
-
-			var headers map[string][]string
-			var encoded []byte
-			var err error
-
-			if ws.encoder != nil {
-				headers, encoded, err = ws.encoder(ctx, chunk.Data, nil)
-			} else {
-				headers, encoded, err = ashttp.DefaultHttpEncode(ctx, chunk.Data)
-			}
-			if err != nil {
-				w.WriteHeader(errors.DecodeErrorToHttpErrorStatus(err))
-				return
-			}
-
-			for key, value := range headers {
-				w.Header().Del(key)
-				for _, v := range value {
-					w.Header().Add(key, v)
+	// responses sent after the session ended are discarded, so that handlers
+	// not selecting on ctx.Done() do not block forever
+	defer func() {
+		go func() {
+			for {
+				select {
+				case <-responseChannel:
+				case <-handlerDone:
+					return
 				}
 			}
+		}()
+	}()
 
-			if err := conn.WriteMessage(chunk.MessageType.ToInt(), encoded); err != nil {
+	// Writer loop (main goroutine)
+	for {
+		select {
+		case chunk := <-responseChannel:
+			if err := conn.SendMessage(chunk.MessageType, chunk.Data); err != nil {
 				slog.Error("Error sending WebSocket message", "error", err)
-				return
+				return err
 			}
 
 		case <-ctx.Done():
 			// someone canceled (reader, handler, or connection closed)
-			closeAll()
-			return
+			return nil
 		}
 	}
 }
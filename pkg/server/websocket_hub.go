@@ -0,0 +1,222 @@
+package server
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+)
+
+// WebsocketHub addresses the open WebSocket sessions of a server, by session
+// id or by room. Messages sent through the hub go through the encoder and after
+// serve middlewares of the endpoint each session belongs to, and never block:
+// if a session's send buffer is full, its slow consumer policy applies.
+type WebsocketHub interface {
+	// Broadcast sends message to every session in room, returning the number
+	// of sessions it was queued for, and the last error sending it.
+	Broadcast(room string, message interface{}) (int, error)
+	// SendTo sends message to the session with the given id.
+	SendTo(sessionId string, message interface{}) error
+	// Sessions lists the sessions in room, or all sessions if room is empty.
+	Sessions(room string) []types.WebsocketSession
+	// Rooms lists the rooms with at least one session.
+	Rooms() []string
+}
+
+type websocketHub struct {
+	mu       sync.RWMutex
+	sessions map[string]*websocketConn
+	rooms    map[string]map[string]*websocketConn
+}
+
+func newWebsocketHub() *websocketHub {
+	return &websocketHub{
+		sessions: make(map[string]*websocketConn),
+		rooms:    make(map[string]map[string]*websocketConn),
+	}
+}
+
+func (s *server) Hub() WebsocketHub {
+	return s.hub
+}
+
+func (h *websocketHub) Broadcast(room string, message interface{}) (int, error) {
+	h.mu.RLock()
+	conns := make([]*websocketConn, 0, len(h.rooms[room]))
+	for _, c := range h.rooms[room] {
+		conns = append(conns, c)
+	}
+	h.mu.RUnlock()
+
+	var sent int
+	var lastErr error
+	for _, c := range conns {
+		if err := h.offer(c, message); err != nil {
+			lastErr = err
+			continue
+		}
+		sent++
+	}
+
+	return sent, lastErr
+}
+
+func (h *websocketHub) SendTo(sessionId string, message interface{}) error {
+	h.mu.RLock()
+	c, ok := h.sessions[sessionId]
+	h.mu.RUnlock()
+	if !ok {
+		return errors.NotFound.Newf("websocket session %s not found", sessionId)
+	}
+
+	return h.offer(c, message)
+}
+
+func (h *websocketHub) Sessions(room string) []types.WebsocketSession {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	conns := h.sessions
+	if room != "" {
+		conns = h.rooms[room]
+	}
+
+	sessions := make([]types.WebsocketSession, 0, len(conns))
+	for _, c := range conns {
+		sessions = append(sessions, h.sessionLocked(c))
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ConnectedAt.Before(sessions[j].ConnectedAt)
+	})
+
+	return sessions
+}
+
+func (h *websocketHub) Rooms() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	rooms := make([]string, 0, len(h.rooms))
+	for room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+
+	return rooms
+}
+
+// closeAll closes every session with code, waiting for their queued messages
+// to be sent.
+func (h *websocketHub) closeAll(code int, reason string) {
+	h.mu.RLock()
+	conns := make([]*websocketConn, 0, len(h.sessions))
+	for _, c := range h.sessions {
+		conns = append(conns, c)
+	}
+	h.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.close(websocketCloseFrame{code: code, reason: reason})
+		}()
+	}
+	wg.Wait()
+}
+
+func (h *websocketHub) offer(c *websocketConn, message interface{}) error {
+	frame, err := c.encode(types.WebsocketTextMessage, message)
+	if err != nil {
+		return err
+	}
+
+	return c.offer(frame)
+}
+
+func (h *websocketHub) register(c *websocketConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sessions[c.id] = c
+}
+
+func (h *websocketHub) unregister(c *websocketConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.sessions, c.id)
+	for room := range c.joined {
+		h.leaveLocked(c, room)
+	}
+}
+
+func (h *websocketHub) join(c *websocketConn, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// closed sessions cannot rejoin
+	if _, ok := h.sessions[c.id]; !ok {
+		return
+	}
+
+	if h.rooms[room] == nil {
+		h.rooms[room] = make(map[string]*websocketConn)
+	}
+	h.rooms[room][c.id] = c
+	c.joined[room] = struct{}{}
+}
+
+func (h *websocketHub) leave(c *websocketConn, room string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.leaveLocked(c, room)
+}
+
+func (h *websocketHub) leaveLocked(c *websocketConn, room string) {
+	delete(c.joined, room)
+	delete(h.rooms[room], c.id)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+	}
+}
+
+func (h *websocketHub) setMetadata(c *websocketConn, key, value string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c.metadata[key] = value
+}
+
+func (h *websocketHub) session(c *websocketConn) types.WebsocketSession {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.sessionLocked(c)
+}
+
+func (h *websocketHub) sessionLocked(c *websocketConn) types.WebsocketSession {
+	rooms := make([]string, 0, len(c.joined))
+	for room := range c.joined {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+
+	metadata := make(map[string]string, len(c.metadata))
+	for key, value := range c.metadata {
+		metadata[key] = value
+	}
+
+	return types.WebsocketSession{
+		Id:          c.id,
+		Url:         c.ws.Url,
+		RemoteAddr:  c.r.RemoteAddr,
+		Subprotocol: c.conn.Subprotocol(),
+		Rooms:       rooms,
+		Metadata:    metadata,
+		ConnectedAt: c.connectedAt,
+	}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+
+	ashttp "github.com/ayushanand18/crazyhttp/internal/http"
+	"github.com/ayushanand18/crazyhttp/pkg/errors"
+	"github.com/ayushanand18/crazyhttp/pkg/types"
+	"github.com/quic-go/webtransport-go"
+)
+
+type webTransport struct {
+	Url string
+	s   *server
+
+	admission *admissionController
+	handshake types.WebTransportHandshakeFunc
+	options   types.WebTransportOption
+}
+
+type WebTransport interface {
+	// Serve serves each session with handler, closing it once the handler
+	// returns
+	Serve(handler types.WebTransportHandlerFunc)
+
+	// HandleHandshake to inspect CONNECT requests opening sessions, or reject
+	// them with an error
+	HandleHandshake(types.WebTransportHandshakeFunc) WebTransport
+	// WithOptions to add serve options
+	WithOptions(options types.WebTransportOption) WebTransport
+	// WithConcurrencyLimit limits the number of sessions open concurrently on
+	// this endpoint, in addition to the server-wide stream limit
+	WithConcurrencyLimit(options types.ConcurrencyLimitOptions) WebTransport
+}
+
+func NewWebTransport(url string, s *server) WebTransport {
+	return &webTransport{Url: url, s: s}
+}
+
+func (wt *webTransport) Serve(handler types.WebTransportHandlerFunc) {
+	wt.s.webtransportEnabled = true
+	wt.s.mux.HandleFunc(wt.Url, wt.handle(handler)).Methods(http.MethodConnect)
+}
+
+func (wt *webTransport) HandleHandshake(fn types.WebTransportHandshakeFunc) WebTransport {
+	wt.handshake = fn
+	return wt
+}
+
+func (wt *webTransport) WithOptions(options types.WebTransportOption) WebTransport {
+	wt.options = options
+	return wt
+}
+
+func (wt *webTransport) WithConcurrencyLimit(options types.ConcurrencyLimitOptions) WebTransport {
+	wt.admission = newAdmissionController(options)
+	return wt
+}
+
+func (wt *webTransport) handle(handler types.WebTransportHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 3 || r.Proto != "webtransport" {
+			wt.s.writeError(r.Context(), w, r, nil, nil, errors.BadRequest.New("expected a WebTransport CONNECT request over HTTP/3"))
+			return
+		}
+
+		if err := rejectEarlyData(r, false); err != nil {
+			wt.s.writeError(r.Context(), w, r, nil, nil, err)
+			return
+		}
+
+		release, err := admit(r.Context(), w, wt.admission, wt.s.streamAdmission)
+		if err != nil {
+			wt.s.writeError(r.Context(), w, r, nil, nil, err)
+			return
+		}
+		defer release()
+
+		ctx, err := defaultMiddleware(r.Context(), r)
+		if err != nil {
+			slog.ErrorContext(ctx, "error in default middlewares", "err:=", err)
+			return
+		}
+
+		if len(wt.options.AllowedOrigins) > 0 && !ashttp.IsOriginAllowed(r.Header.Get("Origin"), wt.options.AllowedOrigins) {
+			wt.s.writeError(ctx, w, r, nil, nil, errors.Forbidden.New("origin not allowed"))
+			return
+		}
+
+		if wt.handshake != nil {
+			ctx, err = wt.handshake(ctx, r)
+			if err != nil {
+				wt.s.writeError(ctx, w, r, nil, nil, err)
+				return
+			}
+		}
+
+		session, err := wt.s.webtransport.Upgrade(w, r)
+		if err != nil {
+			wt.s.writeError(ctx, w, r, nil, nil, errors.BadRequest.Wrap(err, "could not open WebTransport session"))
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(session.Context(), cancel)
+		defer stop()
+
+		wt.s.webtransportSessions.Store(session, struct{}{})
+		defer wt.s.webtransportSessions.Delete(session)
+
+		if err := handler(ctx, &webTransportSession{session: session}); err != nil {
+			slog.ErrorContext(ctx, "error in serving webtransport session", append([]interface{}{"err:=", err}, errors.KeyValues(err)...)...)
+			_ = session.CloseWithError(1, errors.PublicMessage(err))
+			return
+		}
+		_ = session.CloseWithError(0, "")
+	}
+}
+
+// closeWebTransportSessions closes every open WebTransport session.
+func (s *server) closeWebTransportSessions(code uint32, reason string) {
+	s.webtransportSessions.Range(func(key, _ any) bool {
+		_ = key.(*webtransport.Session).CloseWithError(webtransport.SessionErrorCode(code), reason)
+		return true
+	})
+}
+
+// webTransportSession implements types.WebTransportSession.
+type webTransportSession struct {
+	session *webtransport.Session
+}
+
+func (s *webTransportSession) AcceptStream(ctx context.Context) (types.WebTransportStream, error) {
+	return s.session.AcceptStream(ctx)
+}
+
+func (s *webTransportSession) AcceptUniStream(ctx context.Context) (types.WebTransportReceiveStream, error) {
+	return s.session.AcceptUniStream(ctx)
+}
+
+func (s *webTransportSession) OpenStream(ctx context.Context) (types.WebTransportStream, error) {
+	return s.session.OpenStreamSync(ctx)
+}
+
+func (s *webTransportSession) OpenUniStream(ctx context.Context) (types.WebTransportSendStream, error) {
+	return s.session.OpenUniStreamSync(ctx)
+}
+
+func (s *webTransportSession) SendDatagram(data []byte) error {
+	return s.session.SendDatagram(data)
+}
+
+func (s *webTransportSession) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	return s.session.ReceiveDatagram(ctx)
+}
+
+func (s *webTransportSession) RemoteAddr() net.Addr {
+	return s.session.RemoteAddr()
+}
+
+func (s *webTransportSession) Close(code uint32, reason string) error {
+	return s.session.CloseWithError(webtransport.SessionErrorCode(code), reason)
+}
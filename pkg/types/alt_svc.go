@@ -0,0 +1,33 @@
+package types
+
+import "time"
+
+// AltSvcOptions configures how the HTTP/3 server is advertised to HTTP/1.1
+// and HTTP/2 clients, with the Alt-Svc header (RFC 7838). The header is only
+// sent while the HTTP/3 server is listening, for the port it is bound to, and
+// is cleared once the server shuts down, unless for an upgrade.
+//
+// There is no option for HTTP/2 ORIGIN frames (RFC 8336): the HTTP/2 server of
+// net/http cannot send frames of extension types, so clients coalesce
+// connections based on the certificate and DNS only.
+//
+// Fields
+//
+//	Disabled: Stops advertising HTTP/3.
+//	MaxAge:   How long clients may keep using HTTP/3 without checking the
+//	          header again. Defaults to 30 days.
+//	Host:     The host serving HTTP/3, if not the origin itself.
+//	Port:     The port to advertise instead of the bound one, e.g. when a
+//	          load balancer maps ports.
+//	Persist:  Asks clients to keep the alternative across network changes.
+//	DNSName:  The domain to log an HTTPS DNS record (RFC 9460) for once
+//	          listening. Publishing it lets clients use HTTP/3 from their
+//	          first request, rather than after an Alt-Svc header.
+type AltSvcOptions struct {
+	Disabled bool
+	MaxAge   time.Duration
+	Host     string
+	Port     int
+	Persist  bool
+	DNSName  string
+}
@@ -0,0 +1,50 @@
+package types
+
+import "context"
+
+// DatagramOptions configures the HTTP Datagrams (RFC 9297) exchanged on a
+// request stream.
+//
+// Fields
+//
+//	ReceiveBufferSize: The number of received datagrams queued for the
+//	                   handler. Once full, further QUIC datagrams are
+//	                   dropped until it catches up, while capsules are left
+//	                   unread on the request stream. Defaults to 64.
+//	MaxDatagramSize:   The maximum size of a datagram received in a capsule,
+//	                   larger capsules fail the request. Defaults to 64KiB.
+type DatagramOptions struct {
+	ReceiveBufferSize int
+	MaxDatagramSize   int
+}
+
+// DatagramConn exchanges HTTP Datagrams associated with a request stream.
+// Over HTTP/3, datagrams are sent as QUIC datagrams, which may be lost or
+// reordered. Over HTTP/1.1 and HTTP/2, they are sent reliably as DATAGRAM
+// capsules on the request and response bodies.
+type DatagramConn interface {
+	// SendDatagram sends a datagram. Over HTTP/3, it must fit in a single
+	// QUIC packet.
+	SendDatagram(data []byte) error
+	// ReceiveDatagram waits for a datagram from the client, returning io.EOF
+	// once the client closed the request stream.
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+	// Capsules reports whether datagrams are carried as capsules on the
+	// request stream rather than as QUIC datagrams.
+	Capsules() bool
+}
+
+// DatagramHandlerFunc defines a function for serving a request exchanging
+// HTTP Datagrams. The response status is sent before the handler is called,
+// and the request stream is closed once it returns.
+//
+// Parameters
+//
+//	ctx:       The request-scoped context, canceled once the client closes
+//	           the request stream.
+//	datagrams: The datagrams of the request stream.
+//
+// Returns
+//
+//	err: A non-nil error is logged and aborts the request stream.
+type DatagramHandlerFunc func(ctx context.Context, datagrams DatagramConn) error
@@ -0,0 +1,101 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/textproto"
+	"net/url"
+	"os"
+)
+
+// FileSink opens the destination an uploaded file is streamed to, instead of
+// a temporary file. Closing the writer completes the upload.
+type FileSink func(ctx context.Context, file *UploadedFile) (io.WriteCloser, error)
+
+// FormOptions configures the decoding of multipart/form-data and
+// application/x-www-form-urlencoded request bodies.
+//
+// Fields
+//
+//	MaxFileSize:      The maximum size of a single uploaded file. Defaults to
+//	                  MaxTotalSize.
+//	MaxTotalSize:     The maximum size of the whole request body. Defaults to
+//	                  32MiB.
+//	MaxFieldSize:     The maximum size of a single non-file field. Defaults to
+//	                  1MiB.
+//	TempDir:          The directory uploaded files are streamed to. Defaults
+//	                  to os.TempDir().
+//	Sink:             Streams uploaded files elsewhere than TempDir, e.g. to
+//	                  object storage. Files written to a sink are not removed
+//	                  after the handler returns.
+//	AllowedMimeTypes: The content types accepted for uploaded files, sniffed
+//	                  from their content rather than trusted from the client.
+//	                  Wildcards such as "image/*" are supported. Empty allows
+//	                  any type.
+type FormOptions struct {
+	MaxFileSize      int64
+	MaxTotalSize     int64
+	MaxFieldSize     int64
+	TempDir          string
+	Sink             FileSink
+	AllowedMimeTypes []string
+}
+
+// Form is a decoded form request body, passed to handlers as the request.
+// Bind it to a struct with server.DecodeFormRequest.
+//
+// Fields
+//
+//	Values: The non-file fields of the form.
+//	Files:  The uploaded files of the form, by field name.
+type Form struct {
+	Values url.Values
+	Files  map[string][]*UploadedFile
+}
+
+// UploadedFile describes a file uploaded with a multipart form.
+//
+// Fields
+//
+//	FieldName:   The name of the form field the file was uploaded with.
+//	Filename:    The file name sent by the client. It must not be trusted as a
+//	             path.
+//	ContentType: The content type sniffed from the first bytes of the file.
+//	Size:        The size of the file in bytes.
+//	Header:      The MIME header of the part.
+//	Path:        The temporary file the upload was streamed to, removed after
+//	             the handler returns. Empty if streamed to a FileSink.
+type UploadedFile struct {
+	FieldName   string
+	Filename    string
+	ContentType string
+	Size        int64
+	Header      textproto.MIMEHeader
+	Path        string
+}
+
+// Open opens the temporary file the upload was streamed to.
+func (f *UploadedFile) Open() (io.ReadCloser, error) {
+	if f.Path == "" {
+		return nil, errors.New("uploaded file was streamed to a sink")
+	}
+	return os.Open(f.Path)
+}
+
+// RemoveAll removes the temporary files of the form. It is called once the
+// handler returns.
+func (f *Form) RemoveAll() error {
+	var err error
+	for _, files := range f.Files {
+		for _, file := range files {
+			if file.Path == "" {
+				continue
+			}
+			if e := os.Remove(file.Path); e != nil && !errors.Is(e, os.ErrNotExist) && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
@@ -3,11 +3,30 @@ package types
 import (
 	"context"
 	"net/http"
+	"time"
 )
 
 type MethodOptions struct {
 	IsStreamingResponse bool
 	AllowedOrigins      []string
+
+	// StreamingMode selects the framing of a streaming response
+	StreamingMode StreamingMode
+	// StreamingContentType overrides the content type of a streaming response,
+	// e.g. for StreamingModeRaw responses which are not event streams
+	StreamingContentType string
+	// SSE configures a StreamingModeSSE response
+	SSE SSEOptions
+	// RequestStream configures how a streamed request body is read
+	RequestStream RequestStreamOptions
+	// Form configures how form request bodies are decoded
+	Form FormOptions
+	// Datagram configures the HTTP Datagrams of a datagram handler
+	Datagram DatagramOptions
+	// AllowEarlyData serves requests with unsafe methods received in 0-RTT
+	// data, which may be replayed, instead of rejecting them with 425 Too
+	// Early. Only enable it for idempotent handlers.
+	AllowEarlyData bool
 }
 
 // HandlerFunc defines a function for serving HTTP requests.
@@ -108,14 +127,100 @@ type RateLimitOptions struct {
 	ContextKey              string // key in context which will be checked for rate limiting
 }
 
+// AdmissionAlgorithm selects how a concurrency limit adapts to load.
+//
+// # Constants
+//
+//	AdmissionFixed:    The limit stays at MaxInFlight.
+//	AdmissionAIMD:     The limit grows additively while requests complete within
+//	                   TargetLatency, and is cut multiplicatively when they do not.
+//	AdmissionGradient: The limit follows the ratio of the lowest observed latency
+//	                   to the current latency, shedding load as latency rises.
+type AdmissionAlgorithm int
+
+const (
+	AdmissionFixed AdmissionAlgorithm = iota
+	AdmissionAIMD
+	AdmissionGradient
+)
+
+// ConcurrencyLimitOptions specifies the maximum number of requests served
+// concurrently, beyond which requests are queued and then rejected with a
+// 503 Service Unavailable.
+//
+// Fields
+//
+//	MaxInFlight:   Maximum number of requests served concurrently. With an
+//	               adaptive algorithm, this is the initial limit.
+//	MaxQueue:      Maximum number of requests waiting for a slot. Requests
+//	               beyond it are rejected immediately.
+//	QueueTimeout:  Maximum time a request waits in the queue before being
+//	               rejected. Zero waits until the request is canceled.
+//	RetryAfter:    Value of the Retry-After header sent on rejection.
+//	               Defaults to 1 second.
+//	Algorithm:     How the limit adapts to observed latency.
+//	MinInFlight:   Lower bound of an adaptive limit.
+//	MaxAdaptive:   Upper bound of an adaptive limit. Defaults to 10 times
+//	               MaxInFlight.
+//	TargetLatency: Latency above which AdmissionAIMD reduces the limit.
+type ConcurrencyLimitOptions struct {
+	MaxInFlight   int
+	MaxQueue      int
+	QueueTimeout  time.Duration
+	RetryAfter    time.Duration
+	Algorithm     AdmissionAlgorithm
+	MinInFlight   int
+	MaxAdaptive   int
+	TargetLatency time.Duration
+}
+
 // WebSocketOption defines configuration options for a WebSocket endpoint.
 //
 // Fields
 //
-//	AllowedOrigins: A list of allowed origin URLs for incoming WebSocket
-//	                upgrade requests. If empty, no origin restriction is applied.
+//	AllowedOrigins:     A list of allowed origin URLs for incoming WebSocket
+//	                    upgrade requests. If empty, no origin restriction is
+//	                    applied.
+//	Subprotocols:       The subprotocols supported, in order of preference,
+//	                    negotiated unless the handshake selects one.
+//	SendBufferSize:     The number of messages queued for sending on each
+//	                    session. Defaults to 16.
+//	SlowConsumerPolicy: What to do with messages sent through the hub to a
+//	                    session whose send buffer is full.
+//	PingInterval:       How often to ping the client. If zero, no pings are
+//	                    sent.
+//	PongTimeout:        How long to wait for a pong, or any message, after a
+//	                    ping before the session is considered dead. Defaults
+//	                    to half the ping interval.
+//	ReadTimeout:        How long a session may stay idle without messages or
+//	                    pongs from the client. If zero, only pings apply.
+//	WriteTimeout:       The deadline for writing each message. Defaults to
+//	                    10 seconds.
+//	MaxMessageSize:     The maximum size of a message from the client once
+//	                    decompressed, larger messages close the session with
+//	                    code 1009. If zero, messages are unlimited.
+//	ReadBufferSize:     The size of the I/O read buffer, in bytes.
+//	WriteBufferSize:    The size of the I/O write buffer, in bytes.
+//	EnableCompression:  Negotiates per-message deflate (RFC 7692) with
+//	                    clients which offer it.
+//	CompressionLevel:   The flate compression level, from -2 to 9. Defaults
+//	                    to 1, best speed.
 type WebSocketOption struct {
-	AllowedOrigins []string
+	AllowedOrigins     []string
+	Subprotocols       []string
+	SendBufferSize     int
+	SlowConsumerPolicy WebsocketSlowConsumerPolicy
+
+	PingInterval   time.Duration
+	PongTimeout    time.Duration
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	MaxMessageSize int64
+
+	ReadBufferSize    int
+	WriteBufferSize   int
+	EnableCompression bool
+	CompressionLevel  int
 }
 
 // WebsocketHandlerFunc defines a function type for handling a WebSocket
@@ -0,0 +1,47 @@
+package types
+
+import "time"
+
+// HTTP2Options configures HTTP/2, served over TLS by the HTTPS server, and
+// in cleartext (h2c) by the HTTP/1.1 server once enabled. Zero values keep
+// the golang.org/x/net/http2 defaults.
+//
+// Fields
+//
+//	H2C:                  Serves HTTP/2 without TLS on the HTTP/1.1 server,
+//	                      to clients with prior knowledge or upgrading with
+//	                      "Upgrade: h2c". Leave it off behind proxies which
+//	                      forward Upgrade headers, as clients could tunnel
+//	                      requests past the proxy's access rules.
+//	MaxConcurrentStreams: The maximum number of concurrent streams, i.e.
+//	                      requests, per connection. Defaults to 250.
+//	MaxReadFrameSize:     The largest frame the server accepts, between 16KiB
+//	                      and 16MiB. Defaults to 1MiB.
+//	ConnectionWindowSize: The flow-control window of each connection, in
+//	                      bytes. Defaults to 1MiB.
+//	StreamWindowSize:     The flow-control window of each stream, in bytes.
+//	                      Defaults to 1MiB.
+//	IdleTimeout:          How long a connection may stay idle before it is
+//	                      closed. Defaults to service.http.timeouts.idle.
+//	ReadIdleTimeout:      How long a connection may go without receiving a
+//	                      frame before the server checks it with a ping. If
+//	                      zero, no pings are sent.
+//	PingTimeout:          How long to wait for the answer to a ping before
+//	                      closing the connection. Defaults to 15 seconds.
+//	WriteByteTimeout:     How long a write may go without progress before the
+//	                      connection is closed. If zero, writes do not time
+//	                      out.
+type HTTP2Options struct {
+	H2C bool
+
+	MaxConcurrentStreams uint32
+	MaxReadFrameSize     uint32
+
+	ConnectionWindowSize int32
+	StreamWindowSize     int32
+
+	IdleTimeout      time.Duration
+	ReadIdleTimeout  time.Duration
+	PingTimeout      time.Duration
+	WriteByteTimeout time.Duration
+}
@@ -0,0 +1,34 @@
+package types
+
+import "net"
+
+// Listeners are sockets to serve on instead of binding the configured
+// addresses, e.g. sockets inherited from a parent process, or bound to an
+// ephemeral port by a test. A server given a listener is served even if not
+// enabled in the config, and the listener is closed on shutdown.
+//
+// Fields
+//
+//	H3:    The UDP socket of the HTTP/3 server.
+//	H1:    The listener of the HTTP/1.1 server.
+//	H1TLS: The listener of the HTTPS server, accepting connections before
+//	       their TLS handshake.
+type Listeners struct {
+	H3    net.PacketConn
+	H1    net.Listener
+	H1TLS net.Listener
+}
+
+// ListenerAddrs are the addresses the servers are bound to, nil for servers
+// which are not listening.
+//
+// Fields
+//
+//	H3:    The UDP address of the HTTP/3 server.
+//	H1:    The address of the HTTP/1.1 server, TCP or Unix.
+//	H1TLS: The address of the HTTPS server, TCP or Unix.
+type ListenerAddrs struct {
+	H3    net.Addr
+	H1    net.Addr
+	H1TLS net.Addr
+}
@@ -0,0 +1,142 @@
+package types
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+// ProxyOption defines configuration options for a CONNECT proxy.
+//
+// Fields
+//
+//	UDPPathTemplate:       The path of the MASQUE CONNECT-UDP URI template,
+//	                       with {target_host} and {target_port} variables.
+//	                       Defaults to
+//	                       "/.well-known/masque/udp/{target_host}/{target_port}/".
+//	IPPathTemplate:        The path of the MASQUE CONNECT-IP URI template,
+//	                       with {target} and {ipproto} variables. Defaults to
+//	                       "/.well-known/masque/ip/{target}/{ipproto}/".
+//	DialTimeout:           The maximum time to connect to a target. Defaults
+//	                       to 10 seconds.
+//	AllowPrivateAddresses: Allows targets resolving to loopback, private,
+//	                       link-local or unspecified addresses, which are
+//	                       rejected by default so the proxy cannot reach
+//	                       internal hosts. The address actually dialed is
+//	                       checked, so DNS cannot be used to bypass it. For
+//	                       CONNECT-IP, the destination of each packet is
+//	                       checked. Multicast and broadcast targets are
+//	                       always rejected.
+//	Datagram:              Configures the datagrams of CONNECT-UDP and
+//	                       CONNECT-IP tunnels.
+type ProxyOption struct {
+	UDPPathTemplate       string
+	IPPathTemplate        string
+	DialTimeout           time.Duration
+	AllowPrivateAddresses bool
+	Datagram              DatagramOptions
+}
+
+// ProxyTarget is the destination a CONNECT request asks to be tunneled to.
+//
+// Fields
+//
+//	Network:    "tcp" for classic CONNECT tunnels, "udp" for CONNECT-UDP,
+//	            "ip" for CONNECT-IP.
+//	Host:       The target host name or IP address. For CONNECT-IP, it may
+//	            also be an IP prefix, e.g. "192.0.2.0/24", or "*" for any.
+//	Port:       The target port, zero for CONNECT-IP.
+//	IPProtocol: For CONNECT-IP, the IP protocol number the client may use,
+//	            or -1 for any.
+type ProxyTarget struct {
+	Network    string
+	Host       string
+	Port       int
+	IPProtocol int
+}
+
+// ProxyPolicyFunc defines a function deciding whether a CONNECT request may
+// open a tunnel to its target, e.g. checking Proxy-Authorization or an
+// allow list of hosts.
+//
+// Parameters
+//
+//	ctx:    The request context.
+//	r:      The CONNECT request.
+//	target: The destination of the tunnel.
+//
+// Returns
+//
+//	err: A non-nil error rejects the tunnel with an HTTP error response,
+//	     e.g. Forbidden for denied targets.
+type ProxyPolicyFunc func(ctx context.Context, r *http.Request, target ProxyTarget) error
+
+// ProxyIPHandlerFunc defines a function serving a CONNECT-IP tunnel (RFC
+// 9484) once the policy accepted it, e.g. by forwarding its packets to a TUN
+// device. The response status is sent before the handler is called, and the
+// tunnel is closed once it returns.
+//
+// Parameters
+//
+//	ctx:    The request context, canceled once the client closes the tunnel.
+//	target: The scope of the tunnel requested by the client.
+//	tunnel: The tunnel, to assign addresses to the client, advertise the
+//	        routes it may reach and exchange IP packets.
+//
+// Returns
+//
+//	err: A non-nil error resets the tunnel.
+type ProxyIPHandlerFunc func(ctx context.Context, target ProxyTarget, tunnel IPTunnel) error
+
+// IPTunnel exchanges the IP packets of a CONNECT-IP tunnel. Packets sent by
+// the client are dropped unless their source address was assigned to it, and
+// their destination and protocol are within the advertised routes and the
+// scope of the tunnel.
+type IPTunnel interface {
+	// ReadPacket waits for an IP packet from the client, returning io.EOF
+	// once the client closed the tunnel.
+	ReadPacket(ctx context.Context) ([]byte, error)
+	// WritePacket sends an IP packet to the client. Over HTTP/3, it must fit
+	// in a single QUIC packet.
+	WritePacket(packet []byte) error
+	// AssignAddresses sends the addresses assigned to the client, replacing
+	// the ones assigned before.
+	AssignAddresses(addresses ...IPAddress) error
+	// AdvertiseRoutes sends the ranges of addresses the client may reach,
+	// replacing the ones advertised before.
+	AdvertiseRoutes(routes ...IPRoute) error
+	// ReceiveAddressRequest waits for the addresses requested by the client,
+	// each of which must be answered with AssignAddresses.
+	ReceiveAddressRequest(ctx context.Context) ([]IPAddress, error)
+}
+
+// IPAddress is an address assigned to, or requested by, the client of a
+// CONNECT-IP tunnel.
+//
+// Fields
+//
+//	RequestId: The id of the request the address answers, or zero if not
+//	           requested. Requests are non-zero.
+//	Prefix:    The address and the length of its prefix. A request may ask
+//	           for any address with an unspecified one, and is rejected by
+//	           answering it with an unspecified address of full length, e.g.
+//	           0.0.0.0/32.
+type IPAddress struct {
+	RequestId uint64
+	Prefix    netip.Prefix
+}
+
+// IPRoute is a range of addresses the client of a CONNECT-IP tunnel may
+// reach.
+//
+// Fields
+//
+//	Start:    The first address of the range.
+//	End:      The last address of the range, of the same family.
+//	Protocol: The IP protocol number allowed, or zero for any.
+type IPRoute struct {
+	Start    netip.Addr
+	End      netip.Addr
+	Protocol uint8
+}
@@ -0,0 +1,82 @@
+package types
+
+import "time"
+
+// QUICOptions configures the QUIC transport of the HTTP/3 server. Zero values
+// keep the quic-go defaults. quic-go always uses CUBIC congestion control,
+// which is not configurable.
+//
+// Fields
+//
+//	HandshakeIdleTimeout:           The maximum time a handshake may stay
+//	                                idle. Defaults to 5 seconds.
+//	MaxIdleTimeout:                 How long a connection may stay idle
+//	                                before it is closed. Defaults to 30
+//	                                seconds.
+//	KeepAlivePeriod:                How often to send keep-alive packets on
+//	                                idle connections. If zero, none are sent.
+//	MaxIncomingStreams:             The maximum number of concurrent
+//	                                bidirectional streams, i.e. requests, a
+//	                                client may open. Defaults to 100.
+//	MaxIncomingUniStreams:          The maximum number of concurrent
+//	                                unidirectional streams a client may open.
+//	                                Defaults to 100.
+//	InitialStreamReceiveWindow:     The initial flow-control window of each
+//	                                stream, in bytes. Defaults to 512KiB.
+//	MaxStreamReceiveWindow:         The size the flow-control window of a
+//	                                stream may grow to. Defaults to 6MiB.
+//	InitialConnectionReceiveWindow: The initial flow-control window of each
+//	                                connection, in bytes. Defaults to 768KiB.
+//	MaxConnectionReceiveWindow:     The size the flow-control window of a
+//	                                connection may grow to. Defaults to
+//	                                15MiB.
+//	InitialPacketSize:              The size of the first packets sent,
+//	                                before path MTU discovery. Defaults to
+//	                                1280 bytes.
+//	DisablePathMTUDiscovery:        Keeps packets at the initial size.
+//	Allow0RTT:                      Accepts 0-RTT data on resumed
+//	                                connections. Requests sent as 0-RTT data
+//	                                may be replayed by an attacker.
+//	Qlog:                           Writes a qlog trace of every connection
+//	                                to QlogDir.
+//	QlogDir:                        The directory of qlog traces. Defaults to
+//	                                the QLOGDIR environment variable, or
+//	                                "qlog".
+//	DisableGSO:                     Disables UDP generic segmentation
+//	                                offload, by setting QUIC_GO_DISABLE_GSO
+//	                                in the environment of the process. It is
+//	                                a process-global knob: quic-go reads the
+//	                                variable once, so it applies to every
+//	                                quic-go user in the process, the first
+//	                                server listening decides for all, and it
+//	                                is inherited by child processes, such as
+//	                                the new process of an upgrade. Leaving it
+//	                                false keeps the environment as is.
+//	DisableECN:                     Disables explicit congestion
+//	                                notification, by setting
+//	                                QUIC_GO_DISABLE_ECN, a process-global
+//	                                knob like DisableGSO.
+type QUICOptions struct {
+	HandshakeIdleTimeout time.Duration
+	MaxIdleTimeout       time.Duration
+	KeepAlivePeriod      time.Duration
+
+	MaxIncomingStreams    int64
+	MaxIncomingUniStreams int64
+
+	InitialStreamReceiveWindow     uint64
+	MaxStreamReceiveWindow         uint64
+	InitialConnectionReceiveWindow uint64
+	MaxConnectionReceiveWindow     uint64
+
+	InitialPacketSize       uint16
+	DisablePathMTUDiscovery bool
+
+	Allow0RTT bool
+
+	Qlog    bool
+	QlogDir string
+
+	DisableGSO bool
+	DisableECN bool
+}
@@ -0,0 +1,21 @@
+package types
+
+import "net/http"
+
+// Response is an envelope a handler can return instead of a bare value to
+// control the status code, headers and cookies of a response.
+//
+// Fields
+//
+//	StatusCode: The HTTP status code to send. Defaults to 200 when zero.
+//	Headers:    Headers to be included in the response. These take precedence
+//	            over the headers produced by the encoder.
+//	Cookies:    Cookies to be set on the response via Set-Cookie.
+//	Body:       The response object to be passed to the encoder. A nil Body
+//	            is sent as an empty response.
+type Response struct {
+	StatusCode int
+	Headers    http.Header
+	Cookies    []*http.Cookie
+	Body       interface{}
+}
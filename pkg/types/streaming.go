@@ -1,15 +1,185 @@
 package types
 
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
 // StreamChunk represents a single chunk of data in a streaming HTTP response,
 // such as Server-Sent Events (SSE) or other streaming protocols.
 //
 // Fields
 //
-//	Id:   A unique identifier for the chunk, typically used to track ordering
-//	      or support reconnection/resume logic.
-//	Data: The raw byte payload of the chunk to be sent to the client.
+//	Id:      A unique identifier for the chunk, typically used to track ordering
+//	         or support reconnection/resume logic. With StreamingModeSSE, it is
+//	         sent as the event id unless zero.
+//	Data:    The raw byte payload of the chunk to be sent to the client. With
+//	         the JSON streaming modes, it is sent as a JSON string.
+//	Event:   The SSE event type. Empty for the default "message" type.
+//	Retry:   The SSE reconnection time hint for the client. Zero sends none.
+//	Comment: An SSE comment, ignored by clients. Useful to keep connections
+//	         alive or for debugging.
 type StreamChunk struct {
 	Id   uint32
 	Data []byte
+
+	Event   string
+	Retry   time.Duration
+	Comment string
+}
+
+// StreamingMode selects how chunks of a streaming response are framed.
+//
+// # Constants
+//
+//	StreamingModeRaw:       Chunks are written as encoded, with a
+//	                        text/event-stream content type unless set
+//	                        otherwise.
+//	StreamingModeSSE:       Chunks are framed as Server-Sent Events.
+//	StreamingModeNDJSON:    Each chunk is written as a line of newline
+//	                        delimited JSON, as application/x-ndjson.
+//	StreamingModeJSONLines: Same as StreamingModeNDJSON, as application/jsonl.
+//	StreamingModeJSONArray: Chunks are written as the elements of a single
+//	                        JSON array, as application/json. If the stream
+//	                        fails midway the array is left unterminated, so
+//	                        clients do not mistake it for a complete response.
+type StreamingMode int
+
+const (
+	StreamingModeRaw StreamingMode = iota
+	StreamingModeSSE
+	StreamingModeNDJSON
+	StreamingModeJSONLines
+	StreamingModeJSONArray
+)
+
+// SSEOptions configures a StreamingModeSSE response.
+//
+// Fields
+//
+//	Retry:             Reconnection time hint sent to the client when the stream
+//	                   starts. Zero sends none.
+//	HeartbeatInterval: Interval at which comments are sent to keep idle
+//	                   connections open through proxies. Zero disables them.
+//	ReplayBufferSize:  Number of events with an id kept per stream, and replayed
+//	                   to clients reconnecting with a Last-Event-ID header.
+//	                   Zero disables replay.
+//	ReplayKey:         Identifies the stream a request belongs to for replay.
+//	                   Defaults to the request path.
+//	ReplayMaxStreams:  Number of streams kept for replay, dropping the least
+//	                   recently written ones beyond it. Defaults to 1024.
+//	ReplayTTL:         How long the events of a stream no longer written to
+//	                   are kept. Defaults to 5 minutes.
+type SSEOptions struct {
+	Retry             time.Duration
+	HeartbeatInterval time.Duration
+	ReplayBufferSize  int
+	ReplayKey         func(r *http.Request) string
+	ReplayMaxStreams  int
+	ReplayTTL         time.Duration
 }
 
+// StreamWriter sends chunks of a streaming response to the client. It is safe
+// to use after the client disconnects: writes then fail with an error instead
+// of blocking or panicking.
+//
+// The status code and headers are sent with the first chunk, so until then a
+// handler may still set them, or return an error to send an error response.
+type StreamWriter interface {
+	// Send encodes data with the method's encoder and sends it as a chunk. It
+	// blocks until the chunk is written, applying backpressure to the handler.
+	// Without an encoder, the JSON streaming modes encode strings and byte
+	// slices as JSON strings; send a json.RawMessage for encoded JSON.
+	Send(data interface{}) error
+	// SendEvent sends a chunk with its id, event type, retry hint or comment.
+	SendEvent(chunk StreamChunk) error
+	// Flush sends the status code and headers if not sent yet, and flushes any
+	// buffered data to the client.
+	Flush() error
+	// Done is closed when the client disconnects or the stream ends.
+	Done() <-chan struct{}
+	// SetHeader sets a response header. It fails once the first chunk is sent.
+	SetHeader(key, value string) error
+}
+
+// StreamingHandlerFunc defines a function for serving streaming HTTP responses.
+//
+// Parameters
+//
+//	ctx:    The request-scoped context, canceled when the client disconnects.
+//	req:    The decoded HTTP request payload.
+//	stream: The writer to send chunks of the response with.
+//
+// Returns
+//
+//	err: A non-nil error if the stream failed. If nothing was sent yet, it is
+//	     sent to the client as an error response.
+type StreamingHandlerFunc func(ctx context.Context, req interface{}, stream StreamWriter) error
+
+// RequestStreamingMode selects how a streamed request body is split into items.
+//
+// # Constants
+//
+//	RequestStreamingModeRaw:       Items are []byte chunks of the body, as they
+//	                               arrive, of at most ChunkSize bytes.
+//	RequestStreamingModeNDJSON:    Items are the JSON values of the body, decoded
+//	                               one by one, e.g. from newline delimited JSON.
+//	RequestStreamingModeMultipart: Items are the *multipart.Part of a multipart
+//	                               body, to be read before receiving the next.
+type RequestStreamingMode int
+
+const (
+	RequestStreamingModeRaw RequestStreamingMode = iota
+	RequestStreamingModeNDJSON
+	RequestStreamingModeMultipart
+)
+
+// RequestStreamOptions configures a streamed request body.
+//
+// Fields
+//
+//	Mode:      How the body is split into items.
+//	ChunkSize: The maximum size of RequestStreamingModeRaw chunks. Defaults to
+//	           32KiB.
+type RequestStreamOptions struct {
+	Mode      RequestStreamingMode
+	ChunkSize int
+}
+
+// RequestStream receives the items of a request body while it is still being
+// uploaded.
+type RequestStream interface {
+	// Recv blocks until the next item of the body is received. It returns
+	// io.EOF once the body is fully read.
+	Recv() (interface{}, error)
+}
+
+// ClientStreamingHandlerFunc defines a function for serving HTTP requests whose
+// body is streamed.
+//
+// Parameters
+//
+//	ctx:     The request-scoped context, canceled when the client disconnects.
+//	request: The stream of items of the request body.
+//
+// Returns
+//
+//	resp: The response object to be encoded and sent back to the client.
+//	err:  A non-nil error if the request could not be processed successfully.
+type ClientStreamingHandlerFunc func(ctx context.Context, request RequestStream) (interface{}, error)
+
+// BidiStreamingHandlerFunc defines a function for serving HTTP requests whose
+// body and response are both streamed, concurrently.
+//
+// Parameters
+//
+//	ctx:     The request-scoped context, canceled when the client disconnects.
+//	request: The stream of items of the request body.
+//	stream:  The writer to send chunks of the response with.
+//
+// Returns
+//
+//	err: A non-nil error if the stream failed. If nothing was sent yet, it is
+//	     sent to the client as an error response.
+type BidiStreamingHandlerFunc func(ctx context.Context, request RequestStream, stream StreamWriter) error
@@ -0,0 +1,72 @@
+package types
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// TusUpload is the state of a resumable upload.
+//
+// Fields
+//
+//	Id:        The id of the upload, part of its URL.
+//	Size:      The total size of the upload in bytes.
+//	Offset:    The number of bytes received so far.
+//	Metadata:  The metadata sent by the client when creating the upload, e.g.
+//	           its file name.
+//	ExpiresAt: The time after which an incomplete upload is discarded. Zero
+//	           if it never expires.
+type TusUpload struct {
+	Id        string            `json:"id"`
+	Size      int64             `json:"size"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at,omitempty"`
+}
+
+// IsComplete reports whether all the bytes of the upload were received.
+func (u TusUpload) IsComplete() bool {
+	return u.Offset >= u.Size
+}
+
+// TusStore persists resumable uploads. Implementations must return a
+// NotFound error from pkg/errors for unknown uploads.
+type TusStore interface {
+	// Create persists a new, empty upload.
+	Create(ctx context.Context, upload TusUpload) error
+	// Get returns the current state of an upload.
+	Get(ctx context.Context, id string) (TusUpload, error)
+	// Append writes r at offset, which is the current offset of the upload,
+	// and returns the number of bytes written. Bytes written before an error
+	// must be kept, so clients can resume from them.
+	Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error)
+	// Delete removes an upload and its data.
+	Delete(ctx context.Context, id string) error
+	// List returns the ids of all the uploads, to discard the expired ones.
+	List(ctx context.Context) ([]string, error)
+}
+
+// TusOptions configures a tus resumable upload endpoint.
+//
+// Fields
+//
+//	MaxSize:       The maximum size of an upload. Zero means no limit.
+//	Expiration:    The time after which incomplete uploads expire, counted
+//	               from their creation. Zero disables expiration.
+//	SweepInterval: The interval at which expired uploads are discarded, in
+//	               addition to when they are requested. Defaults to
+//	               Expiration.
+//	PreCreate:     Called before an upload is created, e.g. to validate its
+//	               metadata. Returning an error rejects the upload.
+//	OnComplete:    Called once all the bytes of an upload were received, by
+//	               the PATCH request completing it, or when creating an
+//	               empty upload. An error is sent to the client in response
+//	               to that request.
+type TusOptions struct {
+	MaxSize       int64
+	Expiration    time.Duration
+	SweepInterval time.Duration
+	PreCreate     func(ctx context.Context, upload TusUpload) error
+	OnComplete    func(ctx context.Context, upload TusUpload) error
+}
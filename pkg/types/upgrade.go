@@ -0,0 +1,28 @@
+package types
+
+import "time"
+
+// UpgradeOptions configures zero-downtime upgrades, where the running process
+// starts its executable again, hands it its sockets, and drains once the new
+// process serves them. Upgrades are started by Upgrade, or by SIGHUP and
+// SIGUSR2 once enabled.
+//
+// The UDP socket of HTTP/3 is shared by both processes while the old one
+// drains, and each drops the packets it reads for the connections of the
+// other, which QUIC recovers from by retransmitting them: the connections of
+// both processes see losses. The old process asks its HTTP/3 clients to go
+// away, and stops reading the socket as soon as their connections are closed,
+// or DrainTimeout passes.
+//
+// Fields
+//
+//	Enabled:      Upgrades on SIGHUP and SIGUSR2. Not supported on Windows.
+//	ReadyTimeout: How long to wait for the new process to serve the sockets
+//	              before giving up and killing it. Defaults to 30 seconds.
+//	DrainTimeout: How long requests in flight may take to complete once the
+//	              new process is ready. Defaults to 30 seconds.
+type UpgradeOptions struct {
+	Enabled      bool
+	ReadyTimeout time.Duration
+	DrainTimeout time.Duration
+}
@@ -1,7 +1,154 @@
 package types
 
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WebSocketHandshake is the outcome of a successful WebSocket handshake.
+//
+// Fields
+//
+//	Subprotocol: The subprotocol selected among the ones offered by the client
+//	             in Sec-WebSocket-Protocol. Empty selects none.
+//	Headers:     Additional headers sent with the upgrade response.
+type WebSocketHandshake struct {
+	Subprotocol string
+	Headers     http.Header
+}
+
+// WebSocketHandshakeFunc is called before every WebSocket upgrade.
+//
+// Deprecated: use WebSocketUpgradeFunc, which can inspect and reject the
+// upgrade request.
 type WebSocketHandshakeFunc func()
 
+// WebSocketUpgradeFunc inspects a WebSocket upgrade request before it is
+// accepted.
+//
+// Parameters
+//
+//	ctx: The request-scoped context.
+//	r:   The upgrade request, e.g. to authenticate the client or read the
+//	     offered subprotocols.
+//
+// Returns
+//
+//	outCtx:    The context for the lifetime of the session, e.g. carrying the
+//	           authenticated user.
+//	handshake: The subprotocol and headers of the upgrade response.
+//	err:       A non-nil error rejects the upgrade, with the status of the
+//	           error.
+type WebSocketUpgradeFunc func(ctx context.Context, r *http.Request) (outCtx context.Context, handshake WebSocketHandshake, err error)
+
+// WebsocketDecoder defines a function type for decoding a WebSocket message
+// into a Go value suitable for a handler.
+//
+// Parameters
+//
+//	ctx:         The session context.
+//	messageType: The type of the frame, text or binary.
+//	data:        The payload of the message.
+//
+// Returns
+//
+//	message: The decoded message.
+//	err:     A non-nil error if the message could not be decoded.
+type WebsocketDecoder func(ctx context.Context, messageType WebsocketMessageType, data []byte) (message interface{}, err error)
+
+// WebsocketMessage is a message received on a WebSocket.
+//
+// Fields
+//
+//	MessageType: The type of the frame, text or binary.
+//	Data:        The message decoded by the endpoint's decoder.
+//	Raw:         The payload of the message as received.
+type WebsocketMessage struct {
+	MessageType WebsocketMessageType
+	Data        interface{}
+	Raw         []byte
+}
+
+// WebsocketConn is an upgraded WebSocket session. Messages received go through
+// the endpoint's decoder and before serve middlewares, and messages sent
+// through its after serve middlewares and encoder.
+type WebsocketConn interface {
+	// Recv blocks until the next message is received. It returns io.EOF once
+	// the client closes the session normally.
+	Recv() (WebsocketMessage, error)
+	// Send encodes data and sends it as a text message.
+	Send(data interface{}) error
+	// SendMessage encodes data and sends it as a message of messageType.
+	SendMessage(messageType WebsocketMessageType, data interface{}) error
+	// Subprotocol returns the subprotocol selected during the handshake.
+	Subprotocol() string
+	// Close sends the queued messages and a close frame with code and reason,
+	// and closes the session.
+	Close(code int, reason string) error
+
+	// Id returns the id of the session, unique in the server.
+	Id() string
+	// Join adds the session to a room of the server's hub.
+	Join(room string)
+	// Leave removes the session from a room of the server's hub.
+	Leave(room string)
+	// Rooms returns the rooms the session is in.
+	Rooms() []string
+	// SetMetadata sets metadata listed with the session in the hub, e.g. the
+	// user it belongs to.
+	SetMetadata(key, value string)
+}
+
+// WebsocketSession describes a session open in the server's hub.
+//
+// Fields
+//
+//	Id:          The id of the session.
+//	Url:         The path of the endpoint the session is open on.
+//	RemoteAddr:  The network address of the client.
+//	Subprotocol: The subprotocol selected during the handshake.
+//	Rooms:       The rooms the session is in.
+//	Metadata:    The metadata set on the session.
+//	ConnectedAt: The time the session was opened.
+type WebsocketSession struct {
+	Id          string
+	Url         string
+	RemoteAddr  string
+	Subprotocol string
+	Rooms       []string
+	Metadata    map[string]string
+	ConnectedAt time.Time
+}
+
+// WebsocketSlowConsumerPolicy selects what happens to messages sent through
+// the hub to a session whose send buffer is full.
+//
+// # Constants
+//
+//	WebsocketSlowConsumerDrop:       The message is dropped for that session.
+//	WebsocketSlowConsumerDisconnect: The session is closed, so the client can
+//	                                 reconnect and resynchronise.
+type WebsocketSlowConsumerPolicy int
+
+const (
+	WebsocketSlowConsumerDrop WebsocketSlowConsumerPolicy = iota
+	WebsocketSlowConsumerDisconnect
+)
+
+// WebsocketConnHandlerFunc defines a function for serving an upgraded
+// WebSocket session.
+//
+// Parameters
+//
+//	ctx:  The session context, canceled once the session is closed.
+//	conn: The session to receive and send messages with.
+//
+// Returns
+//
+//	err: A non-nil error closes the session with an internal error code.
+type WebsocketConnHandlerFunc func(ctx context.Context, conn WebsocketConn) error
+
 // WebsocketStreamChunk represents a single chunk of data in a streaming HTTP response,
 // such as Server-Sent Events (SSE) or other streaming protocols.
 //
@@ -11,10 +158,13 @@ type WebSocketHandshakeFunc func()
 //	      or support reconnection/resume logic.
 //	MessageType: The type of message being sent (e.g., text, binary, close).
 //	Data: The raw byte payload of the chunk to be sent to the client.
+//	Message: The message decoded by the endpoint's decoder and before serve
+//	         middlewares, set on chunks of the request channel.
 type WebsocketStreamChunk struct {
 	Id          uint32
 	MessageType WebsocketMessageType
 	Data        []byte
+	Message     interface{}
 }
 
 // WebsocketMessageType represents the type of a WebSocket frame as defined by
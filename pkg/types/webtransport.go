@@ -0,0 +1,96 @@
+package types
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// WebTransportOption defines configuration options for a WebTransport
+// endpoint.
+//
+// Fields
+//
+//	AllowedOrigins: A list of allowed origin URLs for incoming sessions. If
+//	                empty, no origin restriction is applied.
+type WebTransportOption struct {
+	AllowedOrigins []string
+}
+
+// WebTransportHandshakeFunc defines a function for inspecting the CONNECT
+// request opening a WebTransport session, before the session is accepted.
+//
+// Parameters
+//
+//	ctx: The request context.
+//	r:   The CONNECT request opening the session.
+//
+// Returns
+//
+//	ctx: The context to serve the session with.
+//	err: A non-nil error rejects the session with an HTTP error response.
+type WebTransportHandshakeFunc func(ctx context.Context, r *http.Request) (context.Context, error)
+
+// WebTransportStream is a bidirectional WebTransport stream. Close closes
+// the sending side only.
+type WebTransportStream interface {
+	io.ReadWriteCloser
+	SetDeadline(t time.Time) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// WebTransportSendStream is a unidirectional WebTransport stream opened by the
+// server.
+type WebTransportSendStream interface {
+	io.WriteCloser
+	SetWriteDeadline(t time.Time) error
+}
+
+// WebTransportReceiveStream is a unidirectional WebTransport stream opened by
+// the client.
+type WebTransportReceiveStream interface {
+	io.Reader
+	SetReadDeadline(t time.Time) error
+}
+
+// WebTransportSession is a WebTransport session over HTTP/3, carrying any
+// number of reliable streams, and unreliable datagrams.
+type WebTransportSession interface {
+	// AcceptStream waits for the client to open a bidirectional stream.
+	AcceptStream(ctx context.Context) (WebTransportStream, error)
+	// AcceptUniStream waits for the client to open a unidirectional stream.
+	AcceptUniStream(ctx context.Context) (WebTransportReceiveStream, error)
+	// OpenStream opens a bidirectional stream, waiting while the client's
+	// stream limit is reached.
+	OpenStream(ctx context.Context) (WebTransportStream, error)
+	// OpenUniStream opens a unidirectional stream, waiting while the
+	// client's stream limit is reached.
+	OpenUniStream(ctx context.Context) (WebTransportSendStream, error)
+	// SendDatagram sends an unreliable datagram, which must fit in a single
+	// QUIC packet.
+	SendDatagram(data []byte) error
+	// ReceiveDatagram waits for a datagram from the client.
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+	// RemoteAddr is the address of the client.
+	RemoteAddr() net.Addr
+	// Close closes the session and all its streams with an application
+	// error code and reason.
+	Close(code uint32, reason string) error
+}
+
+// WebTransportHandlerFunc defines a function for serving a WebTransport
+// session.
+//
+// Parameters
+//
+//	ctx:     The session context, canceled once the session is closed.
+//	session: The session to exchange streams and datagrams with.
+//
+// Returns
+//
+//	err: A non-nil error closes the session with error code 1, and the
+//	     public message of the error as the reason.
+type WebTransportHandlerFunc func(ctx context.Context, session WebTransportSession) error